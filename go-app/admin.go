@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"runtime"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/contrib/zpages"
+	"go.opentelemetry.io/otel/attribute"
+
+	"my-go-app/pkg/health"
+)
+
+// newAdminMux returns the routes served on the admin port, apart from the
+// application traffic: the liveness probe at /healthz, the readiness probe
+// at /readyz, live spans at /debug/tracez, the effective configuration at
+// /debug/config, runtime stats at /debug/runtime and, when pprof is set,
+// pprof at /debug/pprof. Operational endpoints (/admin/...) are added by the
+// caller.
+func newAdminMux(readiness http.Handler, liveness map[string]health.Check, spans *zpages.SpanProcessor, pprof bool) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", livenessHandler(liveness))
+	mux.Handle("/readyz", readiness)
+	if pprof {
+		registerPprof(mux)
+	}
+	if spans != nil {
+		mux.Handle("/debug/tracez", zpages.NewTracezHandler(spans))
+	}
+	mux.HandleFunc("/debug/config", configHandler)
+	mux.HandleFunc("/debug/runtime", runtimeHandler)
+	return mux
+}
+
+// livenessHandler answers 200 while every check passes and 503 otherwise.
+// Unlike readiness, its checks are about the process itself, since failing
+// them makes Kubernetes restart the pod.
+func livenessHandler(checks map[string]health.Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alive := true
+		var statuses []health.Status
+		for _, name := range slices.Sorted(maps.Keys(checks)) {
+			s := health.Status{Name: name, Healthy: true}
+			if err := checks[name](r.Context()); err != nil {
+				alive, s.Healthy, s.Error = false, false, err.Error()
+			}
+			statuses = append(statuses, s)
+		}
+		health.WriteStatus(w, alive, statuses)
+	})
+}
+
+// configHandler serves the effective configuration summarized in the
+// startup banner, plus the current log level.
+func configHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, configDocument())
+}
+
+func configDocument() map[string]any {
+	s := effectiveConfig()
+	doc := map[string]any{
+		"config":      attributeMap(s.config),
+		"integration": attributeMap(s.integrations),
+		"build":       attributeMap(s.build),
+		"resource":    attributeMap(s.resource),
+	}
+	if logSeverityFilter != nil {
+		doc["log_level"] = severityName(logSeverityFilter.Min())
+	}
+	return doc
+}
+
+// runtimeHandler serves a snapshot of goroutine, memory and GC statistics.
+func runtimeHandler(w http.ResponseWriter, _ *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeJSON(w, map[string]any{
+		"uptime_s":          time.Since(startTime).Seconds(),
+		"goroutines":        runtime.NumGoroutine(),
+		"gomaxprocs":        runtime.GOMAXPROCS(0),
+		"heap_alloc_bytes":  ms.HeapAlloc,
+		"heap_inuse_bytes":  ms.HeapInuse,
+		"heap_objects":      ms.HeapObjects,
+		"sys_bytes":         ms.Sys,
+		"gc_count":          ms.NumGC,
+		"gc_pause_total_s":  time.Duration(ms.PauseTotalNs).Seconds(),
+		"gc_cpu_fraction":   ms.GCCPUFraction,
+		"next_gc_bytes":     ms.NextGC,
+		"last_gc_unix_nano": ms.LastGC,
+	})
+}
+
+func attributeMap(attrs []attribute.KeyValue) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// attributeFilterProcessor strips span and span event attributes whose key
+// is not on the allowlist, or is on the denylist, before passing spans to
+// next. Entries ending in "*" match a key prefix (e.g. "http.*"). Dropped
+// attributes are counted per key in app.telemetry.span_attributes.dropped,
+// which shows what a new allowlist would remove before it is enforced. The
+// telemetry canary's span is passed on unfiltered.
+type attributeFilterProcessor struct {
+	next    sdktrace.SpanProcessor
+	allow   []string // empty means every key is allowed
+	deny    []string
+	dropped metric.Int64Counter
+}
+
+func newAttributeFilterProcessor(next sdktrace.SpanProcessor, meter metric.Meter, allow, deny string) (*attributeFilterProcessor, error) {
+	dropped, err := metrics.TelemetrySpanAttributesDropped.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &attributeFilterProcessor{
+		next:    next,
+		allow:   splitList(allow),
+		deny:    splitList(deny),
+		dropped: dropped,
+	}, nil
+}
+
+func splitList(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func matchKey(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *attributeFilterProcessor) keep(key attribute.Key) bool {
+	if len(p.allow) > 0 && !matchKey(p.allow, string(key)) {
+		return false
+	}
+	return !matchKey(p.deny, string(key))
+}
+
+// filter returns attrs without the rejected keys, or nil if all are kept.
+func (p *attributeFilterProcessor) filter(attrs []attribute.KeyValue) []attribute.KeyValue {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		if p.keep(kv.Key) {
+			if out != nil {
+				out = append(out, kv)
+			}
+			continue
+		}
+		p.dropped.Add(context.Background(), 1, metric.WithAttributes(attribute.String("attribute.key", string(kv.Key))))
+		if out == nil {
+			out = append(make([]attribute.KeyValue, 0, len(attrs)), attrs[:i]...)
+		}
+	}
+	return out
+}
+
+func (p *attributeFilterProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *attributeFilterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if isCanarySpan(s) {
+		p.next.OnEnd(s)
+		return
+	}
+	attrs := p.filter(s.Attributes())
+	var events []sdktrace.Event
+	for i, e := range s.Events() {
+		filtered := p.filter(e.Attributes)
+		if filtered == nil {
+			continue
+		}
+		if events == nil {
+			events = append([]sdktrace.Event(nil), s.Events()...)
+		}
+		events[i].Attributes = filtered
+	}
+	if attrs == nil && events == nil {
+		p.next.OnEnd(s)
+		return
+	}
+	p.next.OnEnd(rewrittenSpan{ReadOnlySpan: s, attrs: attrs, events: events})
+}
+
+func (p *attributeFilterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *attributeFilterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"runtime"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/telemetry"
+)
+
+// configSummary is the resolved configuration, grouped as in the startup
+// banner and the admin server's /debug/config.
+type configSummary struct {
+	config, integrations, build, resource []attribute.KeyValue
+}
+
+// effectiveConfig summarizes the resolved configuration. URLs are reported
+// without credentials.
+func effectiveConfig() configSummary {
+	config := []attribute.KeyValue{
+		attribute.String("file", configFilePath),
+		attribute.Bool("telemetry.disabled", telemetryDisabled),
+		attribute.Bool("telemetry.canary", telemetryCanaryEnabled),
+		attribute.String("otlp.endpoint", redactURL(otlpEndpoint)),
+		attribute.String("traces.exporter", tracesExporter),
+		attribute.String("traces.zipkin_endpoint", redactURL(zipkinEndpoint)),
+		attribute.String("prometheus.remote_write_url", redactURL(remoteWriteURL)),
+		attribute.String("http.client_trace", httpClientTrace),
+		attribute.String("loki.push_url", redactURL(lokiPushURL)),
+		attribute.String("loki.labels", lokiLabels),
+		attribute.Bool("otlp.insecure", otlpInsecure),
+		attribute.Int("otlp.max_message_size", otlpMaxMessageSize),
+		attribute.Int64("telemetry.buffer_memory", telemetryBufferBudget()),
+		attribute.String("otlp.startup_timeout", otlpStartupTimeout.String()),
+		attribute.String("admin.address", adminAddr),
+		attribute.Bool("admin.pprof", pprofEnabled),
+		attribute.String("grpc.address", grpcAddr),
+		attribute.String("downstream.grpc_address", downstreamGRPCAddr),
+		attribute.String("downstream.cache_ttl", downstreamCacheTTL.String()),
+		attribute.String("downstream.cache_stale", downstreamCacheStale.String()),
+		attribute.String("cache.redis_url", redactURL(cacheRedisURL)),
+		attribute.String("queue.transport", queueTransport),
+		attribute.String("queue.kafka_brokers", kafkaBrokers),
+		attribute.String("queue.nats_url", redactURL(natsURL)),
+		attribute.String("queue.topic", queueTopic),
+		attribute.String("queue.consumer_group", queueConsumerGroup),
+		attribute.String("queue.time_objective", queueTimeObjective.String()),
+		attribute.Bool("work.async", workAsync),
+		attribute.Int("worker.concurrency", workerConcurrency),
+		attribute.Int("worker.queue_size", workerQueueSize),
+		attribute.String("cleanup.schedule", cleanupSchedule),
+		attribute.Float64("rate_limit", rateLimit),
+		attribute.Int("rate_limit.burst", rateLimitBurst),
+		attribute.String("opamp.server_url", redactURL(opampServerURL)),
+		attribute.String("collector_ready_grace", collectorReadyGrace.String()),
+		attribute.String("export_failure_threshold", exportFailureThreshold.String()),
+		attribute.String("sdk_error_log_interval", sdkErrorLogInterval.String()),
+		attribute.Float64("trace.sample_ratio", traceSampleRatio),
+		attribute.String("span.export_kinds", spanExportKinds),
+		attribute.String("span.drop_kinds", spanDropKinds),
+		attribute.String("span.drop_rules", spanDropRulesConfig),
+		attribute.String("span.attribute_allowlist", spanAttributeAllowlist),
+		attribute.String("span.attribute_denylist", spanAttributeDenylist),
+		attribute.String("telemetry.attributes", telemetryAttributes),
+		attribute.String("trace.id_generator", traceIDGenerator),
+		attribute.Int("span.limits.attribute_value_length", spanLimits.AttributeValueLengthLimit),
+		attribute.Int("span.limits.attribute_count", spanLimits.AttributeCountLimit),
+		attribute.Int("span.limits.event_count", spanLimits.EventCountLimit),
+		attribute.Int("span.limits.link_count", spanLimits.LinkCountLimit),
+		attribute.Int("span.limits.attribute_per_event_count", spanLimits.AttributePerEventCountLimit),
+		attribute.Int("span.limits.attribute_per_link_count", spanLimits.AttributePerLinkCountLimit),
+		attribute.String("hot_operations", hotOperationsConfig),
+		attribute.String("experiment.weights", experimentWeights),
+		attribute.String("webhook.dedup_window", webhookDedupWindow.String()),
+		attribute.String("webhook.event_types", webhookEventTypes),
+		attribute.String("mirror.url", redactURL(mirrorURL)),
+		attribute.String("metric.views_file", metricViewsFile),
+		attribute.String("dependency.policy_file", dependencyPolicyFile),
+		attribute.String("metric.definitions_file", metricDefinitionsFile),
+		attribute.String("metric.histogram_aggregation", histogramAggregation),
+		attribute.Int("metric.cardinality_limit", metricCardinalityLimit),
+		attribute.String("metric.export_interval", metricExportInterval.String()),
+		attribute.String("metric.export_timeout", metricExportTimeout.String()),
+		attribute.String("metric.preaggregation_interval", metricPreaggregation.String()),
+		attribute.String("log.level", logLevel),
+		attribute.String("log.routes", logRoutesConfig),
+		attribute.String("store.driver", storeDriver),
+		attribute.String("request_timeout", requestTimeout.String()),
+		attribute.String("session_ttl", sessionTTL.String()),
+		attribute.String("shutdown.timeout", shutdownTimeout.String()),
+		attribute.String("shutdown.telemetry_timeout", shutdownTelemetryTimeout.String()),
+		attribute.String("shutdown.signals", shutdownSignals),
+		attribute.String("shutdown.pre_stop_delay", preStopDelay.String()),
+		attribute.String("shutdown.summary_file", shutdownSummaryFile),
+		attribute.String("log.console_format", consoleLogFormat),
+		attribute.String("disabled_endpoints", disabledEndpoints),
+		attribute.Bool("maintenance_mode", maintenanceEnabled),
+		attribute.Int("client_telemetry_budget", clientTelemetryLimit),
+	}
+	integrations := []attribute.KeyValue{
+		attribute.Bool("runtime_metrics", runtimeMetricsEnabled),
+		attribute.Bool("host_metrics", hostMetricsEnabled),
+		attribute.Bool("request_cpu_time", requestCPUTimeEnabled),
+		attribute.Bool("adaptive_sampling", adaptiveSamplingEnabled),
+		attribute.Bool("consistent_sampling", consistentSamplingEnabled),
+		attribute.Bool("span_metrics", spanMetricsEnabled),
+		attribute.Bool("tail_sampling", tailSamplingEnabled),
+		attribute.Bool("traffic_mirror", shadowTraffic != nil),
+		attribute.Bool("redaction", redactionEnabled),
+		attribute.Bool("journey_links", journeyLinks),
+		attribute.Bool("opentracing_bridge", openTracingBridge),
+	}
+	build := []attribute.KeyValue{
+		attribute.String("version", telemetry.Version),
+		attribute.String("go_version", runtime.Version()),
+		attribute.Int("gomaxprocs", runtime.GOMAXPROCS(0)),
+		attribute.String("gomaxprocs.source", gomaxprocsSource),
+		attribute.Float64("cpu_quota", cpuQuota),
+		attribute.Int64("gomemlimit", gomemlimit()),
+		attribute.String("gomemlimit.source", gomemlimitSource),
+		attribute.Int64("memory_limit", memoryLimit),
+	}
+	var res []attribute.KeyValue
+	if serviceResource != nil {
+		res = serviceResource.Attributes()
+	}
+	return configSummary{config, integrations, build, res}
+}
+
+// redactURL strips the password from a URL, leaving other values as is.
+func redactURL(s string) string {
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return s
+}
+
+// logStartupBanner emits a single record summarizing the resolved
+// configuration, resource attributes, enabled integrations and listening
+// address, plus a startup span carrying the same summary as an event, so a
+// misbehaving deployment can be triaged from its logs alone.
+func logStartupBanner(ctx context.Context, addr string) {
+	s := effectiveConfig()
+
+	ctx, span := tracer.Start(ctx, "startup")
+	defer span.End()
+	var event []attribute.KeyValue
+	event = append(event, attribute.String("listen.address", addr))
+	event = append(event, prefixed("config.", s.config)...)
+	event = append(event, prefixed("integration.", s.integrations)...)
+	event = append(event, prefixed("build.", s.build)...)
+	event = append(event, prefixed("resource.", s.resource)...)
+	span.AddEvent("startup", trace.WithAttributes(event...))
+
+	logger.InfoContext(ctx, "Server started",
+		slog.String("listen.address", addr),
+		slogGroup("config", s.config),
+		slogGroup("integration", s.integrations),
+		slogGroup("build", s.build),
+		slogGroup("resource", s.resource),
+	)
+}
+
+func prefixed(prefix string, attrs []attribute.KeyValue) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		out[i] = attribute.KeyValue{Key: attribute.Key(prefix) + kv.Key, Value: kv.Value}
+	}
+	return out
+}
+
+func slogGroup(name string, attrs []attribute.KeyValue) slog.Attr {
+	args := make([]any, len(attrs))
+	for i, kv := range attrs {
+		args[i] = slog.Any(string(kv.Key), kv.Value.AsInterface())
+	}
+	return slog.Group(name, args...)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// canaryKey labels the startup self-test's telemetry for lookups in the
+// backends. Any caller can set it on its own telemetry, so nothing trusts
+// it: the pipeline recognizes the canary by canaryContextKey and
+// telemetryCanary.span instead.
+const canaryKey = attribute.Key("canary")
+
+// canaryContextKey marks the context the canary signals are emitted in.
+// Being unexported, only emit can set it.
+type canaryContextKey struct{}
+
+// isCanaryContext reports whether ctx is the canary's.
+func isCanaryContext(ctx context.Context) bool {
+	v, _ := ctx.Value(canaryContextKey{}).(bool)
+	return v
+}
+
+// canaryReport identifies the canary signals, for post-deploy automation to
+// look up in the backends: the trace by ID, the metric point by canary.id
+// and the log record by canary.id or its trace and span IDs.
+type canaryReport struct {
+	ID        string    `json:"id"`
+	EmittedAt time.Time `json:"emitted_at"`
+	Trace     struct {
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+		Name    string `json:"name"`
+	} `json:"trace"`
+	Metric struct {
+		Name       string            `json:"name"`
+		Attributes map[string]string `json:"attributes"`
+	} `json:"metric"`
+	Log struct {
+		Body    string `json:"body"`
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+	} `json:"log"`
+	// Flush is "pending" until the signals were flushed, then "exported"
+	// if the collector accepted them, or "failed" with FlushError.
+	Flush      string `json:"flush"`
+	FlushError string `json:"flush_error,omitempty"`
+}
+
+// telemetryCanary is the startup self-test: one span, one metric point and
+// one log record, all tagged canary=true and with the same canary.id.
+type telemetryCanary struct {
+	mu     sync.Mutex
+	report *canaryReport // nil until emitted
+	span   atomic.Pointer[trace.SpanContext]
+}
+
+// isCanarySpan reports whether s is the canary span, which the export
+// filters let through unchanged: the self-test checks the pipeline works,
+// not that it is configured to keep its span.
+func isCanarySpan(s sdktrace.ReadOnlySpan) bool {
+	sc := canary.span.Load()
+	return sc != nil && sc.Equal(s.SpanContext())
+}
+
+// emit records the canary signals and flushes them in the background, so
+// they are exported right away rather than with the next batch.
+func (c *telemetryCanary) emit(ctx context.Context) error {
+	counter, err := metrics.TelemetryCanary.Int64Counter(meter)
+	if err != nil {
+		return err
+	}
+	r := &canaryReport{ID: uuid.NewString(), EmittedAt: time.Now().UTC(), Flush: "pending"}
+	attrs := []attribute.KeyValue{canaryKey.Bool(true), attribute.String("canary.id", r.ID)}
+
+	r.Trace.Name = "telemetry.canary"
+	ctx = context.WithValue(ctx, canaryContextKey{}, true)
+	ctx, span := tracer.Start(ctx, r.Trace.Name, trace.WithNewRoot(), trace.WithAttributes(attrs...))
+	sc := span.SpanContext()
+	c.span.Store(&sc)
+	counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	r.Log.Body = "Telemetry canary"
+	// Emitted on the OTel logger rather than through appLog, whose level
+	// check would drop it under LOG_LEVEL=warn; the severity filter keeps
+	// records emitted in the canary's context whatever the level.
+	var record otellog.Record
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetSeverityText(otellog.SeverityInfo.String())
+	record.SetBody(otellog.StringValue(r.Log.Body))
+	record.AddAttributes(otellog.Bool(string(canaryKey), true), otellog.String("canary.id", r.ID))
+	mainScope.Logger().Emit(ctx, record)
+	span.End()
+
+	r.Trace.TraceID, r.Trace.SpanID = sc.TraceID().String(), sc.SpanID().String()
+	r.Log.TraceID, r.Log.SpanID = r.Trace.TraceID, r.Trace.SpanID
+	r.Metric.Name = metrics.TelemetryCanary.Name
+	r.Metric.Attributes = map[string]string{"canary": "true", "canary.id": r.ID}
+
+	c.mu.Lock()
+	c.report = r
+	c.mu.Unlock()
+
+	go func() {
+		err := flushTelemetry(context.WithoutCancel(ctx), "canary")
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		r.Flush = "exported"
+		if err != nil {
+			r.Flush, r.FlushError = "failed", err.Error()
+		}
+	}()
+	return nil
+}
+
+// ServeHTTP serves the canary report, or 404 before the canary was
+// emitted.
+func (c *telemetryCanary) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.mu.Lock()
+	var report canaryReport
+	emitted := c.report != nil
+	if emitted {
+		report = *c.report
+	}
+	c.mu.Unlock()
+	if !emitted {
+		http.Error(w, "The telemetry canary has not been emitted", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, report)
+}
+
+// canarySampler samples spans started in the canary's context whatever the
+// wrapped sampler decides, so the self-test does not depend on the sampling
+// ratio.
+type canarySampler struct {
+	sdktrace.Sampler
+}
+
+func (s canarySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if isCanaryContext(p.ParentContext) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.Sampler.ShouldSample(p)
+}
+
+func (s canarySampler) Description() string {
+	return "Canary{" + s.Sampler.Description() + "}"
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func canaryContext() context.Context {
+	return context.WithValue(context.Background(), canaryContextKey{}, true)
+}
+
+func TestCanarySamplerIgnoresCanaryAttribute(t *testing.T) {
+	s := canarySampler{sdktrace.NeverSample()}
+	forged := s.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		Name:          "telemetry.canary",
+		Attributes:    []attribute.KeyValue{canaryKey.Bool(true)},
+	})
+	if forged.Decision != sdktrace.Drop {
+		t.Errorf("span with a canary=true attribute: decision %v, want Drop", forged.Decision)
+	}
+	real := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: canaryContext(), Name: "telemetry.canary"})
+	if real.Decision != sdktrace.RecordAndSample {
+		t.Errorf("span in the canary's context: decision %v, want RecordAndSample", real.Decision)
+	}
+}
+
+func TestExportFiltersLetCanarySpanThrough(t *testing.T) {
+	defer canary.span.Store(nil)
+
+	recorded := &recordedSpans{}
+	attrs, err := newAttributeFilterProcessor(recorded, noop.NewMeterProvider().Meter("test"), "http.*", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		spanKindFilterProcessor{attrs, map[trace.SpanKind]bool{trace.SpanKindServer: true}},
+	))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+	canaryAttrs := trace.WithAttributes(canaryKey.Bool(true), attribute.String("canary.id", "1"))
+
+	_, forged := tracer.Start(context.Background(), "telemetry.canary", canaryAttrs)
+	forged.End()
+	if len(recorded.ended) != 0 {
+		t.Fatalf("an internal span with canary=true was exported")
+	}
+
+	_, span := tracer.Start(context.Background(), "telemetry.canary", canaryAttrs)
+	sc := span.SpanContext()
+	canary.span.Store(&sc)
+	span.End()
+	if len(recorded.ended) != 1 {
+		t.Fatalf("the canary span was not exported")
+	}
+	if got := recorded.ended[0].Attributes(); len(got) != 2 {
+		t.Errorf("canary span attributes = %v, want them unfiltered", got)
+	}
+}
+
+func TestSeverityFilterKeepsOnlyRealCanaryRecord(t *testing.T) {
+	recorded := &recordedLogs{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(newSeverityFilterProcessor(recorded, otellog.SeverityWarn)))
+	defer lp.Shutdown(context.Background())
+
+	emit := func(ctx context.Context, body string) {
+		var r otellog.Record
+		r.SetSeverity(otellog.SeverityInfo)
+		r.SetBody(otellog.StringValue(body))
+		r.AddAttributes(otellog.Bool(string(canaryKey), true))
+		lp.Logger("test").Emit(ctx, r)
+	}
+	emit(context.Background(), "forged")
+	emit(canaryContext(), "Telemetry canary")
+
+	if len(recorded.records) != 1 || recorded.records[0].Body().AsString() != "Telemetry canary" {
+		var bodies []string
+		for _, r := range recorded.records {
+			bodies = append(bodies, r.Body().AsString())
+		}
+		t.Errorf("kept %q, want only the record emitted in the canary's context", bodies)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+type clientIDKey struct{}
+
+// clientIDFromContext returns the client set by clientTelemetryBudget's
+// middleware, or "" outside a request.
+func clientIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// clientTelemetryBudget tracks the spans and log records generated on behalf
+// of each API client and caps them per window. A client over budget is
+// downgraded to metrics-only: its traces are sampled out, including those
+// a sampled caller started, and its log records dropped, while HTTP and span metrics keep covering its traffic.
+// Clients are identified by a fingerprint of their X-API-Key header, never
+// the key itself.
+type clientTelemetryBudget struct {
+	limit  int64
+	window time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	used        map[string]int64
+
+	items     metric.Int64Counter
+	throttled metric.Int64Counter
+}
+
+func newClientTelemetryBudget(meter metric.Meter, limit int64, window time.Duration) (*clientTelemetryBudget, error) {
+	b := &clientTelemetryBudget{
+		limit:       limit,
+		window:      window,
+		windowStart: time.Now(),
+		used:        make(map[string]int64),
+	}
+
+	var err error
+	b.items, err = metrics.ClientTelemetryItems.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	b.throttled, err = metrics.ClientTelemetryThrottled.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Middleware identifies the client of each request. It must run outside
+// otelhttp so the sampler sees the client when the request span starts.
+func (b *clientTelemetryBudget) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := "anonymous"
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			id = "key-" + hex.EncodeToString(sum[:4])
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIDKey{}, id)))
+	})
+}
+
+// overBudget reports whether client has used up its budget in the current
+// window.
+func (b *clientTelemetryBudget) overBudget(client string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollWindow()
+	return b.used[client] >= b.limit
+}
+
+// consume charges one item of signal to client.
+func (b *clientTelemetryBudget) consume(ctx context.Context, client, signal string) {
+	b.mu.Lock()
+	b.rollWindow()
+	b.used[client]++
+	b.mu.Unlock()
+	b.items.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("client.id", client),
+		attribute.String("signal", signal),
+	))
+}
+
+func (b *clientTelemetryBudget) recordThrottled(ctx context.Context, client, signal string) {
+	b.throttled.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("client.id", client),
+		attribute.String("signal", signal),
+	))
+}
+
+// rollWindow starts a new window once the current one has elapsed. b.mu
+// must be held.
+func (b *clientTelemetryBudget) rollWindow() {
+	if time.Since(b.windowStart) >= b.window {
+		b.windowStart = time.Now()
+		clear(b.used)
+	}
+}
+
+// clientBudgetSampler samples out the traces of clients over budget and
+// defers to next otherwise. Use it as the root sampler of ParentBased, and
+// with AlwaysSample as next for remote sampled parents, so a client cannot
+// bypass its budget by sending a sampled traceparent; local parents are
+// left alone, so a trace is never cut in the middle within the service.
+type clientBudgetSampler struct {
+	next   sdktrace.Sampler
+	budget *clientTelemetryBudget
+}
+
+func (s clientBudgetSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if client := clientIDFromContext(p.ParentContext); client != "" && s.budget.overBudget(client) {
+		s.budget.recordThrottled(p.ParentContext, client, "traces")
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s clientBudgetSampler) Description() string {
+	return fmt.Sprintf("ClientBudgetSampler{limit=%d,window=%s}/%s", s.budget.limit, s.budget.window, s.next.Description())
+}
+
+// clientBudgetSpanProcessor charges each exported span to its client and
+// tags it with client.id.
+type clientBudgetSpanProcessor struct {
+	budget *clientTelemetryBudget
+}
+
+func (p clientBudgetSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	client := clientIDFromContext(ctx)
+	if client == "" || !s.SpanContext().IsSampled() {
+		return
+	}
+	s.SetAttributes(attribute.String("client.id", client))
+	p.budget.consume(ctx, client, "traces")
+}
+
+func (clientBudgetSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (clientBudgetSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (clientBudgetSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// clientBudgetLogProcessor charges each log record to its client, dropping
+// the records of clients over budget before they reach next.
+type clientBudgetLogProcessor struct {
+	next   sdklog.Processor
+	budget *clientTelemetryBudget
+}
+
+func (p clientBudgetLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	client := clientIDFromContext(ctx)
+	if client == "" {
+		return p.next.OnEmit(ctx, r)
+	}
+	if p.budget.overBudget(client) {
+		p.budget.recordThrottled(ctx, client, "logs")
+		return nil
+	}
+	p.budget.consume(ctx, client, "logs")
+	return p.next.OnEmit(ctx, r)
+}
+
+func (p clientBudgetLogProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p clientBudgetLogProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestClientBudgetSampler(t *testing.T) {
+	budget, err := newClientTelemetryBudget(noop.NewMeterProvider().Meter("test"), 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler := sdktrace.ParentBased(clientBudgetSampler{sdktrace.AlwaysSample(), budget},
+		sdktrace.WithRemoteParentSampled(clientBudgetSampler{sdktrace.AlwaysSample(), budget}))
+	remoteSampled := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	localSampled := remoteSampled.WithRemote(false)
+
+	tests := []struct {
+		name   string
+		parent trace.SpanContext
+		client string
+		want   sdktrace.SamplingDecision
+	}{
+		{name: "root", client: "over", want: sdktrace.Drop},
+		{name: "remote sampled parent", parent: remoteSampled, client: "over", want: sdktrace.Drop},
+		{name: "local sampled parent", parent: localSampled, client: "over", want: sdktrace.RecordAndSample},
+		{name: "remote sampled parent within budget", parent: remoteSampled, client: "under", want: sdktrace.RecordAndSample},
+		{name: "no client", parent: remoteSampled, want: sdktrace.RecordAndSample},
+	}
+	budget.consume(context.Background(), "over", "traces")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := trace.ContextWithSpanContext(context.Background(), tt.parent)
+			if tt.client != "" {
+				ctx = context.WithValue(ctx, clientIDKey{}, tt.client)
+			}
+			got := sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: trace.TraceID{1}, Name: "GET /work"})
+			if got.Decision != tt.want {
+				t.Errorf("ShouldSample() = %v, want %v", got.Decision, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Command genhandler scaffolds a new instrumented endpoint for the demo
+// service: a handler that starts a span, counts the request and logs through
+// the OTel log pipeline, its route registration in main.go (which adds the
+// server span, RED metrics, kill switch, sessions, deadline and panic
+// recovery), and a test that checks the span and the counter with in-memory
+// exporters. Run it from go-app:
+//
+//	go run ./cmd/genhandler -name orders
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// validName keeps generated identifiers and file names simple.
+var validName = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+// routeLine matches the route registrations in main.go.
+var routeLine = regexp.MustCompile(`(?m)^\troute\(.*\)\n`)
+
+type endpoint struct {
+	Name    string // span and route name, e.g. "orders"
+	Route   string // URL pattern, e.g. "/orders"
+	Handler string // handler function, e.g. "ordersHandler"
+	Test    string // test function, e.g. "TestOrdersHandler"
+}
+
+func main() {
+	var (
+		ep    endpoint
+		dir   string
+		force bool
+	)
+	flag.StringVar(&ep.Name, "name", "", "endpoint name, e.g. orders (required)")
+	flag.StringVar(&ep.Route, "route", "", "URL pattern (default /<name>)")
+	flag.StringVar(&dir, "dir", ".", "directory of the demo service's main package")
+	flag.BoolVar(&force, "force", false, "overwrite existing files")
+	flag.Parse()
+
+	if err := run(ep, dir, force); err != nil {
+		log.Fatalf("genhandler: %v", err)
+	}
+}
+
+func run(ep endpoint, dir string, force bool) error {
+	if !validName.MatchString(ep.Name) {
+		return fmt.Errorf("-name must match %s, got %q", validName, ep.Name)
+	}
+	if ep.Route == "" {
+		ep.Route = "/" + ep.Name
+	}
+	if !strings.HasPrefix(ep.Route, "/") {
+		return fmt.Errorf("-route must start with /, got %q", ep.Route)
+	}
+	ep.Handler = ep.Name + "Handler"
+	ep.Test = "Test" + strings.ToUpper(ep.Handler[:1]) + ep.Handler[1:]
+
+	files := map[string]string{
+		ep.Name + ".go":      "handler.go.tmpl",
+		ep.Name + "_test.go": "handler_test.go.tmpl",
+	}
+	for file, tmpl := range files {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", path)
+		}
+		if err := render(path, tmpl, ep); err != nil {
+			return err
+		}
+		log.Printf("wrote %s", path)
+	}
+
+	registered, err := registerRoute(filepath.Join(dir, "main.go"), ep)
+	if err != nil {
+		return err
+	}
+	if registered {
+		log.Printf("registered %s in main.go", ep.Route)
+	}
+	log.Printf("next: go mod vendor && go test -run %s .", ep.Test)
+	return nil
+}
+
+func render(path, tmpl string, ep endpoint) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, tmpl, ep); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format %s: %w", path, err)
+	}
+	return os.WriteFile(path, src, 0o644)
+}
+
+// registerRoute adds the route after the last one in main.go, unless the
+// handler is registered already.
+func registerRoute(mainFile string, ep endpoint) (bool, error) {
+	src, err := os.ReadFile(mainFile)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Contains(src, []byte(", "+ep.Handler+")")) {
+		return false, nil
+	}
+	routes := routeLine.FindAllIndex(src, -1)
+	if len(routes) == 0 {
+		return false, errors.New("route registrations not found in main.go, register the handler by hand")
+	}
+	i := routes[len(routes)-1][1]
+	line := fmt.Sprintf("\troute(%q, %q, %s)\n", ep.Route, ep.Name, ep.Handler)
+	out := append(src[:i:i], append([]byte(line), src[i:]...)...)
+	return true, os.WriteFile(mainFile, out, 0o644)
+}
@@ -0,0 +1,229 @@
+// Command smoketest verifies end to end that a request sent to the demo
+// service produces a trace in the tracing backend and moves a metric in
+// Prometheus. It exits non-zero when either signal cannot be correlated, so it
+// can be used as a post-deploy gate.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"my-go-app/pkg/metrics"
+)
+
+type config struct {
+	target     string
+	jaegerURL  string
+	tempoURL   string
+	promURL    string
+	promQuery  string
+	timeout    time.Duration
+	pollPeriod time.Duration
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.target, "target", "http://localhost:8080/work", "URL of the service endpoint to exercise")
+	flag.StringVar(&cfg.jaegerURL, "jaeger", "http://localhost:16686", "Jaeger query base URL (empty to skip)")
+	flag.StringVar(&cfg.tempoURL, "tempo", "", "Tempo query base URL (empty to skip)")
+	flag.StringVar(&cfg.promURL, "prometheus", "http://localhost:9090", "Prometheus base URL (empty to skip)")
+	flag.StringVar(&cfg.promQuery, "prometheus-query", "sum("+metrics.PrometheusName(metrics.HTTPServerRequests)+")", "PromQL expression expected to increase after the request")
+	flag.DurationVar(&cfg.timeout, "timeout", 60*time.Second, "how long to wait for telemetry to show up in the backends")
+	flag.DurationVar(&cfg.pollPeriod, "poll", 2*time.Second, "interval between backend queries")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		log.Printf("smoketest FAILED: %v", err)
+		os.Exit(1)
+	}
+	log.Println("smoketest passed")
+}
+
+func run(cfg config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+
+	var baseline float64
+	if cfg.promURL != "" {
+		// A missing series simply means nothing has been recorded yet.
+		baseline, _ = queryPrometheus(ctx, cfg.promURL, cfg.promQuery)
+	}
+
+	traceID, err := sendRequest(ctx, cfg.target)
+	if err != nil {
+		return err
+	}
+	log.Printf("sent request to %s with trace id %s", cfg.target, traceID)
+
+	if cfg.jaegerURL != "" {
+		if err := poll(ctx, cfg.pollPeriod, "jaeger", func() error {
+			return findJaegerTrace(ctx, cfg.jaegerURL, traceID)
+		}); err != nil {
+			return err
+		}
+	}
+	if cfg.tempoURL != "" {
+		if err := poll(ctx, cfg.pollPeriod, "tempo", func() error {
+			return findTempoTrace(ctx, cfg.tempoURL, traceID)
+		}); err != nil {
+			return err
+		}
+	}
+	if cfg.promURL != "" {
+		if err := poll(ctx, cfg.pollPeriod, "prometheus", func() error {
+			v, err := queryPrometheus(ctx, cfg.promURL, cfg.promQuery)
+			if err != nil {
+				return err
+			}
+			if v <= baseline {
+				return fmt.Errorf("%s is %g, still not above baseline %g", cfg.promQuery, v, baseline)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendRequest calls the target with a freshly generated, sampled traceparent
+// so the resulting trace id is known in advance.
+func sendRequest(ctx context.Context, target string) (string, error) {
+	traceID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	spanID, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceID, spanID))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request to %s failed: %w", target, err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("request to %s returned %s", target, res.Status)
+	}
+	return traceID, nil
+}
+
+// poll retries check until it succeeds or ctx expires.
+func poll(ctx context.Context, every time.Duration, name string, check func() error) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		err := check()
+		if err == nil {
+			log.Printf("%s: ok", name)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", name, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func findJaegerTrace(ctx context.Context, base, traceID string) error {
+	var body struct {
+		Data []struct {
+			Spans []json.RawMessage `json:"spans"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, base+"/api/traces/"+traceID, &body); err != nil {
+		return err
+	}
+	if len(body.Data) == 0 || len(body.Data[0].Spans) == 0 {
+		return fmt.Errorf("trace %s has no spans yet", traceID)
+	}
+	return nil
+}
+
+func findTempoTrace(ctx context.Context, base, traceID string) error {
+	var body struct {
+		Batches       []json.RawMessage `json:"batches"`
+		ResourceSpans []json.RawMessage `json:"resourceSpans"`
+	}
+	if err := getJSON(ctx, base+"/api/traces/"+traceID, &body); err != nil {
+		return err
+	}
+	if len(body.Batches) == 0 && len(body.ResourceSpans) == 0 {
+		return fmt.Errorf("trace %s has no spans yet", traceID)
+	}
+	return nil
+}
+
+// queryPrometheus evaluates an instant query and returns the value of the
+// first sample in the result.
+func queryPrometheus(ctx context.Context, base, query string) (float64, error) {
+	var body struct {
+		Status string `json:"status"`
+		Data   struct {
+			Result []struct {
+				Value [2]any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, base+"/api/v1/query?query="+url.QueryEscape(query), &body); err != nil {
+		return 0, err
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("query %q returned status %q", query, body.Status)
+	}
+	if len(body.Data.Result) == 0 {
+		return 0, fmt.Errorf("query %q returned no samples", query)
+	}
+	s, ok := body.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("query %q returned a malformed sample", query)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", u, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", u, err)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"my-go-app/pkg/logging"
+)
+
+// usesCollector reports whether any signal is exported to the collector
+// over OTLP, rather than to Zipkin, a remote-write endpoint or Loki.
+func usesCollector() bool {
+	return tracesExporter == "otlp" || remoteWriteURL == "" || lokiPushURL == ""
+}
+
+// connectCollector starts connecting to the collector in the background,
+// so the service serves traffic while the collector is still starting.
+// Exports made before it is up fail as unavailable and are retried with
+// backoff by the exporters, within their timeout, so telemetry recorded
+// just before the collector is reachable is exported once it is. With a
+// positive timeout, startup instead waits up to timeout for the connection
+// and fails if it is not established.
+func connectCollector(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) error {
+	conn.Connect()
+	if timeout <= 0 {
+		go logCollectorConnected(ctx, conn)
+		return nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if !waitReady(waitCtx, conn) {
+		return fmt.Errorf("collector at %s not reachable within OTLP_STARTUP_TIMEOUT (%s), connection is %s",
+			conn.Target(), timeout, conn.GetState())
+	}
+	return nil
+}
+
+// waitReady blocks until conn is ready or ctx is done, reporting whether it
+// became ready.
+func waitReady(ctx context.Context, conn *grpc.ClientConn) bool {
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return true
+		case connectivity.Idle:
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return false
+		}
+	}
+}
+
+// logCollectorConnected logs when the connection is first established, so
+// the gap between startup and the first exports is visible.
+func logCollectorConnected(ctx context.Context, conn *grpc.ClientConn) {
+	start := time.Now()
+	if waitReady(ctx, conn) {
+		appLog.Info(ctx, "Connected to collector",
+			logging.String("otlp.endpoint", conn.Target()),
+			logging.Duration("wait", time.Since(start)),
+		)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the schema of CONFIG_FILE, a YAML (or JSON) description of
+// the telemetry stack. Each field stands in for the environment variable
+// named in its comment; a variable that is set always wins over the file,
+// so one deployment can share a file and still override single settings.
+type fileConfig struct {
+	Service struct {
+		Name string `yaml:"name"` // OTEL_SERVICE_NAME
+	} `yaml:"service"`
+	OTLP struct {
+		Endpoint string `yaml:"endpoint"` // OTEL_EXPORTER_OTLP_ENDPOINT
+		TLS      struct {
+			Insecure *bool  `yaml:"insecure"`  // OTEL_EXPORTER_OTLP_INSECURE
+			CAFile   string `yaml:"ca_file"`   // OTEL_EXPORTER_OTLP_CERTIFICATE
+			CertFile string `yaml:"cert_file"` // OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE
+			KeyFile  string `yaml:"key_file"`  // OTEL_EXPORTER_OTLP_CLIENT_KEY
+		} `yaml:"tls"`
+	} `yaml:"otlp"`
+	Traces struct {
+		Exporter       string `yaml:"exporter"`        // OTEL_TRACES_EXPORTER
+		ZipkinEndpoint string `yaml:"zipkin_endpoint"` // OTEL_EXPORTER_ZIPKIN_ENDPOINT
+	} `yaml:"traces"`
+	Sampling struct {
+		Ratio      *float64 `yaml:"ratio"`      // TRACE_SAMPLE_RATIO
+		Adaptive   *bool    `yaml:"adaptive"`   // ADAPTIVE_SAMPLING_ENABLED
+		Consistent *bool    `yaml:"consistent"` // CONSISTENT_SAMPLING_ENABLED
+		Tail       *bool    `yaml:"tail"`       // TAIL_SAMPLING_ENABLED
+	} `yaml:"sampling"`
+	Resource struct {
+		Attributes map[string]string `yaml:"attributes"` // OTEL_RESOURCE_ATTRIBUTES
+	} `yaml:"resource"`
+	Batch struct {
+		ScheduleDelay      string `yaml:"schedule_delay"`        // BATCH_SCHEDULE_DELAY
+		ExportTimeout      string `yaml:"export_timeout"`        // BATCH_EXPORT_TIMEOUT
+		MaxQueueSize       int    `yaml:"max_queue_size"`        // BATCH_MAX_QUEUE_SIZE
+		MaxExportBatchSize int    `yaml:"max_export_batch_size"` // BATCH_MAX_EXPORT_BATCH_SIZE
+	} `yaml:"batch"`
+	Metrics struct {
+		ExportInterval string `yaml:"export_interval"` // METRIC_EXPORT_INTERVAL
+	} `yaml:"metrics"`
+	SpanLimits struct {
+		AttributeValueLength   *int `yaml:"attribute_value_length"`    // OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT
+		AttributeCount         *int `yaml:"attribute_count"`           // OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT
+		EventCount             *int `yaml:"event_count"`               // OTEL_SPAN_EVENT_COUNT_LIMIT
+		LinkCount              *int `yaml:"link_count"`                // OTEL_SPAN_LINK_COUNT_LIMIT
+		AttributePerEventCount *int `yaml:"attribute_per_event_count"` // OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT
+		AttributePerLinkCount  *int `yaml:"attribute_per_link_count"`  // OTEL_LINK_ATTRIBUTE_COUNT_LIMIT
+	} `yaml:"span_limits"`
+	// Views replace the compiled-in metric views unless METRIC_VIEWS_FILE
+	// is set.
+	Views []viewConfig `yaml:"views"`
+	Logs  struct {
+		Level  string     `yaml:"level"`  // LOG_LEVEL
+		Routes []logRoute `yaml:"routes"` // LOG_ROUTES
+	} `yaml:"logs"`
+	// Routes adjust the middleware of groups of routes; they are
+	// applied at startup only.
+	Routes    []routePolicy `yaml:"routes"`
+	RateLimit struct {
+		Rate  *float64 `yaml:"rate"`  // RATE_LIMIT
+		Burst int      `yaml:"burst"` // RATE_LIMIT_BURST
+	} `yaml:"rate_limit"`
+	Redaction struct {
+		Enabled  *bool    `yaml:"enabled"`  // REDACTION_ENABLED
+		Keys     []string `yaml:"keys"`     // REDACT_KEYS
+		Patterns []string `yaml:"patterns"` // REDACT_PATTERNS
+	} `yaml:"redaction"`
+}
+
+// configFile is CONFIG_FILE as loaded at startup. It is read while the
+// package variables are initialized, so the settings below can fall back to
+// it; configFileErr is reported by run before anything starts.
+var (
+	configFilePath            = os.Getenv("CONFIG_FILE")
+	configFile, configFileErr = loadConfigFile(configFilePath)
+	configSettings            = configFile.settings()
+)
+
+// loadConfigFile reads and validates the config file at path. An empty path
+// yields an empty configuration.
+func loadConfigFile(path string) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return &fileConfig{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return &fileConfig{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate reports every invalid setting at once.
+func (c *fileConfig) validate() error {
+	var errs []error
+	if e := c.Traces.Exporter; e != "" && e != "otlp" && e != "zipkin" {
+		errs = append(errs, fmt.Errorf("traces.exporter must be otlp or zipkin, got %q", e))
+	}
+	if r := c.Sampling.Ratio; r != nil && (*r < 0 || *r > 1) {
+		errs = append(errs, fmt.Errorf("sampling.ratio must be between 0 and 1, got %g", *r))
+	}
+	if (c.OTLP.TLS.CertFile == "") != (c.OTLP.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("otlp.tls.cert_file and otlp.tls.key_file must be set together"))
+	}
+	for _, f := range []struct{ name, path string }{
+		{"otlp.tls.ca_file", c.OTLP.TLS.CAFile},
+		{"otlp.tls.cert_file", c.OTLP.TLS.CertFile},
+		{"otlp.tls.key_file", c.OTLP.TLS.KeyFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	for k, v := range c.Resource.Attributes {
+		if k == "" || strings.ContainsAny(k+v, ",=") {
+			errs = append(errs, fmt.Errorf("resource.attributes: %q=%q must be non-empty and contain neither ',' nor '='", k, v))
+		}
+	}
+	for _, d := range []struct{ name, value string }{
+		{"batch.schedule_delay", c.Batch.ScheduleDelay},
+		{"batch.export_timeout", c.Batch.ExportTimeout},
+		{"metrics.export_interval", c.Metrics.ExportInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive duration, got %q", d.name, d.value))
+		}
+	}
+	if r := c.RateLimit.Rate; r != nil && *r < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.rate must not be negative, got %g", *r))
+	}
+	if c.RateLimit.Burst < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.burst must not be negative, got %d", c.RateLimit.Burst))
+	}
+	if c.Batch.MaxQueueSize < 0 || c.Batch.MaxExportBatchSize < 0 {
+		errs = append(errs, errors.New("batch sizes must not be negative"))
+	}
+	if q, b := c.Batch.MaxQueueSize, c.Batch.MaxExportBatchSize; q > 0 && b > q {
+		errs = append(errs, fmt.Errorf("batch.max_export_batch_size (%d) must not exceed batch.max_queue_size (%d)", b, q))
+	}
+	if len(c.Views) > 0 {
+		if _, err := buildView(c.Views, "explicit"); err != nil {
+			errs = append(errs, fmt.Errorf("views: %w", err))
+		}
+	}
+	if c.Logs.Level != "" {
+		if _, err := parseSeverity(c.Logs.Level); err != nil {
+			errs = append(errs, fmt.Errorf("logs.level: %w", err))
+		}
+	}
+	for _, r := range c.Logs.Routes {
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("logs.routes: %w", err))
+		}
+	}
+	for _, p := range c.Routes {
+		if err := p.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("routes: %w", err))
+		}
+	}
+	for _, p := range c.Redaction.Patterns {
+		if strings.Contains(p, ";") {
+			errs = append(errs, fmt.Errorf("redaction.patterns: %q must not contain ';'", p))
+		} else if _, err := regexp.Compile(p); err != nil {
+			errs = append(errs, fmt.Errorf("redaction.patterns: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settings flattens the file into the environment variables it stands in
+// for. Unset fields are left out, so the compiled-in defaults apply.
+func (c *fileConfig) settings() map[string]string {
+	s := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			s[key] = value
+		}
+	}
+	setBool := func(key string, value *bool) {
+		if value != nil {
+			s[key] = strconv.FormatBool(*value)
+		}
+	}
+	setInt := func(key string, value int) {
+		if value != 0 {
+			s[key] = strconv.Itoa(value)
+		}
+	}
+	// For settings where 0 means something, such as a limit of none.
+	setIntPtr := func(key string, value *int) {
+		if value != nil {
+			s[key] = strconv.Itoa(*value)
+		}
+	}
+
+	set("OTEL_SERVICE_NAME", c.Service.Name)
+	set("OTEL_EXPORTER_OTLP_ENDPOINT", c.OTLP.Endpoint)
+	setBool("OTEL_EXPORTER_OTLP_INSECURE", c.OTLP.TLS.Insecure)
+	set("OTEL_EXPORTER_OTLP_CERTIFICATE", c.OTLP.TLS.CAFile)
+	set("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", c.OTLP.TLS.CertFile)
+	set("OTEL_EXPORTER_OTLP_CLIENT_KEY", c.OTLP.TLS.KeyFile)
+	set("OTEL_TRACES_EXPORTER", c.Traces.Exporter)
+	set("OTEL_EXPORTER_ZIPKIN_ENDPOINT", c.Traces.ZipkinEndpoint)
+	if r := c.Sampling.Ratio; r != nil {
+		s["TRACE_SAMPLE_RATIO"] = strconv.FormatFloat(*r, 'g', -1, 64)
+	}
+	setBool("ADAPTIVE_SAMPLING_ENABLED", c.Sampling.Adaptive)
+	setBool("CONSISTENT_SAMPLING_ENABLED", c.Sampling.Consistent)
+	setBool("TAIL_SAMPLING_ENABLED", c.Sampling.Tail)
+	var attrs []string
+	for _, k := range slices.Sorted(maps.Keys(c.Resource.Attributes)) {
+		attrs = append(attrs, k+"="+c.Resource.Attributes[k])
+	}
+	set("OTEL_RESOURCE_ATTRIBUTES", strings.Join(attrs, ","))
+	set("BATCH_SCHEDULE_DELAY", c.Batch.ScheduleDelay)
+	set("BATCH_EXPORT_TIMEOUT", c.Batch.ExportTimeout)
+	setInt("BATCH_MAX_QUEUE_SIZE", c.Batch.MaxQueueSize)
+	setInt("BATCH_MAX_EXPORT_BATCH_SIZE", c.Batch.MaxExportBatchSize)
+	set("METRIC_EXPORT_INTERVAL", c.Metrics.ExportInterval)
+	setIntPtr("OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", c.SpanLimits.AttributeValueLength)
+	setIntPtr("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", c.SpanLimits.AttributeCount)
+	setIntPtr("OTEL_SPAN_EVENT_COUNT_LIMIT", c.SpanLimits.EventCount)
+	setIntPtr("OTEL_SPAN_LINK_COUNT_LIMIT", c.SpanLimits.LinkCount)
+	setIntPtr("OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT", c.SpanLimits.AttributePerEventCount)
+	setIntPtr("OTEL_LINK_ATTRIBUTE_COUNT_LIMIT", c.SpanLimits.AttributePerLinkCount)
+	set("LOG_LEVEL", c.Logs.Level)
+	var routes []string
+	for _, r := range c.Logs.Routes {
+		routes = append(routes, r.String())
+	}
+	set("LOG_ROUTES", strings.Join(routes, ","))
+	if r := c.RateLimit.Rate; r != nil {
+		s["RATE_LIMIT"] = strconv.FormatFloat(*r, 'g', -1, 64)
+	}
+	setInt("RATE_LIMIT_BURST", c.RateLimit.Burst)
+	setBool("REDACTION_ENABLED", c.Redaction.Enabled)
+	set("REDACT_KEYS", strings.Join(c.Redaction.Keys, ","))
+	set("REDACT_PATTERNS", strings.Join(c.Redaction.Patterns, ";"))
+	return s
+}
+
+// Batch processor settings shared by the span and log pipelines. Zero
+// leaves the SDK default.
+var (
+	batchScheduleDelay      = envDuration("BATCH_SCHEDULE_DELAY", 0)
+	batchExportTimeout      = envDuration("BATCH_EXPORT_TIMEOUT", 0)
+	batchMaxQueueSize       = envInt("BATCH_MAX_QUEUE_SIZE", 0)
+	batchMaxExportBatchSize = envInt("BATCH_MAX_EXPORT_BATCH_SIZE", 0)
+)
+
+func batchSpanOptions() []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if batchScheduleDelay > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(batchScheduleDelay))
+	}
+	if batchExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(batchExportTimeout))
+	}
+	if batchMaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(batchMaxQueueSize))
+	}
+	if batchMaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(batchMaxExportBatchSize))
+	}
+	return opts
+}
+
+func batchLogOptions() []sdklog.BatchProcessorOption {
+	var opts []sdklog.BatchProcessorOption
+	if batchScheduleDelay > 0 {
+		opts = append(opts, sdklog.WithExportInterval(batchScheduleDelay))
+	}
+	if batchExportTimeout > 0 {
+		opts = append(opts, sdklog.WithExportTimeout(batchExportTimeout))
+	}
+	if batchMaxQueueSize > 0 {
+		opts = append(opts, sdklog.WithMaxQueueSize(batchMaxQueueSize))
+	}
+	if batchMaxExportBatchSize > 0 {
+		opts = append(opts, sdklog.WithExportMaxBatchSize(batchMaxExportBatchSize))
+	}
+	return opts
+}
+
+// otlpCredentials returns the transport credentials of the collector
+// connection: plaintext unless OTEL_EXPORTER_OTLP_INSECURE=false, in which
+// case TLS verified against OTEL_EXPORTER_OTLP_CERTIFICATE (or the system
+// roots), with an optional client certificate for mTLS.
+func otlpCredentials() (credentials.TransportCredentials, error) {
+	if otlpInsecure {
+		return insecure.NewCredentials(), nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := envString("OTEL_EXPORTER_OTLP_CERTIFICATE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	certFile := envString("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", "")
+	keyFile := envString("OTEL_EXPORTER_OTLP_CLIENT_KEY", "")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// parseResourceAttributes parses OTEL_RESOURCE_ATTRIBUTES, a list such as
+// "deployment.environment=prod,team=payments".
+func parseResourceAttributes(list string) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	for _, entry := range splitList(list) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES entry %q, want key=value", entry)
+		}
+		attrs = append(attrs, attribute.String(strings.TrimSpace(k), strings.TrimSpace(v)))
+	}
+	return attrs, nil
+}
+
+// parseHeaderList parses a list of request headers such as
+// "X-Scope-OrgID=payments,Authorization=Bearer token", read from the
+// environment variable env.
+func parseHeaderList(env, list string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range splitList(list) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, want name=value", env, entry)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otTraceStateKey is the tracestate vendor key reserved for OpenTelemetry.
+const otTraceStateKey = "ot"
+
+// pZero is the p-value meaning a sampling probability of zero.
+const pZero = 63
+
+// consistentSampler implements consistent probability sampling as described
+// in the OTel probability sampling specification. Every trace carries a
+// random r-value in tracestate; a span is sampled when its p-value, the
+// negative base-2 logarithm of the sampling probability, does not exceed r.
+// Because r is shared by the whole trace, samplers with different
+// probabilities make consistent decisions, and the recorded p-value lets
+// downstream tail samplers and span-to-metrics pipelines reweight spans by
+// their adjusted count 2^p.
+type consistentSampler struct {
+	probability float64
+	// pFloor and pCeil bracket -log2(probability). Non power-of-two
+	// probabilities are achieved by choosing pFloor with probability
+	// pFloorProb and pCeil otherwise.
+	pFloor, pCeil int
+	pFloorProb    float64
+}
+
+func newConsistentSampler(probability float64) *consistentSampler {
+	s := &consistentSampler{probability: probability}
+	switch {
+	case probability >= 1:
+		s.pFloor, s.pCeil, s.pFloorProb = 0, 0, 1
+	case probability <= 0 || probability < math.Pow(2, -(pZero-1)):
+		s.pFloor, s.pCeil, s.pFloorProb = pZero, pZero, 1
+	default:
+		exact := -math.Log2(probability)
+		s.pFloor = int(math.Floor(exact))
+		s.pCeil = int(math.Ceil(exact))
+		if s.pFloor == s.pCeil {
+			s.pFloorProb = 1
+		} else {
+			high, low := math.Pow(2, -float64(s.pFloor)), math.Pow(2, -float64(s.pCeil))
+			s.pFloorProb = (probability - low) / (high - low)
+		}
+	}
+	return s
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s *consistentSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	ts := trace.SpanContextFromContext(p.ParentContext).TraceState()
+	fields := parseOTTraceState(ts.Get(otTraceStateKey))
+
+	r, ok := fields.int("r")
+	if !ok || r > 62 {
+		// The 2 low bits are forced on so r never exceeds 62.
+		r = bits.LeadingZeros64(rand.Uint64() | 3)
+	}
+	pv := s.pCeil
+	if rand.Float64() < s.pFloorProb {
+		pv = s.pFloor
+	}
+
+	decision := sdktrace.Drop
+	fields.set("r", r)
+	if pv <= r && pv != pZero {
+		decision = sdktrace.RecordAndSample
+		fields.set("p", pv)
+	} else {
+		fields.del("p")
+	}
+
+	if next, err := ts.Insert(otTraceStateKey, fields.String()); err == nil {
+		ts = next
+	}
+	return sdktrace.SamplingResult{Decision: decision, Tracestate: ts}
+}
+
+// Description implements sdktrace.Sampler.
+func (s *consistentSampler) Description() string {
+	return fmt.Sprintf("ConsistentProbabilityBased{%g}", s.probability)
+}
+
+// otFields is the parsed value of the "ot" tracestate entry, a list of
+// key:value pairs separated by semicolons.
+type otFields []otField
+
+type otField struct{ key, value string }
+
+func parseOTTraceState(v string) otFields {
+	var fields otFields
+	for _, part := range strings.Split(v, ";") {
+		if k, val, ok := strings.Cut(part, ":"); ok && k != "" {
+			fields = append(fields, otField{key: k, value: val})
+		}
+	}
+	return fields
+}
+
+func (f otFields) int(key string) (int, bool) {
+	for _, field := range f {
+		if field.key == key {
+			n, err := strconv.Atoi(field.value)
+			return n, err == nil && n >= 0
+		}
+	}
+	return 0, false
+}
+
+func (f *otFields) set(key string, v int) {
+	for i := range *f {
+		if (*f)[i].key == key {
+			(*f)[i].value = strconv.Itoa(v)
+			return
+		}
+	}
+	*f = append(*f, otField{key: key, value: strconv.Itoa(v)})
+}
+
+func (f *otFields) del(key string) {
+	out := (*f)[:0]
+	for _, field := range *f {
+		if field.key != key {
+			out = append(out, field)
+		}
+	}
+	*f = out
+}
+
+func (f otFields) String() string {
+	parts := make([]string, len(f))
+	for i, field := range f {
+		parts[i] = field.key + ":" + field.value
+	}
+	return strings.Join(parts, ";")
+}
+
+var _ sdktrace.Sampler = (*consistentSampler)(nil)
+
+// newRatioSampler returns a sampler keeping the given fraction of traces,
+// using consistent probability sampling when consistent is set and the
+// SDK's trace id ratio sampler otherwise.
+func newRatioSampler(ratio float64, consistent bool) sdktrace.Sampler {
+	if consistent {
+		return newConsistentSampler(ratio)
+	}
+	return sdktrace.TraceIDRatioBased(ratio)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// consoleLogProcessor writes every record as one line to w, either human
+// readable ("text") or as a JSON object ("json"), so logs stay visible in
+// `kubectl logs` even when the collector is unreachable.
+type consoleLogProcessor struct {
+	mu     sync.Mutex
+	w      io.Writer
+	asJSON bool
+}
+
+func newConsoleLogProcessor(w io.Writer, format string) (*consoleLogProcessor, error) {
+	switch format {
+	case "text":
+		return &consoleLogProcessor{w: w}, nil
+	case "json":
+		return &consoleLogProcessor{w: w, asJSON: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown console log format %q, want text or json", format)
+	}
+}
+
+func (p *consoleLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	ts := r.Timestamp()
+	if ts.IsZero() {
+		ts = r.ObservedTimestamp()
+	}
+
+	var buf bytes.Buffer
+	if p.asJSON {
+		line := map[string]any{
+			"time":     ts.UTC().Format(time.RFC3339Nano),
+			"severity": r.Severity().String(),
+			"scope":    r.InstrumentationScope().Name,
+			"body":     logValueAny(r.Body()),
+		}
+		r.WalkAttributes(func(kv otellog.KeyValue) bool {
+			line[kv.Key] = logValueAny(kv.Value)
+			return true
+		})
+		if err := json.NewEncoder(&buf).Encode(line); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(&buf, "%s %-5s %s %s",
+			ts.UTC().Format(time.RFC3339Nano), r.Severity(), r.InstrumentationScope().Name, r.Body())
+		r.WalkAttributes(func(kv otellog.KeyValue) bool {
+			writeTextAttr(&buf, kv.Key, kv.Value)
+			return true
+		})
+		buf.WriteByte('\n')
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
+
+func (p *consoleLogProcessor) Shutdown(context.Context) error   { return nil }
+func (p *consoleLogProcessor) ForceFlush(context.Context) error { return nil }
+
+// writeTextAttr appends " key=value", flattening maps into dotted keys.
+func writeTextAttr(buf *bytes.Buffer, key string, v otellog.Value) {
+	if v.Kind() == otellog.KindMap {
+		for _, kv := range v.AsMap() {
+			writeTextAttr(buf, key+"."+kv.Key, kv.Value)
+		}
+		return
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(quoteIfNeeded(v.String()))
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logValueAny converts a log value into its JSON-encodable Go equivalent.
+func logValueAny(v otellog.Value) any {
+	switch v.Kind() {
+	case otellog.KindBool:
+		return v.AsBool()
+	case otellog.KindInt64:
+		return v.AsInt64()
+	case otellog.KindFloat64:
+		return v.AsFloat64()
+	case otellog.KindString:
+		return v.AsString()
+	case otellog.KindBytes:
+		return base64.StdEncoding.EncodeToString(v.AsBytes())
+	case otellog.KindSlice:
+		out := make([]any, 0, len(v.AsSlice()))
+		for _, e := range v.AsSlice() {
+			out = append(out, logValueAny(e))
+		}
+		return out
+	case otellog.KindMap:
+		out := make(map[string]any, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			out[kv.Key] = logValueAny(kv.Value)
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	runtimemetrics "runtime/metrics"
+
+	"my-go-app/pkg/metrics"
+)
+
+// mutexWaitMetric is the cumulative time goroutines spent blocked on a
+// sync.Mutex, sync.RWMutex or runtime-internal lock.
+const mutexWaitMetric = "/sync/mutex/wait/total:seconds"
+
+// registerContentionMetrics exports lock contention from runtime/metrics as
+// go.sync.mutex.wait. Its rate is the number of goroutines blocked on locks
+// on average, which can be lined up with tail latency in traces. The
+// scheduler latency distribution comes from the runtime producer registered
+// on the metric reader, as go.schedule.duration.
+func registerContentionMetrics(g *gaugeRegistry) error {
+	return g.Float64Counter(metrics.MutexWait,
+		func(context.Context) float64 {
+			sample := []runtimemetrics.Sample{{Name: mutexWaitMetric}}
+			runtimemetrics.Read(sample)
+			if sample[0].Value.Kind() != runtimemetrics.KindFloat64 {
+				return 0
+			}
+			return sample[0].Value.Float64()
+		},
+	)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// measureCPUTime runs fn with the calling goroutine locked to its OS thread
+// and returns the CPU time the thread consumed meanwhile. Work handed off to
+// other goroutines is not included. ok is false on platforms without
+// per-thread CPU accounting.
+func measureCPUTime(fn func()) (cpu time.Duration, ok bool) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	start, ok := threadCPUTime()
+	fn()
+	if !ok {
+		return 0, false
+	}
+	end, ok := threadCPUTime()
+	if !ok {
+		return 0, false
+	}
+	return end - start, true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// threadCPUTime is not supported outside Linux.
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
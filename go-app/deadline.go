@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/dependency"
+)
+
+// deadlineMiddleware bounds each request by timeout and reports the
+// resulting deadline in the X-Deadline response header (RFC 3339) and on
+// the request span, so callers and traces reveal mismatches between client
+// and server timeouts. A tighter deadline already on the context, or sent
+// by the caller in dependency.TimeoutHeader, wins. The deadline flows with
+// the context to downstream calls.
+//
+// A handler still running at the deadline is answered for: the client gets
+// a 504, the request span a timeout.exceeded event and an error status, and
+// whatever the handler writes afterwards is discarded. The response is
+// buffered until the handler returns or flushes it; once flushed, it streams
+// and can no longer be replaced, so the deadline only cancels the handler.
+// A request whose client went away is answered with 499, so it is not
+// measured as a 200. It must run inside otelhttp so the request span is
+// already started.
+func deadlineMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := timeout
+		if t, ok := callerTimeout(r); ok && t < timeout {
+			timeout = t
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		w.Header().Set("X-Deadline", deadline.UTC().Format(time.RFC3339Nano))
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(
+			attribute.String("http.server.deadline", deadline.UTC().Format(time.RFC3339Nano)),
+			attribute.Int64("http.server.timeout_ms", time.Until(deadline).Milliseconds()),
+		)
+
+		tw := &timeoutWriter{ctx: ctx, w: w, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		case <-ctx.Done():
+		}
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		// A handler that returned as ctx ended wrote nothing after it did,
+		// so the response is decided by ctx rather than by which came first.
+		switch ctx.Err() {
+		case nil:
+			if !tw.streaming {
+				tw.commit()
+			}
+		case context.DeadlineExceeded:
+			span.AddEvent("timeout.exceeded", trace.WithAttributes(
+				attribute.Int64("http.server.timeout_ms", timeout.Milliseconds()),
+			))
+			span.SetStatus(codes.Error, "request timed out after "+timeout.String())
+			if !tw.streaming {
+				http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+			}
+		default:
+			// The client is gone; record why nothing was sent.
+			if !tw.streaming {
+				w.WriteHeader(statusClientClosedRequest)
+			}
+		}
+	})
+}
+
+// callerTimeout returns the timeout the caller sent in
+// dependency.TimeoutHeader, if any.
+func callerTimeout(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(dependency.TimeoutHeader)
+	if v == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// statusClientClosedRequest is the status of requests whose client
+// disconnected before the response was ready, as nginx logs them.
+const statusClientClosedRequest = 499
+
+// timeoutWriter buffers a response until the handler returns, so it can
+// still be replaced by a 504 at the deadline. A handler that flushes
+// switches it to streaming to w.
+type timeoutWriter struct {
+	ctx context.Context // writes are discarded once it is done
+	w   http.ResponseWriter
+
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	code      int
+	streaming bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.ctx.Err() != nil {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.streaming {
+		return tw.w.Write(p)
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.ctx.Err() != nil || tw.streaming || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// Flush sends what is buffered and streams the rest of the response, for
+// handlers such as server-sent events.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	f, ok := tw.w.(http.Flusher)
+	if tw.ctx.Err() != nil || !ok {
+		return
+	}
+	if !tw.streaming {
+		tw.commit()
+		tw.streaming = true
+	}
+	f.Flush()
+}
+
+// commit writes the buffered header and body to w. tw.mu must be held.
+func (tw *timeoutWriter) commit() {
+	dst := tw.w.Header()
+	clear(dst)
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.code)
+	tw.w.Write(tw.body.Bytes())
+	tw.body.Reset()
+}
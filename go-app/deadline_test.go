@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlineMiddleware(t *testing.T) {
+	blockUntilDone := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		io.WriteString(w, "late")
+	})
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		timeout  time.Duration
+		cancel   bool // the client goes away
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "buffered",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "made")
+			}),
+			timeout:  time.Second,
+			wantCode: http.StatusCreated,
+			wantBody: "made",
+		},
+		{
+			name:     "timed out",
+			handler:  blockUntilDone,
+			timeout:  10 * time.Millisecond,
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: "Request timed out\n",
+		},
+		{
+			name:     "client gone",
+			handler:  blockUntilDone,
+			timeout:  time.Minute,
+			cancel:   true,
+			wantCode: statusClientClosedRequest,
+		},
+		{
+			// Flushed output is sent, so the deadline cannot replace it.
+			name: "streaming",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "event 1\n")
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				io.WriteString(w, "event 2\n")
+			}),
+			timeout:  10 * time.Millisecond,
+			wantCode: http.StatusOK,
+			wantBody: "event 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			deadlineMiddleware(tt.timeout, tt.handler).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode || rec.Body.String() != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"my-go-app/pkg/health"
+)
+
+// registerDependencies registers the health checks of the service's
+// integrations: the item store, the telemetry collector when any signal is
+// exported to it and, when DOWNSTREAM_HEALTH_URL is set, the downstream
+// HTTP service.
+func registerDependencies(ctx context.Context, deps *health.Registry) {
+	deps.Register("store", itemStore.Ping)
+	if collectorConn != nil {
+		collector := newCollectorMonitor(collectorConn, collectorReadyGrace)
+		go collector.watch(ctx)
+		deps.Register("collector", collector.Check)
+	}
+	if url := os.Getenv("DOWNSTREAM_HEALTH_URL"); url != "" {
+		// Not the instrumented downstream client: probe traffic should not
+		// produce client spans.
+		deps.Register("downstream", health.HTTP(http.DefaultClient, url))
+	}
+}
+
+// collectorMonitor tracks the gRPC connection to the collector. The
+// collector counts as unhealthy once the connection has not been
+// established for longer than grace, so a pod that cannot export its
+// telemetry stops receiving traffic, while brief reconnects and a slow
+// start do not flap readiness.
+type collectorMonitor struct {
+	conn  *grpc.ClientConn
+	grace time.Duration
+
+	mu        sync.Mutex
+	downSince time.Time // zero while the connection is ready
+}
+
+func newCollectorMonitor(conn *grpc.ClientConn, grace time.Duration) *collectorMonitor {
+	return &collectorMonitor{conn: conn, grace: grace, downSince: time.Now()}
+}
+
+// watch follows the connection's state until ctx is done.
+func (c *collectorMonitor) watch(ctx context.Context) {
+	for {
+		state := c.conn.GetState()
+		if state == connectivity.Idle {
+			// The exporters connect lazily; connect now so readiness does
+			// not wait for the first export.
+			c.conn.Connect()
+		}
+		c.mu.Lock()
+		switch {
+		case state == connectivity.Ready:
+			c.downSince = time.Time{}
+		case c.downSince.IsZero():
+			c.downSince = time.Now()
+		}
+		c.mu.Unlock()
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// Check fails when the connection has been down for longer than the grace
+// period.
+func (c *collectorMonitor) Check(context.Context) error {
+	c.mu.Lock()
+	downSince := c.downSince
+	c.mu.Unlock()
+	if !downSince.IsZero() && time.Since(downSince) > c.grace {
+		return fmt.Errorf("collector connection to %s is %s, not established for %s",
+			c.conn.Target(), c.conn.GetState(), time.Since(downSince).Round(time.Second))
+	}
+	return nil
+}
+
+// exportLivenessCheck fails when exports of some signal have failed without
+// interruption for longer than threshold.
+func exportLivenessCheck(pipeline *pipelineMetrics, threshold time.Duration) health.Check {
+	return func(context.Context) error {
+		if signal, d, ok := pipeline.failingLongest(); ok && d > threshold {
+			return fmt.Errorf("%s exports failing for %s", signal, d.Round(time.Second))
+		}
+		return nil
+	}
+}
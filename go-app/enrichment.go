@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// parseEnrichment parses "key=value,..." into attributes. Values may
+// reference environment variables ("region=${REGION}"); entries whose value
+// expands to empty are skipped so an unset variable adds no attribute.
+func parseEnrichment(config string) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	for _, entry := range splitList(config) {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid telemetry attribute %q, want key=value", entry)
+		}
+		if value = strings.TrimSpace(os.ExpandEnv(value)); value != "" {
+			attrs = append(attrs, attribute.String(key, value))
+		}
+	}
+	return attrs, nil
+}
+
+// enrichmentSpanProcessor sets a fixed set of attributes (region, cluster,
+// team, ...) on every span when it starts, so handlers don't have to.
+// Attributes set later by instrumentation take precedence.
+type enrichmentSpanProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+func (p enrichmentSpanProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+func (enrichmentSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (enrichmentSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (enrichmentSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// enrichmentLogProcessor adds the same attributes to every log record. It
+// must be registered before the exporting processor.
+type enrichmentLogProcessor struct {
+	attrs []otellog.KeyValue
+}
+
+func newEnrichmentLogProcessor(attrs []attribute.KeyValue) enrichmentLogProcessor {
+	kvs := make([]otellog.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		kvs[i] = otellog.String(string(kv.Key), kv.Value.AsString())
+	}
+	return enrichmentLogProcessor{kvs}
+}
+
+func (p enrichmentLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	r.AddAttributes(p.attrs...)
+	return nil
+}
+
+func (enrichmentLogProcessor) Shutdown(context.Context) error   { return nil }
+func (enrichmentLogProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// stderrLog logs what must not wait for, or depend on, the telemetry
+// pipeline: settings read before it starts, SDK errors and the error the
+// process exits with.
+var stderrLog = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// setStderrFormat switches stderrLog to JSON when LOG_CONSOLE_FORMAT is json,
+// so stderr parses like the console logs once the settings are read. With
+// text or off it stays text: what it logs must be written either way.
+func setStderrFormat(format string) {
+	if format == "json" {
+		stderrLog = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+}
+
+// envBool reads a boolean environment variable, falling back to def when the
+// variable is unset or cannot be parsed.
+func envBool(key string, def bool) bool {
+	v, ok := lookupSetting(key)
+	if !ok || v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		stderrLog.Warn("Invalid setting, using the default", "setting", key, "value", v, "default", def)
+		return def
+	}
+	return b
+}
+
+// envString reads an environment variable, falling back to def when the
+// variable is unset or empty.
+func envString(key, def string) string {
+	if v, _ := lookupSetting(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envStringDeprecated reads key like envString, falling back to oldKey,
+// which key replaces, with a warning.
+func envStringDeprecated(key, oldKey, def string) string {
+	if v, _ := lookupSetting(key); v != "" {
+		return v
+	}
+	if v, _ := lookupSetting(oldKey); v != "" {
+		stderrLog.Warn("Deprecated setting, use its replacement", "setting", oldKey, "replacement", key)
+		return v
+	}
+	return def
+}
+
+// envInt reads an integer environment variable, falling back to def when the
+// variable is unset or cannot be parsed.
+func envInt(key string, def int) int {
+	v, ok := lookupSetting(key)
+	if !ok || v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		stderrLog.Warn("Invalid setting, using the default", "setting", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a floating point environment variable, falling back to def
+// when the variable is unset or cannot be parsed.
+func envFloat(key string, def float64) float64 {
+	v, ok := lookupSetting(key)
+	if !ok || v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		stderrLog.Warn("Invalid setting, using the default", "setting", key, "value", v, "default", def)
+		return def
+	}
+	return f
+}
+
+// envDuration reads a duration environment variable (e.g. "500ms"), falling
+// back to def when the variable is unset or cannot be parsed.
+func envDuration(key string, def time.Duration) time.Duration {
+	v, ok := lookupSetting(key)
+	if !ok || v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		stderrLog.Warn("Invalid setting, using the default", "setting", key, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
+// envMillis reads an integer number of milliseconds, the unit of the
+// standard OTEL_* SDK variables, falling back to def when the variable is
+// unset or cannot be parsed.
+func envMillis(key string, def time.Duration) time.Duration {
+	v, ok := lookupSetting(key)
+	if !ok || v == "" {
+		return def
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		stderrLog.Warn("Invalid setting, using the default", "setting", key, "value", v, "default", def)
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// lookupSetting returns the environment variable key, or the value
+// CONFIG_FILE sets for it when the variable is not set.
+func lookupSetting(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	v, ok := configSettings[key]
+	return v, ok
+}
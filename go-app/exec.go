@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runTracedCommand runs name with args inside a span. The span context is
+// propagated to the child through the TRACEPARENT/TRACESTATE/BAGGAGE
+// environment variables, so instrumented subprocesses continue the trace.
+// It returns the command's stdout.
+func runTracedCommand(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "exec "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.ProcessExecutableName(name),
+			semconv.ProcessCommandArgs(append([]string{name}, args...)...),
+		),
+	)
+	defer span.End()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), traceEnv(ctx)...)
+
+	err := cmd.Run()
+
+	span.SetAttributes(attribute.Int("process.stderr.size", stderr.Len()))
+	if cmd.ProcessState != nil {
+		span.SetAttributes(attribute.Int("process.exit.code", cmd.ProcessState.ExitCode()))
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			err = errors.New(strings.TrimSpace(stderr.String()))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// traceEnv renders the propagated context of ctx as environment variables,
+// following the OTel convention of upper-casing the propagator keys.
+func traceEnv(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	env := make([]string, 0, len(carrier))
+	for k, v := range carrier {
+		env = append(env, strings.ToUpper(k)+"="+v)
+	}
+	return env
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// errorExemplarSlots is how many exemplars each series keeps per kind
+	// (failed and other measurements).
+	errorExemplarSlots = 4
+	// errorExemplarMaxAge is how long a failed measurement's exemplar is
+	// preferred over newer exemplars of successful ones.
+	errorExemplarMaxAge = 5 * time.Minute
+)
+
+// exemplarFilter decides which measurements are offered to exemplar
+// reservoirs. Normally only measurements within sampled spans are; with
+// tail sampling, the trace of a failed request is exported even when it was
+// not sampled, so every measurement within a span is offered and
+// sampledExemplarSelector gives an aggregation the SDK default reservoir,
+// wrapped in a sampledReservoir.
+func sampledExemplarSelector(agg sdkmetric.Aggregation) exemplar.ReservoirProvider {
+	provider := sdkmetric.DefaultExemplarReservoirProviderSelector(agg)
+	return func(series attribute.Set) exemplar.Reservoir {
+		return sampledReservoir{provider(series)}
+	}
+}
+
+// sampledReservoir only offers measurements within sampled spans to its
+// reservoir, like exemplar.TraceBasedFilter, so that the relaxed filter of
+// tail sampling does not give it exemplars of traces that are never
+// exported.
+type sampledReservoir struct {
+	exemplar.Reservoir
+}
+
+func (r sampledReservoir) Offer(ctx context.Context, t time.Time, val exemplar.Value, attr []attribute.KeyValue) {
+	if trace.SpanContextFromContext(ctx).IsSampled() {
+		r.Reservoir.Offer(ctx, t, val, attr)
+	}
+}
+
+// errorPreferringReservoir keeps the unsampled ones only if they failed.
+// Every other reservoir is a sampledReservoir (see sampledExemplarSelector),
+// which still only keeps measurements within sampled spans.
+func exemplarFilter(tailSampling bool) exemplar.Filter {
+	if !tailSampling {
+		return exemplar.TraceBasedFilter
+	}
+	return func(ctx context.Context) bool {
+		return trace.SpanContextFromContext(ctx).IsValid()
+	}
+}
+
+// errorPreferringSelector gives counters an errorPreferringReservoir and
+// leaves other aggregations to sampledExemplarSelector.
+func errorPreferringSelector(agg sdkmetric.Aggregation) exemplar.ReservoirProvider {
+	if _, ok := agg.(sdkmetric.AggregationSum); !ok {
+		return sampledExemplarSelector(agg)
+	}
+	return func(series attribute.Set) exemplar.Reservoir {
+		_, failed := series.Value(semconv.ErrorTypeKey)
+		return &errorPreferringReservoir{errorSeries: failed}
+	}
+}
+
+// errorPreferringReservoir keeps the latest exemplars of failed
+// measurements apart from the others and reports the failed ones while any
+// is recent, so an error-rate spike on a counter links to failing traces
+// rather than to whatever succeeded last. A measurement failed if its series
+// or filtered attributes carry error.type, or its span has an error status.
+type errorPreferringReservoir struct {
+	errorSeries bool
+
+	mu     sync.Mutex
+	failed []exemplar.Exemplar
+	other  []exemplar.Exemplar
+}
+
+func (r *errorPreferringReservoir) Offer(ctx context.Context, t time.Time, val exemplar.Value, attr []attribute.KeyValue) {
+	sc := trace.SpanContextFromContext(ctx)
+	failed := r.errorSeries || spanFailed(ctx) || slices.ContainsFunc(attr, func(kv attribute.KeyValue) bool {
+		return kv.Key == semconv.ErrorTypeKey
+	})
+	if !sc.IsSampled() && !failed {
+		return
+	}
+	e := exemplar.Exemplar{
+		FilteredAttributes: slices.Clone(attr),
+		Time:               t,
+		Value:              val,
+	}
+	if sc.IsValid() {
+		tid, sid := sc.TraceID(), sc.SpanID()
+		e.TraceID, e.SpanID = tid[:], sid[:]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if failed {
+		r.failed = pushExemplar(r.failed, e)
+	} else {
+		r.other = pushExemplar(r.other, e)
+	}
+}
+
+func (r *errorPreferringReservoir) Collect(dest *[]exemplar.Exemplar) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = slices.DeleteFunc(r.failed, func(e exemplar.Exemplar) bool {
+		return time.Since(e.Time) > errorExemplarMaxAge
+	})
+	held := r.other
+	if len(r.failed) > 0 {
+		held = r.failed
+	}
+	*dest = append((*dest)[:0], held...)
+}
+
+// pushExemplar appends e, dropping the oldest exemplar when full.
+func pushExemplar(held []exemplar.Exemplar, e exemplar.Exemplar) []exemplar.Exemplar {
+	if len(held) == errorExemplarSlots {
+		held = append(held[:0], held[1:]...)
+	}
+	return append(held, e)
+}
+
+// spanFailed reports whether the span in ctx has already been marked as
+// failed, e.g. by the panic recovery middleware.
+func spanFailed(ctx context.Context) bool {
+	s, ok := trace.SpanFromContext(ctx).(sdktrace.ReadOnlySpan)
+	return ok && s.Status().Code == codes.Error
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTailSamplingExemplars(t *testing.T) {
+	view, err := buildView(nil, "explicit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(view),
+		sdkmetric.WithExemplarFilter(exemplarFilter(true)),
+	)
+	meter := mp.Meter("test")
+	counter, err := meter.Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram, err := meter.Float64Histogram("duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A request that was not sampled up front and failed: tail sampling
+	// exports its trace, so only the counter links to it.
+	unsampled := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	failed := metric.WithAttributes(semconv.ErrorTypeKey.String("500"))
+	counter.Add(unsampled, 1, failed)
+	histogram.Record(unsampled, 0.1, failed)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	exemplars := map[string]int{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			exemplars[m.Name] = len(data.DataPoints[0].Exemplars)
+		case metricdata.Histogram[float64]:
+			exemplars[m.Name] = len(data.DataPoints[0].Exemplars)
+		}
+	}
+	if exemplars["requests"] != 1 {
+		t.Errorf("counter has %d exemplars, want the failed unsampled request", exemplars["requests"])
+	}
+	if exemplars["duration"] != 0 {
+		t.Errorf("histogram has %d exemplars, want none for an unsampled trace", exemplars["duration"])
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+)
+
+// experimentVariants are the implementations /experiment can route to. They
+// do the same two steps of simulated work differently, so their latency and
+// error rates can be compared from telemetry alone:
+//   - control runs the steps one after the other;
+//   - cached skips both on a cache hit (80% of requests);
+//   - parallel runs the steps concurrently but fails 3% of the time.
+var experimentVariants = map[string]func(context.Context) error{
+	"control":  experimentControl,
+	"cached":   experimentCached,
+	"parallel": experimentParallel,
+}
+
+// experiment routes /experiment requests across variants by weight.
+type experiment struct {
+	variants []string
+	weights  []int
+	total    int
+	duration metric.Float64Histogram
+}
+
+// newExperiment parses weights such as "control=50,cached=30,parallel=20".
+func newExperiment(weights string, duration metric.Float64Histogram) (*experiment, error) {
+	e := &experiment{duration: duration}
+	for _, entry := range splitList(weights) {
+		name, w, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if _, known := experimentVariants[name]; !ok || !known {
+			return nil, fmt.Errorf("invalid experiment weight %q, want <control|cached|parallel>=<weight>", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid experiment weight %q: weight must be a non-negative integer", entry)
+		}
+		e.variants = append(e.variants, name)
+		e.weights = append(e.weights, n)
+		e.total += n
+	}
+	if e.total == 0 {
+		return nil, fmt.Errorf("experiment weights %q select no variant", weights)
+	}
+	return e, nil
+}
+
+// pick returns a variant at random, in proportion to the weights.
+func (e *experiment) pick() string {
+	n := rand.Intn(e.total)
+	for i, w := range e.weights {
+		if n < w {
+			return e.variants[i]
+		}
+		n -= w
+	}
+	return e.variants[len(e.variants)-1]
+}
+
+// ServeHTTP runs the request through one variant, chosen by weight or
+// forced with ?variant=. The variant is recorded as experiment.variant on
+// the request span and on app.experiment.duration.
+func (e *experiment) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	variant := r.URL.Query().Get("variant")
+	if variant == "" {
+		variant = e.pick()
+	} else if _, ok := experimentVariants[variant]; !ok {
+		http.Error(w, "unknown variant", http.StatusBadRequest)
+		return
+	}
+	variantAttr := attribute.String("experiment.variant", variant)
+	trace.SpanFromContext(ctx).SetAttributes(variantAttr)
+	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/experiment")))
+
+	ctx, span := tracer.Start(ctx, "experiment."+variant, trace.WithAttributes(variantAttr))
+	start := time.Now()
+	err := experimentVariants[variant](ctx)
+	elapsed := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	e.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(
+		variantAttr,
+		attribute.Bool("success", err == nil),
+	))
+	w.Header().Set("X-Experiment-Variant", variant)
+	if err != nil {
+		appLog.Error(ctx, "Experiment variant failed", logging.String("experiment.variant", variant), logging.Err(err))
+		http.Error(w, "Experiment failed", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Experiment complete with variant %s\n", variant)
+}
+
+// experimentStep simulates one unit of work taking base plus up to jitter.
+func experimentStep(ctx context.Context, name string, base, jitter time.Duration) {
+	_, span := tracer.Start(ctx, name)
+	defer span.End()
+	time.Sleep(base + time.Duration(rand.Int63n(int64(jitter))))
+}
+
+func experimentControl(ctx context.Context) error {
+	experimentStep(ctx, "experiment.fetch", 80*time.Millisecond, 40*time.Millisecond)
+	experimentStep(ctx, "experiment.render", 60*time.Millisecond, 30*time.Millisecond)
+	return nil
+}
+
+func experimentCached(ctx context.Context) error {
+	hit := rand.Float64() < 0.8
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("experiment.cache_hit", hit))
+	if hit {
+		experimentStep(ctx, "experiment.cache_lookup", 10*time.Millisecond, 10*time.Millisecond)
+		return nil
+	}
+	return experimentControl(ctx)
+}
+
+func experimentParallel(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		experimentStep(ctx, "experiment.fetch", 80*time.Millisecond, 40*time.Millisecond)
+	}()
+	go func() {
+		defer wg.Done()
+		experimentStep(ctx, "experiment.render", 60*time.Millisecond, 30*time.Millisecond)
+	}()
+	wg.Wait()
+	if rand.Float64() < 0.03 {
+		return fmt.Errorf("parallel render raced with fetch")
+	}
+	return nil
+}
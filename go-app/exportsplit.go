@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isMessageTooLarge reports whether err is gRPC rejecting an export request
+// for exceeding the maximum message size, either locally
+// (OTLP_MAX_MESSAGE_SIZE) or by the collector. Other ResourceExhausted
+// errors, such as a collector shedding load, are not split.
+func isMessageTooLarge(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.ResourceExhausted && strings.Contains(s.Message(), "larger than max")
+}
+
+// exportSplit exports items with export and, when the request is too large,
+// halves it and exports each half the same way. A single item that is too
+// large on its own is dropped and counted. Errors of the halves are joined.
+func exportSplit[T any](ctx context.Context, m *pipelineMetrics, signal string, items []T, export func(context.Context, []T) error) error {
+	err := export(ctx, items)
+	if !isMessageTooLarge(err) {
+		return err
+	}
+	attrs := metric.WithAttributes(attribute.String("signal", signal))
+	if len(items) <= 1 {
+		m.exportOversized.Add(ctx, int64(len(items)), attrs)
+		return err
+	}
+	m.exportSplits.Add(ctx, 1, attrs)
+	half := len(items) / 2
+	return errors.Join(
+		exportSplit(ctx, m, signal, items[:half], export),
+		exportSplit(ctx, m, signal, items[half:], export),
+	)
+}
+
+// splittingSpanExporter resends batches of spans that exceed the maximum
+// message size in smaller parts instead of failing the whole batch.
+type splittingSpanExporter struct {
+	sdktrace.SpanExporter
+	metrics *pipelineMetrics
+}
+
+func (e splittingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return exportSplit(ctx, e.metrics, "traces", spans, e.SpanExporter.ExportSpans)
+}
+
+// splittingLogExporter resends batches of log records that exceed the
+// maximum message size in smaller parts, so one large log body only costs
+// itself rather than the batch it was exported with.
+type splittingLogExporter struct {
+	sdklog.Exporter
+	metrics *pipelineMetrics
+}
+
+func (e splittingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return exportSplit(ctx, e.metrics, "logs", records, e.Exporter.Export)
+}
+
+// splittingMetricExporter resends collections that exceed the maximum
+// message size in parts of whole metrics (a single metric's data points
+// are not split).
+type splittingMetricExporter struct {
+	sdkmetric.Exporter
+	metrics *pipelineMetrics
+}
+
+// scopedMetric is one metric with the scope it belongs to.
+type scopedMetric struct {
+	scope  int
+	metric metricdata.Metrics
+}
+
+func (e splittingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	var all []scopedMetric
+	for i, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			all = append(all, scopedMetric{i, m})
+		}
+	}
+	first := true
+	return exportSplit(ctx, e.metrics, "metrics", all, func(ctx context.Context, part []scopedMetric) error {
+		// The first attempt sends the collection as it is.
+		if first {
+			first = false
+			return e.Exporter.Export(ctx, rm)
+		}
+		return e.Exporter.Export(ctx, regroupMetrics(rm, part))
+	})
+}
+
+// regroupMetrics builds a ResourceMetrics of rm's resource holding part,
+// grouped back into their scopes.
+func regroupMetrics(rm *metricdata.ResourceMetrics, part []scopedMetric) *metricdata.ResourceMetrics {
+	out := &metricdata.ResourceMetrics{Resource: rm.Resource}
+	index := make(map[int]int)
+	for _, sm := range part {
+		i, ok := index[sm.scope]
+		if !ok {
+			i = len(out.ScopeMetrics)
+			index[sm.scope] = i
+			out.ScopeMetrics = append(out.ScopeMetrics, metricdata.ScopeMetrics{Scope: rm.ScopeMetrics[sm.scope].Scope})
+		}
+		out.ScopeMetrics[i].Metrics = append(out.ScopeMetrics[i].Metrics, sm.metric)
+	}
+	return out
+}
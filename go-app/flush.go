@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/telemetry"
+)
+
+// flushTimeout bounds an on-demand flush.
+const flushTimeout = 10 * time.Second
+
+// flushTelemetry exports everything recorded so far and logs the outcome.
+func flushTelemetry(ctx context.Context, trigger string) error {
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+	start := time.Now()
+	err := telemetry.Flush(ctx)
+	attrs := []logging.Attr{
+		logging.String("trigger", trigger),
+		logging.Duration("flush.duration_s", time.Since(start)),
+	}
+	if err != nil {
+		appLog.Error(ctx, "Telemetry flush failed", append(attrs, logging.Err(err))...)
+		return err
+	}
+	appLog.Info(ctx, "Telemetry flushed", attrs...)
+	return nil
+}
+
+// watchFlushSignal flushes telemetry on every flush signal (SIGUSR1 where
+// supported), e.g. `kill -USR1 <pid>` right before a pod is killed, until ctx
+// is done.
+func watchFlushSignal(ctx context.Context) {
+	if len(flushSignals) == 0 {
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, flushSignals...)
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigs:
+				flushTelemetry(context.WithoutCancel(ctx), sig.String())
+			}
+		}
+	}()
+}
+
+// flushHandler flushes telemetry on POST, e.g.
+// `curl -X POST localhost:8081/admin/flush`.
+func flushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := flushTelemetry(r.Context(), "admin:"+r.RemoteAddr); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// flushSignals is empty where SIGUSR1 does not exist; use /admin/flush.
+var flushSignals []os.Signal
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+
+	"my-go-app/pkg/metrics"
+)
+
+// gaugeRegistry registers observable instruments backed by plain callbacks,
+// so exporting a piece of in-process state (queue depth, pool size, ...)
+// takes a single call instead of hand-wiring instrument and callback.
+type gaugeRegistry struct {
+	meter metric.Meter
+}
+
+func newGaugeRegistry(meter metric.Meter) *gaugeRegistry {
+	return &gaugeRegistry{meter: meter}
+}
+
+// Int64Gauge exports the value returned by fn as an observable gauge.
+func (g *gaugeRegistry) Int64Gauge(d metrics.Definition, fn func(context.Context) int64, attrs ...attribute.KeyValue) error {
+	opt := metric.WithAttributes(attrs...)
+	_, err := d.Int64ObservableGauge(g.meter,
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(fn(ctx), opt)
+			return nil
+		}),
+	)
+	return err
+}
+
+// Float64Gauge exports the value returned by fn as an observable gauge.
+func (g *gaugeRegistry) Float64Gauge(d metrics.Definition, fn func(context.Context) float64, attrs ...attribute.KeyValue) error {
+	opt := metric.WithAttributes(attrs...)
+	_, err := d.Float64ObservableGauge(g.meter,
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			o.Observe(fn(ctx), opt)
+			return nil
+		}),
+	)
+	return err
+}
+
+// Int64Counter exports the monotonically increasing value returned by fn as
+// an observable counter.
+func (g *gaugeRegistry) Int64Counter(d metrics.Definition, fn func(context.Context) int64, attrs ...attribute.KeyValue) error {
+	opt := metric.WithAttributes(attrs...)
+	_, err := d.Int64ObservableCounter(g.meter,
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(fn(ctx), opt)
+			return nil
+		}),
+	)
+	return err
+}
+
+// Float64Counter exports the monotonically increasing value returned by fn
+// as an observable counter.
+func (g *gaugeRegistry) Float64Counter(d metrics.Definition, fn func(context.Context) float64, attrs ...attribute.KeyValue) error {
+	opt := metric.WithAttributes(attrs...)
+	_, err := d.Float64ObservableCounter(g.meter,
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			o.Observe(fn(ctx), opt)
+			return nil
+		}),
+	)
+	return err
+}
+
+// registerDefaultGauges exports process uptime, the goroutine count and the
+// connection pool of the downstream HTTP client.
+func registerDefaultGauges(g *gaugeRegistry, startTime time.Time, conns *connCounter) error {
+	if err := g.Float64Gauge(metrics.ProcessUptime,
+		func(context.Context) float64 { return time.Since(startTime).Seconds() },
+	); err != nil {
+		return err
+	}
+	if err := g.Int64Gauge(metrics.Goroutines,
+		func(context.Context) int64 { return int64(runtime.NumGoroutine()) },
+	); err != nil {
+		return err
+	}
+	if err := g.Int64Gauge(metrics.HTTPClientOpenConnections,
+		func(context.Context) int64 { return conns.activeConns() },
+		attribute.String("http.client.name", "downstream"),
+		semconv.HTTPConnectionStateActive,
+	); err != nil {
+		return err
+	}
+	if err := g.Int64Gauge(metrics.HTTPClientOpenConnections,
+		func(context.Context) int64 { return conns.open.Load() - conns.activeConns() },
+		attribute.String("http.client.name", "downstream"),
+		semconv.HTTPConnectionStateIdle,
+	); err != nil {
+		return err
+	}
+	return g.Int64Counter(metrics.HTTPClientDialedConnections,
+		func(context.Context) int64 { return conns.dialed.Load() },
+		attribute.String("http.client.name", "downstream"),
+	)
+}
+
+// connCounter tracks the connections created by a dialer, and how many of
+// them are serving a request (see httpClientMetrics).
+type connCounter struct {
+	open   atomic.Int64
+	dialed atomic.Int64
+	active atomic.Int64
+}
+
+// activeConns is the number of open connections serving a request. A
+// connection closed while still marked active is not counted twice.
+func (c *connCounter) activeConns() int64 {
+	return min(c.active.Load(), c.open.Load())
+}
+
+// wrapDialer returns a DialContext function that counts the connections
+// created by dial.
+func (c *connCounter) wrapDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.dialed.Add(1)
+		c.open.Add(1)
+		return &countedConn{Conn: conn, counter: c}, nil
+	}
+}
+
+// countedConn decrements the open connection count once when closed.
+type countedConn struct {
+	net.Conn
+	counter *connCounter
+	closed  atomic.Bool
+}
+
+func (c *countedConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.counter.open.Add(-1)
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"my-go-app/pkg/dependency"
+	"my-go-app/pkg/logging"
+	demov1 "my-go-app/proto/demo/v1"
+)
+
+// newDownstreamGRPCConn connects lazily to the downstream Demo gRPC service.
+// otelgrpc's client stats handler starts a client span for every call and
+// injects its trace context and baggage into the outgoing metadata, so the
+// server's spans join the caller's trace, as with the instrumented HTTP
+// client. Calls follow the "downstream-grpc" dependency policy.
+func newDownstreamGRPCConn(addr string, policy dependency.Policy) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(dependency.UnaryClientInterceptor(policy)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection to %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// Endpoint that calls the downstream gRPC service, so one trace spans an
+// HTTP request, a gRPC call and the downstream service's work. Without
+// DOWNSTREAM_GRPC_ADDR there is nothing to call, and it answers 404.
+func downstreamGRPCHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/downstream-grpc")))
+	if downstreamDemoClient == nil {
+		http.Error(w, "No downstream gRPC service is configured", http.StatusNotFound)
+		return
+	}
+	appLog.Info(ctx, "Calling downstream gRPC service")
+
+	res, err := downstreamDemoClient.Hello(ctx, &demov1.HelloRequest{Name: r.URL.Query().Get("name")})
+	if err != nil {
+		http.Error(w, "Failed to call downstream gRPC service", http.StatusBadGateway)
+		appLog.Error(ctx, "Downstream gRPC call failed",
+			logging.Err(err),
+			logging.String("rpc.grpc.status_code", status.Code(err).String()),
+		)
+		return
+	}
+	fmt.Fprintln(w, res.GetMessage())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthgrpc "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+
+	"my-go-app/pkg/logging"
+	demov1 "my-go-app/proto/demo/v1"
+)
+
+// grpcServer serves the Demo service next to the HTTP server. Requests are
+// traced and measured by otelgrpc's stats handler (rpc.server.duration and
+// friends), and trace context and baggage are taken from the incoming
+// metadata with the global propagator, as otelhttp does for headers.
+type grpcServer struct {
+	server *grpc.Server
+	health *healthgrpc.Server
+}
+
+func newGRPCServer() *grpcServer {
+	s := &grpcServer{
+		server: grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler(
+				// Health checks would otherwise trace every probe.
+				otelgrpc.WithFilter(func(info *stats.RPCTagInfo) bool {
+					return info.FullMethodName != healthpb.Health_Check_FullMethodName
+				}),
+			)),
+			grpc.ChainUnaryInterceptor(grpcRecoveryInterceptor),
+		),
+		health: healthgrpc.NewServer(),
+	}
+	demov1.RegisterDemoServer(s.server, demoServer{})
+	healthpb.RegisterHealthServer(s.server, s.health)
+	return s
+}
+
+// Shutdown reports NOT_SERVING to health checks and waits for in-flight
+// RPCs to finish; those still running when ctx is done are cancelled.
+func (s *grpcServer) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// grpcRecoveryInterceptor turns a handler panic into an Internal error
+// instead of a crashed process, recording it on the RPC span and in the log
+// like recoveryMiddleware.
+func grpcRecoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		stack := string(debug.Stack())
+		msg := fmt.Sprint(rec)
+
+		span := trace.SpanFromContext(ctx)
+		span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+			semconv.ExceptionType(fmt.Sprintf("%T", rec)),
+			semconv.ExceptionMessage(msg),
+			semconv.ExceptionStacktrace(stack),
+		))
+		span.SetStatus(otelcodes.Error, "panic: "+msg)
+		appLog.Error(ctx, "Recovered from RPC panic",
+			logging.String("panic", msg),
+			logging.String("exception.stacktrace", stack),
+			logging.String("rpc.method", info.FullMethod),
+		)
+		err = status.Error(codes.Internal, "internal error")
+	}()
+	return handler(ctx, req)
+}
+
+// demoServer implements the Demo service, the gRPC counterpart of /hello
+// and /work.
+type demoServer struct {
+	demov1.UnimplementedDemoServer
+}
+
+func (demoServer) Hello(ctx context.Context, req *demov1.HelloRequest) (*demov1.HelloResponse, error) {
+	_, span := hotOps.Start(ctx, "Demo.Hello.work")
+	defer span.End()
+
+	appLog.Info(ctx, "Received Hello RPC")
+
+	time.Sleep(50 * time.Millisecond)
+	span.AddEvent("Finished sleeping")
+
+	name := req.GetName()
+	if name == "" {
+		name = "OpenTelemetry"
+	}
+	return &demov1.HelloResponse{Message: "Hello, " + name + "!"}, nil
+}
+
+func (demoServer) Work(ctx context.Context, _ *demov1.WorkRequest) (*demov1.WorkResponse, error) {
+	startTime := time.Now()
+
+	ctx, span := tracer.Start(ctx, "Demo.Work.mainOperation")
+	defer span.End()
+
+	appLog.Info(ctx, "Starting complex work")
+
+	time.Sleep(time.Duration(75+rand.Intn(50)) * time.Millisecond)
+	span.AddEvent("Initial processing complete")
+
+	appLog.Info(ctx, "Calling downstream service")
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/downstream", nil)
+	res, err := downstreamAPIHTTPClient.Do(req)
+	if err != nil {
+		appLog.Error(ctx, "Downstream call failed", logging.Err(err))
+		return nil, status.Error(codes.Unavailable, "failed to call downstream service")
+	}
+	res.Body.Close()
+	span.SetAttributes(attribute.Int("downstream.status_code", res.StatusCode))
+
+	time.Sleep(time.Duration(50+rand.Intn(25)) * time.Millisecond)
+	span.AddEvent("Final processing complete")
+
+	duration := time.Since(startTime).Seconds()
+	workDurationHistogram.Record(ctx, duration, metric.WithAttributes(attribute.Bool("success", true)))
+
+	appLog.Info(ctx, "Complex work finished")
+	return &demov1.WorkResponse{
+		DownstreamStatusCode: int32(res.StatusCode),
+		DurationSeconds:      duration,
+	}, nil
+}
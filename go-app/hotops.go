@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"my-go-app/pkg/metrics"
+)
+
+// hotOperations replaces per-call spans of very hot internal operations
+// (cache gets and the like) with metrics. An operation configured with N
+// starts a real span for only one call in N; every call, traced or not, is
+// counted and timed in app.operation.calls and app.operation.duration, so the
+// occasional span serves as an exemplar. Operations that are not configured
+// get a span per call as usual.
+type hotOperations struct {
+	tracer   trace.Tracer
+	every    map[string]uint64
+	seen     map[string]*atomic.Uint64
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newHotOperations(tracer trace.Tracer, meter metric.Meter, every map[string]uint64) (*hotOperations, error) {
+	ops := &hotOperations{
+		tracer: tracer,
+		every:  every,
+		seen:   make(map[string]*atomic.Uint64, len(every)),
+	}
+	for name := range every {
+		ops.seen[name] = new(atomic.Uint64)
+	}
+
+	var err error
+	ops.calls, err = metrics.OperationCalls.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	ops.duration, err = metrics.OperationDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// Start begins the operation name. It behaves like tracer.Start, except that
+// for hot operations most calls return ctx unchanged and a span that only
+// records metrics when it ends.
+func (o *hotOperations) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	every, hot := o.every[name]
+	if !hot {
+		return o.tracer.Start(ctx, name, opts...)
+	}
+
+	s := &aggregatedSpan{ops: o, name: name, start: time.Now()}
+	if o.seen[name].Add(1)%every == 1 || every == 1 {
+		ctx, s.Span = o.tracer.Start(ctx, name, opts...)
+	} else {
+		s.Span = noop.Span{}
+	}
+	s.ctx = ctx
+	return ctx, s
+}
+
+// aggregatedSpan records the duration and status of a hot operation on End,
+// forwarding everything else to the real span (or a no-op one).
+type aggregatedSpan struct {
+	trace.Span
+	ops    *hotOperations
+	ctx    context.Context
+	name   string
+	start  time.Time
+	status codes.Code
+}
+
+func (s *aggregatedSpan) SetStatus(code codes.Code, description string) {
+	s.status = code
+	s.Span.SetStatus(code, description)
+}
+
+func (s *aggregatedSpan) End(opts ...trace.SpanEndOption) {
+	s.Span.End(opts...)
+
+	// Recording in the span's context lets traced calls become exemplars.
+	opt := metric.WithAttributes(
+		attribute.String("operation", s.name),
+		attribute.String("status.code", s.status.String()),
+	)
+	s.ops.calls.Add(s.ctx, 1, opt)
+	s.ops.duration.Record(s.ctx, time.Since(s.start).Seconds(), opt)
+}
+
+// parseHotOperations parses a comma-separated list of name=N pairs, where one
+// call in N of operation name is traced (e.g. "helloHandler.work=100").
+func parseHotOperations(list string) (map[string]uint64, error) {
+	every := make(map[string]uint64)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, n, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid hot operation %q, want name=N", entry)
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64)
+		if err != nil || v == 0 {
+			return nil, fmt.Errorf("invalid trace interval %q for hot operation %q", n, name)
+		}
+		every[strings.TrimSpace(name)] = v
+	}
+	return every, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"my-go-app/pkg/metrics"
+)
+
+// httpClientMetrics complements the duration and request size otelhttp
+// records for outgoing requests with the response size, the transport's
+// own retries and which connections are busy. Wrap it with
+// otelhttp.NewTransport so measurements carry the client span's exemplars.
+type httpClientMetrics struct {
+	next         http.RoundTripper
+	conns        *connCounter
+	responseSize metric.Int64Histogram
+	retries      metric.Int64Counter
+}
+
+func newHTTPClientMetrics(meter metric.Meter, conns *connCounter, next http.RoundTripper) (*httpClientMetrics, error) {
+	responseSize, err := metrics.HTTPClientResponseBodySize.Int64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := metrics.HTTPClientRetries.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &httpClientMetrics{next: next, conns: conns, responseSize: responseSize, retries: retries}, nil
+}
+
+// RoundTrip counts the connection as active from the moment the transport
+// hands it to the request until the response body is closed or read to the
+// end. A second connection for the same request means the transport retried
+// it after the first, reused one failed.
+func (m *httpClientMetrics) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(normalizeMethod(req.Method)),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+
+	var held atomic.Bool
+	release := sync.OnceFunc(func() {
+		if held.Load() {
+			m.conns.active.Add(-1)
+		}
+	})
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			if held.Swap(true) {
+				m.retries.Add(ctx, 1, metric.WithAttributes(attrs...))
+				return
+			}
+			m.conns.active.Add(1)
+		},
+	})
+
+	res, err := m.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	attrs = append(attrs, semconv.HTTPResponseStatusCode(res.StatusCode))
+	var once sync.Once
+	res.Body = &measuredBody{ReadCloser: res.Body, length: res.ContentLength, done: func(size int64) {
+		once.Do(func() {
+			release()
+			m.responseSize.Record(ctx, size, metric.WithAttributes(attrs...))
+		})
+	}}
+	return res, nil
+}
+
+// measuredBody reports the body's size to done once it is read to the end
+// or closed: the declared Content-Length, or the bytes read when the length
+// was unknown.
+type measuredBody struct {
+	io.ReadCloser
+	length int64
+	read   int64
+	done   func(size int64)
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *measuredBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *measuredBody) finish() {
+	size := b.length
+	if size < 0 {
+		size = b.read
+	}
+	b.done(size)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedHTTPClients are the HTTP clients HTTP_CLIENT_TRACE can list.
+var tracedHTTPClients = []string{"downstream", "mirror"}
+
+// checkClientTrace reports a name in list, the value of HTTP_CLIENT_TRACE,
+// that is not one of tracedHTTPClients.
+func checkClientTrace(list string) error {
+	for _, name := range splitList(list) {
+		if !slices.Contains(tracedHTTPClients, name) {
+			return fmt.Errorf("invalid HTTP_CLIENT_TRACE client %q, want %s", name, strings.Join(tracedHTTPClients, " or "))
+		}
+	}
+	return nil
+}
+
+// clientTraceOptions returns the otelhttp options of the HTTP client name
+// ("downstream", "mirror"): when the client is listed in HTTP_CLIENT_TRACE,
+// each request's client span gets child spans for connection setup
+// (http.getconn with http.dns, http.connect and http.tls below it) and for
+// sending and receiving (http.send, http.receive), plus the time to first
+// byte as an attribute. Headers are not recorded.
+func clientTraceOptions(name string) []otelhttp.Option {
+	if !slices.Contains(splitList(httpClientTrace), name) {
+		return nil
+	}
+	return []otelhttp.Option{otelhttp.WithClientTrace(newClientTrace)}
+}
+
+// newClientTrace returns the otelhttptrace hooks for the client span in
+// ctx, recording on it http.client.time_to_first_byte_ms: the time from the
+// request being written to the first response byte, i.e. the server's
+// processing time plus a round trip.
+func newClientTrace(ctx context.Context) *httptrace.ClientTrace {
+	ct := otelhttptrace.NewClientTrace(ctx, otelhttptrace.WithoutHeaders())
+	// The hooks run on the transport's write and read goroutines.
+	var wrote atomic.Int64
+	wroteRequest, gotFirstByte := ct.WroteRequest, ct.GotFirstResponseByte
+	ct.WroteRequest = func(info httptrace.WroteRequestInfo) {
+		wrote.Store(time.Now().UnixNano())
+		wroteRequest(info)
+	}
+	ct.GotFirstResponseByte = func() {
+		if at := wrote.Load(); at != 0 {
+			ttfb := time.Since(time.Unix(0, at))
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("http.client.time_to_first_byte_ms",
+				float64(ttfb.Microseconds())/1000))
+		}
+		gotFirstByte()
+	}
+	return ct
+}
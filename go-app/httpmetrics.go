@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// httpServerMetrics records RED (rate, errors, duration) metrics for every
+// request served by the wrapped handler, using the OTel HTTP semantic
+// convention metric and attribute names.
+type httpServerMetrics struct {
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+	errors       metric.Int64Counter
+	// unsampledDuration keeps latency data for requests whose traces were
+	// sampled out, complementing the sampled traces.
+	unsampledDuration metric.Float64Histogram
+	// cpuTime is nil unless per-request CPU accounting is enabled.
+	cpuTime metric.Float64Histogram
+}
+
+func newHTTPServerMetrics(meter metric.Meter, measureCPU bool) (*httpServerMetrics, error) {
+	var (
+		m   httpServerMetrics
+		err error
+	)
+	m.duration, err = metrics.HTTPServerRequestDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.requestSize, err = metrics.HTTPServerRequestBodySize.Int64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.responseSize, err = metrics.HTTPServerResponseBodySize.Int64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.errors, err = metrics.HTTPServerErrors.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.unsampledDuration, err = metrics.HTTPServerUnsampledDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	if measureCPU {
+		m.cpuTime, err = metrics.HTTPServerRequestCPUTime.Float64Histogram(meter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// Middleware wraps next so each request is measured. The route is read from
+// the ServeMux pattern of the request, so it must wrap handlers registered on
+// the mux rather than the mux itself. Measurements are recorded with the
+// request context, which carries exemplars when a span is active.
+func (m *httpServerMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var (
+			cpu   time.Duration
+			cpuOK bool
+		)
+		stats := httpsnoop.CaptureMetricsFn(w, func(ww http.ResponseWriter) {
+			if m.cpuTime == nil {
+				next.ServeHTTP(ww, r)
+				return
+			}
+			cpu, cpuOK = measureCPUTime(func() { next.ServeHTTP(ww, r) })
+		})
+
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(normalizeMethod(r.Method)),
+			semconv.HTTPResponseStatusCode(stats.Code),
+		}
+		if r.Pattern != "" {
+			attrs = append(attrs, semconv.HTTPRoute(r.Pattern))
+		}
+		if stats.Code >= http.StatusInternalServerError {
+			attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(stats.Code)))
+		}
+		opt := metric.WithAttributes(attrs...)
+
+		m.duration.Record(ctx, stats.Duration.Seconds(), opt)
+		if r.ContentLength >= 0 {
+			m.requestSize.Record(ctx, r.ContentLength, opt)
+		}
+		m.responseSize.Record(ctx, stats.Written, opt)
+		if stats.Code >= http.StatusInternalServerError {
+			m.errors.Add(ctx, 1, opt)
+		}
+		if cpuOK {
+			m.cpuTime.Record(ctx, cpu.Seconds(), opt)
+		}
+		if !trace.SpanContextFromContext(ctx).IsSampled() {
+			// Only route and status, to keep the fallback cheap.
+			m.unsampledDuration.Record(ctx, stats.Duration.Seconds(), metric.WithAttributes(
+				semconv.HTTPRoute(r.Pattern),
+				semconv.HTTPResponseStatusCode(stats.Code),
+			))
+		}
+	})
+}
+
+// normalizeMethod maps non-standard methods to "_OTHER" as required by the
+// semantic conventions, keeping the attribute's cardinality bounded.
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "_OTHER"
+}
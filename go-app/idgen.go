@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newIDGenerator returns the trace and span ID generator selected by
+// TRACE_ID_GENERATOR. A nil generator keeps the SDK's random IDs.
+func newIDGenerator(name string) (sdktrace.IDGenerator, error) {
+	switch name {
+	case "random":
+		return nil, nil
+	case "xray":
+		// X-Ray rejects trace IDs whose first 4 bytes are not the epoch
+		// seconds of the trace's start.
+		return xray.NewIDGenerator(), nil
+	case "sortable":
+		return sortableIDGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown trace ID generator %q, want random, xray or sortable", name)
+	}
+}
+
+// sortableIDGenerator prefixes trace IDs with the start time in Unix
+// milliseconds (6 bytes, big-endian) followed by 10 random bytes, so trace
+// IDs sort by creation time, like ULIDs, in backends that store them as
+// keys. Span IDs are random.
+type sortableIDGenerator struct{}
+
+func (sortableIDGenerator) NewIDs(context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(tid[:6], ms[2:])
+	binary.BigEndian.PutUint16(tid[6:8], uint16(rand.Uint32()))
+	binary.BigEndian.PutUint64(tid[8:], rand.Uint64())
+	return tid, newSpanID()
+}
+
+func (sortableIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		binary.BigEndian.PutUint64(sid[:], rand.Uint64())
+	}
+	return sid
+}
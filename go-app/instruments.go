@@ -0,0 +1,14 @@
+package main
+
+import "my-go-app/pkg/metrics"
+
+// defaultMetricDefinitions are the catalog entries the registry serves.
+// Entries in METRIC_DEFINITIONS_FILE override these by name.
+var defaultMetricDefinitions = []metrics.Definition{
+	metrics.HTTPServerRequests,
+	metrics.HTTPServerActiveRequests,
+	metrics.HTTPServerLastRequestTimestamp,
+	metrics.HTTPServerPanics,
+	metrics.WorkDuration,
+	metrics.ExperimentDuration,
+}
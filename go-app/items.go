@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/store"
+)
+
+// itemsHandler serves GET, PUT and DELETE on /items/{key} from itemStore.
+func itemsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := r.PathValue("key")
+
+	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/items/{key}")))
+
+	switch r.Method {
+	case http.MethodGet:
+		v, err := itemStore.Get(ctx, key)
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to read item", http.StatusInternalServerError)
+			appLog.Error(ctx, "Store read failed", logging.String("item.key", key), logging.Err(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+
+	case http.MethodPut:
+		v, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := itemStore.Put(ctx, key, v); err != nil {
+			http.Error(w, "Failed to write item", http.StatusInternalServerError)
+			appLog.Error(ctx, "Store write failed", logging.String("item.key", key), logging.Err(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		err := itemStore.Delete(ctx, key)
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to delete item", http.StatusInternalServerError)
+			appLog.Error(ctx, "Store delete failed", logging.String("item.key", key), logging.Err(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
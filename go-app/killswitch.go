@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+)
+
+// killSwitches disables individual endpoints at runtime: requests to a
+// disabled route get a 503 with a maintenance message. Switch state is
+// exported as the app.endpoint.enabled gauge and every flip is recorded as
+// an audit log record.
+type killSwitches struct {
+	mu       sync.RWMutex
+	routes   []string
+	disabled map[string]bool
+}
+
+// newKillSwitches starts with the routes in disabled (a comma-separated
+// list such as "/work,/convert") switched off.
+func newKillSwitches(meter metric.Meter, disabled string) (*killSwitches, error) {
+	k := &killSwitches{disabled: make(map[string]bool)}
+	for _, route := range strings.Split(disabled, ",") {
+		if route = strings.TrimSpace(route); route != "" {
+			k.disabled[route] = true
+		}
+	}
+
+	_, err := metrics.EndpointEnabled.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			k.mu.RLock()
+			defer k.mu.RUnlock()
+			for _, route := range k.routes {
+				v := int64(1)
+				if k.disabled[route] {
+					v = 0
+				}
+				o.Observe(v, metric.WithAttributes(attribute.String("http.route", route)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Register adds route to the switches that can be flipped and exported.
+func (k *killSwitches) Register(route string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !slices.Contains(k.routes, route) {
+		k.routes = append(k.routes, route)
+	}
+}
+
+// Enabled reports whether route is switched on.
+func (k *killSwitches) Enabled(route string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return !k.disabled[route]
+}
+
+// Set flips the switch of a registered route and records who did it.
+func (k *killSwitches) Set(ctx context.Context, route string, enabled bool, actor string) error {
+	k.mu.Lock()
+	if !slices.Contains(k.routes, route) {
+		k.mu.Unlock()
+		return fmt.Errorf("unknown route %q", route)
+	}
+	was := !k.disabled[route]
+	if enabled {
+		delete(k.disabled, route)
+	} else {
+		k.disabled[route] = true
+	}
+	k.mu.Unlock()
+
+	appLog.Warn(ctx, "Endpoint kill switch flipped",
+		logging.Bool("audit", true),
+		logging.String("http.route", route),
+		logging.Bool("enabled", enabled),
+		logging.Bool("previously_enabled", was),
+		logging.String("actor", actor),
+	)
+	return nil
+}
+
+// Middleware answers requests to disabled routes with 503. It must run
+// inside otelhttp and the HTTP metrics middleware so rejected requests are
+// still traced and measured.
+func (k *killSwitches) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !k.Enabled(r.Pattern) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "This endpoint is temporarily disabled for maintenance.", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP lists the switches on GET and flips one on PUT or POST, e.g.
+// `curl -X PUT 'localhost:8081/admin/endpoints?route=/work&enabled=false'`.
+func (k *killSwitches) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		if err := k.Set(r.Context(), r.URL.Query().Get("route"), enabled, r.RemoteAddr); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	k.mu.RLock()
+	state := make(map[string]bool, len(k.routes))
+	for _, route := range k.routes {
+		state[route] = !k.disabled[route]
+	}
+	k.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
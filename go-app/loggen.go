@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/telemetry"
+)
+
+// severityWeight is one entry of the severity distribution used by loggen.
+type severityWeight struct {
+	name     string
+	severity otellog.Severity
+	weight   int
+}
+
+// loggenConfig controls the rate and severity distribution of the records
+// emitted by the loggen subcommand.
+type loggenConfig struct {
+	rate     float64
+	records  int
+	duration time.Duration
+	mix      []severityWeight
+	total    int
+}
+
+func parseLoggenFlags(args []string) (loggenConfig, error) {
+	var (
+		cfg loggenConfig
+		mix string
+	)
+	fs := flag.NewFlagSet("loggen", flag.ContinueOnError)
+	fs.Float64Var(&cfg.rate, "rate", 100, "log records emitted per second")
+	fs.IntVar(&cfg.records, "records", 0, "number of records to emit (0 means no limit)")
+	fs.DurationVar(&cfg.duration, "duration", 0, "how long to run (0 runs until interrupted or -records is reached)")
+	fs.StringVar(&mix, "mix", "debug=10,info=70,warn=15,error=5", "relative weights of each severity")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case !(cfg.rate > 0 && cfg.rate <= maxGenRate):
+		return cfg, fmt.Errorf("-rate must be positive and at most %g", maxGenRate)
+	case cfg.records < 0:
+		return cfg, errors.New("-records must not be negative")
+	case cfg.duration < 0:
+		return cfg, errors.New("-duration must not be negative")
+	}
+
+	var err error
+	cfg.mix, cfg.total, err = parseSeverityMix(mix)
+	return cfg, err
+}
+
+// parseSeverityMix parses a comma separated list of severity=weight pairs.
+func parseSeverityMix(s string) ([]severityWeight, int, error) {
+	var (
+		mix   []severityWeight
+		total int
+	)
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid -mix entry %q, expected severity=weight", part)
+		}
+		sev, ok := severitiesByName[name]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown severity %q in -mix", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, 0, fmt.Errorf("invalid weight %q for severity %q", weight, name)
+		}
+		mix = append(mix, severityWeight{name: name, severity: sev, weight: w})
+		total += w
+	}
+	if total == 0 {
+		return nil, 0, errors.New("-mix weights must not all be zero")
+	}
+	return mix, total, nil
+}
+
+func (cfg loggenConfig) pick() severityWeight {
+	n := rand.Intn(cfg.total)
+	for _, sw := range cfg.mix {
+		if n < sw.weight {
+			return sw
+		}
+		n -= sw.weight
+	}
+	return cfg.mix[len(cfg.mix)-1]
+}
+
+// runLoggen emits structured log records through the global LoggerProvider
+// at a fixed rate, picking each record's severity from the configured mix.
+func runLoggen(ctx context.Context, args []string) error {
+	cfg, err := parseLoggenFlags(args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+
+	records := logging.New(telemetry.Scope("loggen"))
+	logger.InfoContext(ctx, "loggen: emitting records", "rate", cfg.rate)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+	defer ticker.Stop()
+
+	counts := make(map[string]int, len(cfg.mix))
+	emitted := 0
+	for cfg.records == 0 || emitted < cfg.records {
+		select {
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "loggen: stopped", "emitted", emitted, "by_severity", counts)
+			return nil
+		case <-ticker.C:
+		}
+
+		sw := cfg.pick()
+		records.Log(ctx, sw.severity, fmt.Sprintf("synthetic %s record", sw.name),
+			logging.Bool("loggen.synthetic", true),
+			logging.Int("loggen.sequence", emitted),
+			logging.String("loggen.severity", sw.name),
+		)
+		counts[sw.name]++
+		emitted++
+	}
+
+	logger.InfoContext(ctx, "loggen: done", "emitted", emitted, "by_severity", counts)
+	return nil
+}
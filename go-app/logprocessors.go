@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// traceAttributesProcessor copies the trace context the SDK attached to a
+// record into trace_id, span_id and sampled attributes, which Loki can use
+// for derived fields without parsing the OTLP trace fields. It must be
+// registered before the exporting processor.
+type traceAttributesProcessor struct{}
+
+func (traceAttributesProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	if !r.TraceID().IsValid() {
+		return nil
+	}
+	r.AddAttributes(
+		otellog.String("trace_id", r.TraceID().String()),
+		otellog.String("span_id", r.SpanID().String()),
+		otellog.Bool("sampled", r.TraceFlags().IsSampled()),
+	)
+	return nil
+}
+
+func (traceAttributesProcessor) Shutdown(context.Context) error   { return nil }
+func (traceAttributesProcessor) ForceFlush(context.Context) error { return nil }
+
+var severitiesByName = map[string]otellog.Severity{
+	"trace": otellog.SeverityTrace,
+	"debug": otellog.SeverityDebug,
+	"info":  otellog.SeverityInfo,
+	"warn":  otellog.SeverityWarn,
+	"error": otellog.SeverityError,
+	"fatal": otellog.SeverityFatal,
+}
+
+// parseSeverity maps a level name such as "info" or "WARN" to its severity.
+func parseSeverity(name string) (otellog.Severity, error) {
+	sev, ok := severitiesByName[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return sev, nil
+}
+
+// severityName returns the level name of sev, rounding down to the nearest
+// named level (e.g. SeverityWarn2 is "warn").
+func severityName(sev otellog.Severity) string {
+	name, best := "", otellog.Severity(0)
+	for n, s := range severitiesByName {
+		if s <= sev && s > best {
+			name, best = n, s
+		}
+	}
+	return name
+}
+
+// severityFilterProcessor drops records below a minimum severity before they
+// reach next. The threshold can be changed at runtime. Records without a
+// severity and the telemetry canary's record are always kept.
+type severityFilterProcessor struct {
+	next sdklog.Processor
+	min  atomic.Int64
+}
+
+func newSeverityFilterProcessor(next sdklog.Processor, min otellog.Severity) *severityFilterProcessor {
+	p := &severityFilterProcessor{next: next}
+	p.SetMin(min)
+	return p
+}
+
+// Min returns the current threshold.
+func (p *severityFilterProcessor) Min() otellog.Severity {
+	return otellog.Severity(p.min.Load())
+}
+
+// SetMin changes the threshold for subsequent records.
+func (p *severityFilterProcessor) SetMin(min otellog.Severity) {
+	p.min.Store(int64(min))
+}
+
+func (p *severityFilterProcessor) allowed(sev otellog.Severity) bool {
+	return sev == otellog.SeverityUndefined || sev >= p.Min()
+}
+
+func (p *severityFilterProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if !p.allowed(r.Severity()) && !isCanaryContext(ctx) {
+		return nil
+	}
+	return p.next.OnEmit(ctx, r)
+}
+
+// Enabled implements sdklog.FilterProcessor.
+func (p *severityFilterProcessor) Enabled(_ context.Context, param sdklog.EnabledParameters) bool {
+	return p.allowed(param.Severity)
+}
+
+func (p *severityFilterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *severityFilterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// ServeHTTP reports the current level on GET and changes it on PUT or POST,
+// taking the level name as the request body (e.g. `curl -X PUT -d debug`).
+func (p *severityFilterProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sev, err := parseSeverity(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.SetMin(sev)
+		logger.InfoContext(r.Context(), "Log level changed", "level", severityName(sev))
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": severityName(p.Min())})
+}
+
+// fanoutLogProcessor passes every record to each of its processors, so a
+// filter in front of it applies to all of them.
+type fanoutLogProcessor []sdklog.Processor
+
+func (f fanoutLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	var errs []error
+	for _, p := range f {
+		errs = append(errs, p.OnEmit(ctx, r))
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutLogProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, p := range f {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutLogProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range f {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
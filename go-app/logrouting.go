@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// Log pipelines a route can send records to: "default" applies LOG_LEVEL
+// and the client telemetry budget, "audit" skips both so audit records are
+// never filtered out, and "drop" discards the records.
+const (
+	logPipelineDefault = "default"
+	logPipelineAudit   = "audit"
+	logPipelineDrop    = "drop"
+)
+
+var logPipelines = []string{logPipelineDefault, logPipelineAudit, logPipelineDrop}
+
+// logRoute sends the records of loggers whose scope name matches scope to
+// pipeline. A trailing "*" matches any suffix, so "audit/*" covers
+// "audit/payments" and "audit/admin".
+type logRoute struct {
+	Scope    string `yaml:"scope"`
+	Pipeline string `yaml:"pipeline"`
+}
+
+func (r logRoute) matches(scope string) bool {
+	if prefix, ok := strings.CutSuffix(r.Scope, "*"); ok {
+		return strings.HasPrefix(scope, prefix)
+	}
+	return scope == r.Scope
+}
+
+func (r logRoute) String() string { return r.Scope + "=" + r.Pipeline }
+
+// parseLogRoutes parses LOG_ROUTES, a comma-separated list of
+// scope=pipeline pairs such as "audit/*=audit,debug/*=drop".
+func parseLogRoutes(spec string) ([]logRoute, error) {
+	var routes []logRoute
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		scope, pipeline, ok := strings.Cut(entry, "=")
+		route := logRoute{strings.TrimSpace(scope), strings.TrimSpace(pipeline)}
+		if !ok {
+			return nil, fmt.Errorf("invalid log route %q, want scope=pipeline", entry)
+		}
+		if err := route.validate(); err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (r logRoute) validate() error {
+	if r.Scope == "" {
+		return fmt.Errorf("log route %q has no scope", r)
+	}
+	if !slices.Contains(logPipelines, r.Pipeline) {
+		return fmt.Errorf("log route %q: unknown pipeline %q, want one of %s", r, r.Pipeline, strings.Join(logPipelines, ", "))
+	}
+	return nil
+}
+
+// routingLogProcessor passes each record to the pipeline of the first route
+// matching its logger's scope, or to the default pipeline.
+//
+// The audit pipeline is made of the outputs the default pipeline ends in,
+// so shutting down and flushing the default pipeline covers both.
+type routingLogProcessor struct {
+	routes []logRoute
+	next   sdklog.Processor
+	audit  sdklog.Processor
+}
+
+func newRoutingLogProcessor(routes []logRoute, next, audit sdklog.Processor) sdklog.Processor {
+	if len(routes) == 0 {
+		return next
+	}
+	return routingLogProcessor{routes: routes, next: next, audit: audit}
+}
+
+func (p routingLogProcessor) route(scope string) sdklog.Processor {
+	for _, r := range p.routes {
+		if !r.matches(scope) {
+			continue
+		}
+		switch r.Pipeline {
+		case logPipelineAudit:
+			return p.audit
+		case logPipelineDrop:
+			return nil
+		}
+		return p.next
+	}
+	return p.next
+}
+
+func (p routingLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if next := p.route(r.InstrumentationScope().Name); next != nil {
+		return next.OnEmit(ctx, r)
+	}
+	return nil
+}
+
+// Enabled implements sdklog.FilterProcessor.
+func (p routingLogProcessor) Enabled(ctx context.Context, param sdklog.EnabledParameters) bool {
+	next := p.route(param.InstrumentationScope.Name)
+	if next == nil {
+		return false
+	}
+	if f, ok := next.(sdklog.FilterProcessor); ok {
+		return f.Enabled(ctx, param)
+	}
+	return true
+}
+
+func (p routingLogProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p routingLogProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// defaultLokiLabels are the resource attributes turned into stream labels
+// unless LOKI_LABELS names others. They identify where a record comes from
+// without multiplying streams per pod.
+const defaultLokiLabels = "service.name,service.namespace,deployment.environment,k8s.namespace.name,cloud.region"
+
+// lokiExporter pushes log records to Loki's HTTP API instead of the
+// collector. Each record becomes one line in the console's text format,
+// "level=... scope=... msg=... key=value ...", so | logfmt parses it,
+// trace_id included. Records are grouped into streams by the configured
+// resource attributes (dots replaced by underscores) and a level label.
+type lokiExporter struct {
+	endpoint string
+	headers  map[string]string
+	labels   []string
+	client   *http.Client
+}
+
+// newLokiExporter returns an exporter pushing to endpoint, the push URL
+// (e.g. http://loki:3100/loki/api/v1/push). labels is the list of resource
+// attributes used as stream labels, headers is added to every request.
+func newLokiExporter(endpoint, labels, headers string) (*lokiExporter, error) {
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid LOKI_PUSH_URL %q, want an http or https URL", redactURL(endpoint))
+	}
+	h, err := parseHeaderList("LOKI_PUSH_HEADERS", headers)
+	if err != nil {
+		return nil, err
+	}
+	return &lokiExporter{
+		endpoint: endpoint,
+		headers:  h,
+		labels:   splitList(labels),
+		// Not the instrumented client: exports must not produce spans.
+		client: &http.Client{},
+	}, nil
+}
+
+// lokiStream is a stream of the push request: its labels and
+// [timestamp in nanoseconds, line] pairs.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (e *lokiExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var streams []*lokiStream
+	index := make(map[string]*lokiStream)
+	for _, r := range records {
+		labels := e.streamLabels(r.Resource())
+		labels["level"] = lokiLevel(r.Severity())
+		key := lokiStreamKey(labels)
+		s, ok := index[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			index[key] = s
+			streams = append(streams, s)
+		}
+		ts := r.Timestamp()
+		if ts.IsZero() {
+			ts = r.ObservedTimestamp()
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), lokiLine(&r)})
+	}
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(map[string]any{"streams": streams}); err != nil {
+		return fmt.Errorf("loki push: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("loki push: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("loki push: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("loki push: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+		return fmt.Errorf("loki push to %s failed: %s: %s", redactURL(e.endpoint), res.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+func (e *lokiExporter) ForceFlush(ctx context.Context) error { return ctx.Err() }
+
+func (e *lokiExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// streamLabels returns the stream labels taken from res.
+func (e *lokiExporter) streamLabels(res *resource.Resource) map[string]string {
+	labels := make(map[string]string, len(e.labels)+1)
+	set := res.Set()
+	for _, key := range e.labels {
+		if v, ok := set.Value(attribute.Key(key)); ok && v.Emit() != "" {
+			labels[promLabelName(key)] = v.Emit()
+		}
+	}
+	return labels
+}
+
+// lokiStreamKey identifies a label set; the labels are few, so joining the
+// sorted pairs is cheap enough.
+func lokiStreamKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// lokiLine renders r like the text console line, without the timestamp
+// Loki stores separately.
+func lokiLine(r *sdklog.Record) string {
+	var buf bytes.Buffer
+	buf.WriteString("level=" + lokiLevel(r.Severity()))
+	buf.WriteString(" scope=" + quoteIfNeeded(r.InstrumentationScope().Name))
+	buf.WriteString(" msg=" + quoteIfNeeded(r.Body().String()))
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		writeTextAttr(&buf, kv.Key, kv.Value)
+		return true
+	})
+	return buf.String()
+}
+
+// lokiLevel is the level name of sev, or "unknown" (as Loki calls it) for
+// records without a severity.
+func lokiLevel(sev otellog.Severity) string {
+	if name := severityName(sev); name != "" {
+		return name
+	}
+	return "unknown"
+}
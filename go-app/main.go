@@ -2,215 +2,1120 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/contrib/instrumentation/host"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/contrib/zpages"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/log/global"
+	lognoop "go.opentelemetry.io/otel/log/noop"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+
+	"my-go-app/pkg/cache"
+	"my-go-app/pkg/dependency"
+	"my-go-app/pkg/health"
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/middleware"
+	"my-go-app/pkg/queue"
+	"my-go-app/pkg/scheduler"
+	"my-go-app/pkg/store"
+	"my-go-app/pkg/telemetry"
+	"my-go-app/pkg/worker"
+	demov1 "my-go-app/proto/demo/v1"
 )
 
 var (
-	serviceName             = os.Getenv("OTEL_SERVICE_NAME")
-	otlpEndpoint            = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	tracer                  trace.Tracer
-	meter                   metric.Meter
-	httpRequestsCounter     metric.Int64Counter
-	httpActiveRequests      metric.Int64UpDownCounter
-	workDurationHistogram   metric.Float64Histogram
-	downstreamAPIHTTPClient *http.Client
+	serviceName               = envString("OTEL_SERVICE_NAME", "")
+	otlpEndpoint              = envString("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	otlpInsecure              = envBool("OTEL_EXPORTER_OTLP_INSECURE", true)
+	otlpMaxMessageSize        = envInt("OTLP_MAX_MESSAGE_SIZE", 4<<20)
+	otlpStartupTimeout        = envDuration("OTLP_STARTUP_TIMEOUT", 0)
+	resourceAttributes        = envString("OTEL_RESOURCE_ATTRIBUTES", "")
+	podName                   = os.Getenv("POD_NAME")
+	runtimeMetricsEnabled     = envBool("RUNTIME_METRICS_ENABLED", true)
+	autoMaxProcsEnabled       = envBool("AUTOMAXPROCS_ENABLED", true)
+	autoMemLimitEnabled       = envBool("AUTOMEMLIMIT_ENABLED", false)
+	autoMemLimitRatio         = envFloat("AUTOMEMLIMIT_RATIO", 0.9)
+	hostMetricsEnabled        = envBool("HOST_METRICS_ENABLED", false)
+	metricViewsFile           = os.Getenv("METRIC_VIEWS_FILE")
+	histogramAggregation      = envString("HISTOGRAM_AGGREGATION", "explicit")
+	metricCardinalityLimit    = envInt("METRIC_CARDINALITY_LIMIT", 2000)
+	metricExportInterval      = envDuration("METRIC_EXPORT_INTERVAL", envMillis("OTEL_METRIC_EXPORT_INTERVAL", time.Minute))
+	metricExportTimeout       = envMillis("OTEL_METRIC_EXPORT_TIMEOUT", 30*time.Second)
+	telemetryDisabled         = envBool("TELEMETRY_DISABLED", false)
+	telemetryCanaryEnabled    = envBool("TELEMETRY_CANARY", true)
+	metricPreaggregation      = envDuration("METRIC_PREAGGREGATION_INTERVAL", 0)
+	requestCPUTimeEnabled     = envBool("REQUEST_CPU_TIME_ENABLED", false)
+	traceSampleRatio          = envFloat("TRACE_SAMPLE_RATIO", 1)
+	adaptiveSamplingEnabled   = envBool("ADAPTIVE_SAMPLING_ENABLED", false)
+	consistentSamplingEnabled = envBool("CONSISTENT_SAMPLING_ENABLED", false)
+	spanMetricsEnabled        = envBool("SPAN_METRICS_ENABLED", false)
+	tailSamplingEnabled       = envBool("TAIL_SAMPLING_ENABLED", false)
+	spanExportKinds           = os.Getenv("SPAN_EXPORT_KINDS")
+	spanDropKinds             = os.Getenv("SPAN_DROP_KINDS")
+	spanDropRulesConfig       = envString("SPAN_DROP_RULES", "url.path=/healthz,url.path=/readyz,user_agent.original=kube-probe/*")
+	spanDropRules             []spanDropRule
+	hotOperationsConfig       = os.Getenv("HOT_OPERATIONS")
+	metricDefinitionsFile     = os.Getenv("METRIC_DEFINITIONS_FILE")
+	logLevel                  = envString("LOG_LEVEL", "info")
+	logRoutesConfig           = envString("LOG_ROUTES", "")
+	consoleLogFormat          = envString("LOG_CONSOLE_FORMAT", "text")
+	mirrorURL                 = os.Getenv("MIRROR_URL")
+	disabledEndpoints         = os.Getenv("DISABLED_ENDPOINTS")
+	maintenanceEnabled        = envBool("MAINTENANCE_MODE", false)
+	clientTelemetryLimit      = envInt("CLIENT_TELEMETRY_BUDGET", 0)
+	redactionEnabled          = envBool("REDACTION_ENABLED", true)
+	storeDriver               = envString("STORAGE_BACKEND", envString("STORE_DRIVER", "memory"))
+	requestTimeout            = envDuration("REQUEST_TIMEOUT", 30*time.Second)
+	spanAttributeAllowlist    = os.Getenv("SPAN_ATTRIBUTE_ALLOWLIST")
+	spanAttributeDenylist     = os.Getenv("SPAN_ATTRIBUTE_DENYLIST")
+	telemetryAttributes       = os.Getenv("TELEMETRY_ATTRIBUTES")
+	spanLimits                = newSpanLimits()
+	traceIDGenerator          = envString("TRACE_ID_GENERATOR", "random")
+	adminAddr                 = envString("ADMIN_ADDR", "localhost:8081")
+	pprofEnabled              = envBool("PPROF_ENABLED", false)
+	grpcAddr                  = envString("GRPC_ADDR", "")
+	downstreamGRPCAddr        = envString("DOWNSTREAM_GRPC_ADDR", "")
+	downstreamCacheTTL        = envDuration("DOWNSTREAM_CACHE_TTL", 0)
+	downstreamCacheStale      = envDuration("DOWNSTREAM_CACHE_STALE", 30*time.Second)
+	cacheRedisURL             = envString("CACHE_REDIS_URL", "")
+	queueTransport            = envString("QUEUE_TRANSPORT", "kafka")
+	kafkaBrokers              = envString("KAFKA_BROKERS", "")
+	natsURL                   = envString("NATS_URL", "")
+	queueTopic                = envStringDeprecated("QUEUE_TOPIC", "KAFKA_TOPIC", "work-jobs")
+	queueConsumerGroup        = envStringDeprecated("QUEUE_CONSUMER_GROUP", "KAFKA_CONSUMER_GROUP", "my-go-app")
+	queueTimeObjective        = envDuration("QUEUE_TIME_OBJECTIVE", 5*time.Second)
+	workAsync                 = envBool("WORK_ASYNC", false)
+	workerConcurrency         = envInt("WORKER_CONCURRENCY", 4)
+	workerQueueSize           = envInt("WORKER_QUEUE_SIZE", 100)
+	cleanupSchedule           = envString("CLEANUP_SCHEDULE", "@every 1m")
+	collectorReadyGrace       = envDuration("COLLECTOR_READY_GRACE", 30*time.Second)
+	exportFailureThreshold    = envDuration("EXPORT_FAILURE_THRESHOLD", 5*time.Minute)
+	dependencyPolicyFile      = os.Getenv("DEPENDENCY_POLICY_FILE")
+	sdkErrorLogInterval       = envDuration("OTEL_SDK_ERROR_LOG_INTERVAL", time.Minute)
+	shutdownTimeout           = envDuration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	shutdownTelemetryTimeout  = envDuration("SHUTDOWN_TELEMETRY_TIMEOUT", 5*time.Second)
+	shutdownSummaryFile       = envString("SHUTDOWN_SUMMARY_FILE", "")
+	shutdownSignals           = envString("SHUTDOWN_SIGNALS", "SIGINT,SIGTERM")
+	preStopDelay              = envDuration("PRE_STOP_DELAY", 0)
+	sessionTTL                = envDuration("SESSION_TTL", 30*time.Minute)
+	journeyLinks              = envBool("JOURNEY_LINKS", false)
+	openTracingBridge         = envBool("OPENTRACING_BRIDGE", false)
+	remoteWriteURL            = envString("PROMETHEUS_REMOTE_WRITE_URL", "")
+	remoteWriteHeaders        = envString("PROMETHEUS_REMOTE_WRITE_HEADERS", "")
+	httpClientTrace           = envString("HTTP_CLIENT_TRACE", "")
+	tracesExporter            = envString("OTEL_TRACES_EXPORTER", "otlp")
+	zipkinEndpoint            = envString("OTEL_EXPORTER_ZIPKIN_ENDPOINT", "http://localhost:9411/api/v2/spans")
+	lokiPushURL               = envString("LOKI_PUSH_URL", "")
+	lokiLabels                = envString("LOKI_LABELS", defaultLokiLabels)
+	lokiPushHeaders           = envString("LOKI_PUSH_HEADERS", "")
+	telemetryBufferMemory     = envInt("TELEMETRY_BUFFER_MEMORY", 0)
+	rateLimit                 = envFloat("RATE_LIMIT", 0)
+	rateLimitBurst            = envInt("RATE_LIMIT_BURST", 0)
+	opampServerURL            = envString("OPAMP_SERVER_URL", "")
+	experimentWeights         = envString("EXPERIMENT_WEIGHTS", "control=50,cached=30,parallel=20")
+	webhookDedupWindow        = envDuration("WEBHOOK_DEDUP_WINDOW", 10*time.Minute)
+	webhookEventTypes         = envString("WEBHOOK_EVENT_TYPES", "order.created,order.updated,order.cancelled,payment.succeeded,payment.failed")
+	serviceResource           *resource.Resource
+	mainScope                 = telemetry.Scope("main")
+	appLog                    = logging.New(mainScope)
+	logger                    *slog.Logger
+	tracer                    trace.Tracer
+	hotOps                    *hotOperations
+	meter                     metric.Meter
+	httpRequestsCounter       metric.Int64Counter
+	httpActiveRequests        metric.Int64UpDownCounter
+	httpPanicsCounter         metric.Int64Counter
+	workDurationHistogram     metric.Float64Histogram
+	latencyExperiment         *experiment
+	webhooks                  *webhookReceiver
+	lastRequestGauge          metric.Int64Gauge
+	metricRegistry            *metrics.Registry
+	downstreamAPIHTTPClient   *http.Client
+	downstreamDemoClient      demov1.DemoClient
+	downstreamCache           *swrCache[int]
+	responseCache             cache.Cache
+	workJobs                  *queue.Producer
+	workers                   *worker.Pool
+	itemStore                 store.Store
+	itemRepository            store.Repository
+	dependencyPolicies        *dependency.Registry
+	collectorConn             *grpc.ClientConn
+	telemetryPipeline         *pipelineMetrics
+	adaptiveTraceSampler      *adaptiveSampler
+	ratioSampler              *swappableSampler
+	activeRedaction           = &redactionRules{}
+	metricReader              *intervalReader
+	clientBudget              *clientTelemetryBudget
+	shadowTraffic             *trafficMirror
+	logSeverityFilter         *severityFilterProcessor
+	liveSpans                 *zpages.SpanProcessor
+	gauges                    *gaugeRegistry
+	canary                    = &telemetryCanary{}
+	startTime                 = time.Now()
 )
 
-// initOtel sets up the OpenTelemetry pipeline.
-func initOtel(ctx context.Context) (func(context.Context) error, error) {
+// initOtel sets up the OpenTelemetry pipeline, registering its flush and
+// shutdown with shutdown.
+func initOtel(ctx context.Context, shutdown *shutdownCoordinator) error {
+	resAttrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceInstanceID(serviceInstanceID()),
+	}
+	if podName != "" {
+		resAttrs = append(resAttrs, semconv.K8SPodName(podName))
+	}
+	extraAttrs, err := parseResourceAttributes(resourceAttributes)
+	if err != nil {
+		return err
+	}
+	resAttrs = append(resAttrs, extraAttrs...)
 	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-		),
+		resource.WithAttributes(resAttrs...),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return fmt.Errorf("failed to create resource: %w", err)
+	}
+	serviceResource = res
+
+	// The collector is only dialled when a signal is exported over OTLP:
+	// Zipkin, remote write and Loki each replace it for one signal.
+	var conn *grpc.ClientConn
+	if usesCollector() {
+		creds, err := otlpCredentials()
+		if err != nil {
+			return err
+		}
+		conn, err = grpc.NewClient(otlpEndpoint,
+			grpc.WithTransportCredentials(creds),
+			grpc.WithDefaultCallOptions(
+				// Oversized batches fail here rather than at the collector,
+				// and are split and resent by the splitting exporters.
+				grpc.MaxCallSendMsgSize(otlpMaxMessageSize),
+			),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+		}
+		collectorConn = conn
+		// The providers flush through conn, so it is closed last.
+		shutdown.AddTelemetry("collector connection", func(context.Context) error { return conn.Close() })
+		if err := connectCollector(ctx, conn, otlpStartupTimeout); err != nil {
+			return err
+		}
+	}
+
+	// --- Pipeline Self-Observability ---
+	// The exporters below are wrapped to count exported items, failures and
+	// export latency. The global meter delegates to the MeterProvider once
+	// it is registered, so the instruments can be created up front.
+	pipelineScope := telemetry.Scope("telemetry-pipeline")
+	pipeline, err := newPipelineMetrics(pipelineScope.Meter())
+	if err != nil {
+		return err
+	}
+	otel.SetLogger(newSDKLogger(pipeline))
+	sdkErrors, err := newSDKErrorHandler(pipelineScope.Meter(), sdkErrorLogInterval)
+	if err != nil {
+		return err
+	}
+	otel.SetErrorHandler(sdkErrors)
+	telemetryPipeline = pipeline
+
+	// --- Per-Client Telemetry Budget ---
+	if clientTelemetryLimit > 0 {
+		clientBudget, err = newClientTelemetryBudget(telemetry.Scope("client-budget").Meter(),
+			int64(clientTelemetryLimit),
+			envDuration("CLIENT_TELEMETRY_BUDGET_WINDOW", time.Minute),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// --- PII Redaction ---
+	// Applied to spans and log records before export so sensitive data
+	// never leaves the pod.
+	if redactionEnabled {
+		redact, err := newRedactor(envString("REDACT_KEYS", ""), envString("REDACT_PATTERNS", ""))
+		if err != nil {
+			return err
+		}
+		activeRedaction.Store(redact)
 	}
 
-	conn, err := grpc.NewClient(otlpEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// --- Enrichment ---
+	// Deployment tags such as region, cluster and team are added to every
+	// span and log record here instead of in each handler.
+	enrichment, err := parseEnrichment(telemetryAttributes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+		return err
 	}
 
 	// --- Trace Exporter ---
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	// Spans go to the collector over OTLP, or as Zipkin v2 JSON to a
+	// backend that only speaks Zipkin.
+	var traceExporter sdktrace.SpanExporter
+	switch tracesExporter {
+	case "otlp":
+		traceExporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	case "zipkin":
+		traceExporter, err = zipkin.New(zipkinEndpoint)
+	default:
+		err = fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, want otlp or zipkin", tracesExporter)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	// Spans and log records waiting for export are held by memory-bounded
+	// buffers that drop the oldest first, so a collector outage cannot run
+	// a small pod out of memory.
+	bufferBudget := telemetryBufferBudget()
+	spanBuffer := newBufferedSpanExporter(instrumentedSpanExporter{splittingSpanExporter{traceExporter, pipeline}, pipeline},
+		int64(float64(bufferBudget)*spanBufferShare), pipeline)
+	bsp := bufferFlushingSpanProcessor{sdktrace.NewBatchSpanProcessor(spanBuffer, batchSpanOptions()...), spanBuffer.buffer}
+	ratioSampler = newSwappableSampler(newRatioSampler(traceSampleRatio, consistentSamplingEnabled))
+	var sampler sdktrace.Sampler = ratioSampler
+	if adaptiveSamplingEnabled {
+		adaptiveTraceSampler = newAdaptiveSampler(adaptiveSamplerConfig{
+			BaseRatio:    traceSampleRatio,
+			BoostedRatio: envFloat("ADAPTIVE_SAMPLING_BOOSTED_RATIO", 1),
+			ErrorRate:    envFloat("ADAPTIVE_SAMPLING_ERROR_RATE", 0.05),
+			P99:          envDuration("ADAPTIVE_SAMPLING_P99", 500*time.Millisecond),
+			Window:       envDuration("ADAPTIVE_SAMPLING_WINDOW", 30*time.Second),
+			Consistent:   consistentSamplingEnabled,
+		})
+		sampler = adaptiveTraceSampler
+	}
+	var parentOpts []sdktrace.ParentBasedSamplerOption
+	if clientBudget != nil {
+		sampler = clientBudgetSampler{sampler, clientBudget}
+		// Otherwise a client could keep its traces by sending a sampled
+		// traceparent.
+		parentOpts = append(parentOpts, sdktrace.WithRemoteParentSampled(clientBudgetSampler{sdktrace.AlwaysSample(), clientBudget}))
+	}
+	sampler = canarySampler{sampler}
+	var exportProcessor sdktrace.SpanProcessor = queueCountingProcessor{bsp, pipeline}
+	// Always installed, so redaction can be enabled by a config reload.
+	exportProcessor = redactionSpanProcessor{exportProcessor, activeRedaction}
+	if spanAttributeAllowlist != "" || spanAttributeDenylist != "" {
+		exportProcessor, err = newAttributeFilterProcessor(exportProcessor, pipelineScope.Meter(),
+			spanAttributeAllowlist, spanAttributeDenylist)
+		if err != nil {
+			return err
+		}
+	}
+	exportKinds, err := exportedSpanKinds(spanExportKinds, spanDropKinds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return fmt.Errorf("invalid span kind filter: %w", err)
+	}
+	if exportKinds != nil {
+		exportProcessor = spanKindFilterProcessor{exportProcessor, exportKinds}
+	}
+	if spanDropRules, err = parseSpanDropRules(spanDropRulesConfig); err != nil {
+		return err
+	}
+	if len(spanDropRules) > 0 {
+		exportProcessor = noiseFilterProcessor{exportProcessor, spanDropRules}
+	}
+	if tailSamplingEnabled {
+		exportProcessor = newTailSamplingProcessor(exportProcessor,
+			envDuration("TAIL_SAMPLING_DECISION_WAIT", 10*time.Second),
+			envInt("TAIL_SAMPLING_MAX_SPANS", 10000),
+		)
 	}
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
+		sdktrace.WithRawSpanLimits(spanLimits),
+	}
+	idGenerator, err := newIDGenerator(traceIDGenerator)
+	if err != nil {
+		return err
+	}
+	if idGenerator != nil {
+		tpOpts = append(tpOpts, sdktrace.WithIDGenerator(idGenerator))
+	}
+	if len(enrichment) > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(enrichmentSpanProcessor{enrichment}))
+	}
+	tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(sessionSpanProcessor{}))
+	tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(exportProcessor))
+	if spanMetricsEnabled {
+		spanMetrics, err := newSpanMetricsProcessor(telemetry.Scope("span-metrics").Meter())
+		if err != nil {
+			return err
+		}
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(spanMetrics))
+	}
+	if clientBudget != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(clientBudgetSpanProcessor{clientBudget}))
+	}
+	// Keeps recent and in-flight spans in memory for /debug/tracez.
+	liveSpans = zpages.NewSpanProcessor()
+	// /debug/tracez shows the same redacted spans that are exported.
+	tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(liveRedactionSpanProcessor{redactionSpanProcessor{liveSpans, activeRedaction}}))
+	if spanMetricsEnabled || tailSamplingEnabled {
+		// Unsampled spans are recorded (but not exported) so span metrics
+		// cover all traffic regardless of the sampling ratio, and the tail
+		// sampling buffer can promote traces that end in error.
+		tpOpts = append(tpOpts, sdktrace.WithSampler(recordOnlySampler{sdktrace.ParentBased(sampler, parentOpts...)}))
+	} else {
+		tpOpts = append(tpOpts, sdktrace.WithSampler(sdktrace.ParentBased(sampler, parentOpts...)))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(tpOpts...)
+	shutdown.AddTelemetry("tracer provider", tracerProvider.Shutdown)
+	propagator := propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
 	)
-	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagator)
+	if openTracingBridge {
+		// Legacy opentracing-go instrumentation records into the same
+		// provider; the wrapped provider keeps OTel and OpenTracing spans
+		// in one trace.
+		otTracer, wrapped := telemetry.NewOpenTracingBridge(tracerProvider, propagator)
+		opentracing.SetGlobalTracer(otTracer)
+		otel.SetTracerProvider(wrapped)
+	} else {
+		otel.SetTracerProvider(tracerProvider)
+	}
 
 	// --- Metric Exporter ---
-	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+	// Metrics go to the collector, or straight to a Prometheus remote-write
+	// receiver where there is none.
+	var metricExporter sdkmetric.Exporter
+	if remoteWriteURL != "" {
+		metricExporter, err = newRemoteWriteExporter(remoteWriteURL, remoteWriteHeaders)
+	} else {
+		metricExporter, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create metric exporter: %w", err)
+	}
+	var readerOpts []sdkmetric.ManualReaderOption
+	if runtimeMetricsEnabled {
+		// Scheduler latency distribution (go.schedule.duration), which the
+		// runtime only exposes as a precomputed histogram.
+		readerOpts = append(readerOpts, sdkmetric.WithProducer(runtime.NewProducer()))
+	}
+	if metricExportInterval <= 0 {
+		return fmt.Errorf("METRIC_EXPORT_INTERVAL must be a positive duration, got %s", metricExportInterval)
+	}
+	if metricExportTimeout <= 0 {
+		return fmt.Errorf("OTEL_METRIC_EXPORT_TIMEOUT must be a positive number of milliseconds, got %d", metricExportTimeout.Milliseconds())
+	}
+	metricReader = newIntervalReader(instrumentedMetricExporter{splittingMetricExporter{metricExporter, pipeline}, pipeline},
+		metricExportInterval, metricExportTimeout, readerOpts...)
+	viewConfigs, err := loadViewConfigs(metricViewsFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		return err
+	}
+	if remoteWriteURL != "" {
+		if err := checkRemoteWriteAggregation(histogramAggregation, viewConfigs); err != nil {
+			return err
+		}
+	}
+	view, err := buildView(viewConfigs, histogramAggregation)
+	if err != nil {
+		return err
 	}
-	reader := sdkmetric.NewPeriodicReader(metricExporter)
 	meterProvider := sdkmetric.NewMeterProvider(
 		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(reader),
+		sdkmetric.WithReader(metricReader),
+		sdkmetric.WithView(view),
+		// Cap the number of series per instrument; measurements beyond the
+		// cap are folded into a single otel.metric.overflow=true series.
+		sdkmetric.WithCardinalityLimit(metricCardinalityLimit),
+		// Attach exemplars (trace and span ids) to measurements recorded
+		// within a sampled span, so latency histograms link to traces.
+		// Counters prefer exemplars of failed requests.
+		sdkmetric.WithExemplarFilter(exemplarFilter(tailSamplingEnabled)),
 	)
 	otel.SetMeterProvider(meterProvider)
+	shutdown.AddTelemetry("meter provider", meterProvider.Shutdown)
+
+	// --- Go Runtime Metrics ---
+	if runtimeMetricsEnabled {
+		if err := runtime.Start(runtime.WithMeterProvider(meterProvider)); err != nil {
+			return fmt.Errorf("failed to start runtime metrics: %w", err)
+		}
+	}
+
+	// --- Host Metrics ---
+	// Useful when no node agent is scraping the host, e.g. plain Docker
+	// deployments. Reports CPU time, memory usage and network I/O.
+	if hostMetricsEnabled {
+		if err := host.Start(host.WithMeterProvider(meterProvider)); err != nil {
+			return fmt.Errorf("failed to start host metrics: %w", err)
+		}
+	}
 
 	// --- Log Exporter ---
-	logExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+	// Logs go to the collector, or straight to Loki where the collector has
+	// no log pipeline yet.
+	var logExporter sdklog.Exporter
+	if lokiPushURL != "" {
+		logExporter, err = newLokiExporter(lokiPushURL, lokiLabels, lokiPushHeaders)
+	} else {
+		logExporter, err = otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create log exporter: %w", err)
+	}
+	minSeverity, err := parseSeverity(logLevel)
+	if err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	logOutputs := fanoutLogProcessor{
+		sdklog.NewBatchProcessor(newBufferedLogExporter(instrumentedLogExporter{splittingLogExporter{logExporter, pipeline}, pipeline},
+			int64(float64(bufferBudget)*logBufferShare), pipeline), batchLogOptions()...),
+	}
+	// Also write records to stdout for `kubectl logs` unless disabled.
+	if consoleLogFormat != "off" {
+		console, err := newConsoleLogProcessor(os.Stdout, consoleLogFormat)
+		if err != nil {
+			return fmt.Errorf("invalid LOG_CONSOLE_FORMAT: %w", err)
+		}
+		logOutputs = append(logOutputs, console)
+	}
+	var logSink sdklog.Processor = logOutputs
+	if clientBudget != nil {
+		logSink = clientBudgetLogProcessor{logSink, clientBudget}
+	}
+	logSeverityFilter = newSeverityFilterProcessor(logSink, minSeverity)
+	logRoutes, err := parseLogRoutes(logRoutesConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create log exporter: %w", err)
+		return fmt.Errorf("invalid LOG_ROUTES: %w", err)
 	}
-	loggerProvider := sdklog.NewLoggerProvider(
+	logOpts := []sdklog.LoggerProviderOption{
 		sdklog.WithResource(res),
-		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
-	)
+		sdklog.WithProcessor(traceAttributesProcessor{}),
+	}
+	if len(enrichment) > 0 {
+		logOpts = append(logOpts, sdklog.WithProcessor(newEnrichmentLogProcessor(enrichment)))
+	}
+	logOpts = append(logOpts, sdklog.WithProcessor(redactionLogProcessor{activeRedaction}))
+	logOpts = append(logOpts, sdklog.WithProcessor(newRoutingLogProcessor(logRoutes, logSeverityFilter, logOutputs)))
+	loggerProvider := sdklog.NewLoggerProvider(logOpts...)
 	global.SetLoggerProvider(loggerProvider)
+	shutdown.AddTelemetry("logger provider", loggerProvider.Shutdown)
+
+	// Server lifecycle messages go through slog so they reach the OTel log
+	// pipeline with trace correlation, like the handlers' records.
+	logger = slog.New(mainScope.SlogHandler(loggerProvider))
+	if err := initInstruments(shutdown); err != nil {
+		return err
+	}
 
-	// --- Create Tracers, Meters, and Instruments ---
-	tracer = otel.Tracer("my-go-app/main-tracer")
-	meter = otel.Meter("my-go-app/main-meter")
+	// Runs before the providers are shut down: everything emitted by the
+	// earlier shutdown steps is exported while the pipeline is intact.
+	shutdown.AddTelemetry("telemetry flush", telemetry.Flush)
+	return nil
+}
 
-	httpRequestsCounter, err = meter.Int64Counter(
-		"http.server.requests_total",
-		metric.WithDescription("Total number of incoming HTTP requests."),
-		metric.WithUnit("{request}"),
-	)
+// initNoopTelemetry installs no-op providers for TELEMETRY_DISABLED. The
+// collector is never dialled and nothing is registered with the shutdown
+// coordinator; the instruments are still created, from the no-op meter, so
+// the rest of the service runs unchanged. Lifecycle messages go to stderr.
+func initNoopTelemetry() error {
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	global.SetLoggerProvider(lognoop.NewLoggerProvider())
+	logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	return initInstruments(&shutdownCoordinator{})
+}
+
+// initInstruments creates the tracers, meters and instruments from the
+// installed providers.
+func initInstruments(shutdown *shutdownCoordinator) error {
+	tracer = mainScope.Tracer()
+	meter = mainScope.Meter()
+
+	hotOpsEvery, err := parseHotOperations(hotOperationsConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create http_requests_total counter: %w", err)
+		return err
+	}
+	if hotOps, err = newHotOperations(tracer, meter, hotOpsEvery); err != nil {
+		return err
 	}
 
-	httpActiveRequests, err = meter.Int64UpDownCounter(
-		"http.server.active_requests",
-		metric.WithDescription("Number of active HTTP requests."),
-		metric.WithUnit("{request}"),
-	)
+	defs := defaultMetricDefinitions
+	if metricDefinitionsFile != "" {
+		fileDefs, err := metrics.LoadDefinitions(metricDefinitionsFile)
+		if err != nil {
+			return err
+		}
+		defs = append(defs[:len(defs):len(defs)], fileDefs...)
+	}
+	metricRegistry, err = metrics.NewRegistry(meter, defs...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create http_active_requests counter: %w", err)
+		return err
 	}
 
-	workDurationHistogram, err = meter.Float64Histogram(
-		"app.work.duration",
-		metric.WithDescription("Duration of the work operation."),
-		metric.WithUnit("s"),
-	)
+	if httpRequestsCounter, err = metricRegistry.Counter(metrics.HTTPServerRequests.Name); err != nil {
+		return err
+	}
+	httpRequestsCounter = preaggregate(shutdown, httpRequestsCounter)
+	if httpActiveRequests, err = metricRegistry.UpDownCounter(metrics.HTTPServerActiveRequests.Name); err != nil {
+		return err
+	}
+	if httpPanicsCounter, err = metricRegistry.Counter(metrics.HTTPServerPanics.Name); err != nil {
+		return err
+	}
+	if lastRequestGauge, err = metricRegistry.Gauge(metrics.HTTPServerLastRequestTimestamp.Name); err != nil {
+		return err
+	}
+	if workDurationHistogram, err = metricRegistry.Histogram(metrics.WorkDuration.Name); err != nil {
+		return err
+	}
+	experimentDuration, err := metricRegistry.Histogram(metrics.ExperimentDuration.Name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create work_duration_seconds histogram: %w", err)
+		return err
+	}
+	if latencyExperiment, err = newExperiment(experimentWeights, experimentDuration); err != nil {
+		return err
 	}
 
 	// Create an instrumented HTTP client to automatically propagate trace context
-	downstreamAPIHTTPClient = &http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	downstreamConns := &connCounter{}
+	downstreamTransport := http.DefaultTransport.(*http.Transport).Clone()
+	downstreamTransport.DialContext = downstreamConns.wrapDialer((&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext)
+	dependencyPolicies, err = dependency.Load(dependencyPolicyFile)
+	if err != nil {
+		return err
+	}
+	for former, name := range dependencyPolicies.Renamed() {
+		appLog.Warn(context.Background(), "Dependency policy uses a deprecated name",
+			logging.String("dependency.deprecated_name", former), logging.String(string(dependency.NameKey), name))
+	}
+	clientMetrics, err := newHTTPClientMetrics(meter, downstreamConns,
+		dependency.Transport(dependencyPolicies.Policy("downstream"), downstreamTransport))
+	if err != nil {
+		return err
+	}
+	// Retries wrap otelhttp so every attempt gets its own client span; the
+	// circuit breaker wraps the retries so a call and its retries count as
+	// one outcome.
+	downstreamPolicy := dependencyPolicies.Policy("downstream")
+	retrying, err := dependency.Retry(telemetry.Scope("dependency"), downstreamPolicy,
+		otelhttp.NewTransport(clientMetrics, clientTraceOptions("downstream")...))
+	if err != nil {
+		return err
 	}
+	breaker, err := dependency.CircuitBreaker(telemetry.Scope("dependency"), downstreamPolicy, retrying)
+	if err != nil {
+		return err
+	}
+	downstreamAPIHTTPClient = &http.Client{Transport: breaker}
 
-	return func(shutdownCtx context.Context) error {
-		cErr := conn.Close()
-		tpErr := tracerProvider.Shutdown(shutdownCtx)
-		mpErr := meterProvider.Shutdown(shutdownCtx)
-		lpErr := loggerProvider.Shutdown(shutdownCtx)
-		if cErr != nil {
-			return cErr
-		}
-		if tpErr != nil {
-			return tpErr
+	if downstreamCacheTTL > 0 {
+		// Only successful responses are cached.
+		downstreamCache, err = newSWRCache(meter, downstreamCacheTTL, downstreamCacheStale, fetchDownstreamCached, func(status int) error {
+			if status >= http.StatusInternalServerError {
+				return fmt.Errorf("downstream service answered %d", status)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		if mpErr != nil {
-			return mpErr
+	}
+
+	if webhooks, err = newWebhookReceiver(meter, webhookDedupWindow, splitList(webhookEventTypes)); err != nil {
+		return err
+	}
+
+	if mirrorURL != "" {
+		shadowTraffic, err = newTrafficMirror(mirrorURL,
+			envFloat("MIRROR_PERCENT", 10),
+			envDuration("MIRROR_TIMEOUT", 10*time.Second),
+		)
+		if err != nil {
+			return err
 		}
-		if lpErr != nil {
-			return lpErr
+	}
+
+	gauges = newGaugeRegistry(meter)
+	if err := registerDefaultGauges(gauges, startTime, downstreamConns); err != nil {
+		return err
+	}
+	if err := registerMaxProcsGauges(gauges); err != nil {
+		return err
+	}
+	if err := registerMemLimitGauges(gauges); err != nil {
+		return err
+	}
+	if runtimeMetricsEnabled {
+		if err := registerContentionMetrics(gauges); err != nil {
+			return err
 		}
-		return nil
-	}, nil
+	}
+	return nil
+}
+
+// serviceInstanceID identifies this replica. The pod name (exposed through the
+// downward API as POD_NAME) is preferred so the id survives container
+// restarts; otherwise a random UUID is generated once per process.
+func serviceInstanceID() string {
+	if podName != "" {
+		return podName
+	}
+	return uuid.NewString()
 }
 
-// Middleware to count active requests
+// Middleware to count active requests and track the last request time
 func activeRequestsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		httpActiveRequests.Add(ctx, 1)
 		defer httpActiveRequests.Add(ctx, -1)
+		lastRequestGauge.Record(ctx, time.Now().Unix())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDAttributeMiddleware records the request ID on the request span,
+// so a trace can be found from the X-Request-ID of a response.
+func requestIDAttributeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.RequestIDFromContext(r.Context()); id != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.StringSlice("http.request.header.x-request-id", []string{id}),
+			)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	if err := run(); err != nil {
+		stderrLog.Error("Exiting on error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// preaggregate puts counter behind a sharded pre-aggregation layer when
+// METRIC_PREAGGREGATION_INTERVAL is set, for counters added to on every
+// request. The pending sums are flushed before the meter provider shuts
+// down.
+func preaggregate(shutdown *shutdownCoordinator, counter metric.Int64Counter) metric.Int64Counter {
+	if metricPreaggregation <= 0 || telemetryDisabled {
+		return counter
+	}
+	c := metrics.Preaggregate(counter, metricPreaggregation)
+	shutdown.AddTelemetry("metric pre-aggregation", c.Close)
+	return c
+}
 
-	shutdown, err := initOtel(ctx)
+// run starts the selected subcommand or the HTTP server and blocks until it
+// ends. Errors, including panics, are returned rather than exiting on the
+// spot, so they are logged through the LoggerProvider and buffered telemetry
+// is flushed by the deferred shutdown before main exits.
+func run() (err error) {
+	if configFileErr != nil {
+		return configFileErr
+	}
+	setStderrFormat(consoleLogFormat)
+	setMaxProcs()
+	if err := setMemLimit(); err != nil {
+		return err
+	}
+	args := os.Args[1:]
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args, err = parseServerFlags(args); err != nil {
+			return err
+		}
+	}
+	signals, err := parseSignals(shutdownSignals)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := checkClientTrace(httpClientTrace); err != nil {
+		return err
+	}
+	// Like the timeout of a route policy: a deadline in the past would
+	// fail every request.
+	if requestTimeout <= 0 {
+		return fmt.Errorf("REQUEST_TIMEOUT must be a positive duration, got %s", requestTimeout)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	// Registered before initOtel so a partially initialized pipeline is
+	// still torn down.
+	shutdown := &shutdownCoordinator{telemetryTimeout: shutdownTelemetryTimeout}
+	defer func() {
+		if err != nil && logger != nil {
+			logger.Error("Exiting on error", "error", err)
+		}
+		// ctx is already canceled on interrupt; give the drain and the
+		// exporters a fresh deadline.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdownStart := time.Now()
+		if sErr := shutdown.Shutdown(shutdownCtx); sErr != nil {
+			err = errors.Join(err, fmt.Errorf("shutdown failed: %w", sErr))
+		}
+		reportShutdownSummary(shutdownStart, err)
+	}()
+
+	if telemetryDisabled {
+		if err := initNoopTelemetry(); err != nil {
+			return err
+		}
+	} else if err := initOtel(ctx, shutdown); err != nil {
+		return fmt.Errorf("failed to initialize OpenTelemetry: %w", err)
 	}
+	watchFlushSignal(ctx)
+	configReload := newConfigReloader(configFilePath)
+	configReload.Watch(ctx)
 	defer func() {
-		if err := shutdown(ctx); err != nil {
-			log.Fatal("failed to shutdown OpenTelemetry: ", err)
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
 		}
 	}()
 
+	if len(args) > 0 {
+		switch args[0] {
+		case "tracegen":
+			if err := runTracegen(ctx, args[1:]); err != nil {
+				return fmt.Errorf("tracegen: %w", err)
+			}
+			return nil
+		case "metricgen":
+			if err := runMetricgen(ctx, args[1:]); err != nil {
+				return fmt.Errorf("metricgen: %w", err)
+			}
+			return nil
+		case "loggen":
+			if err := runLoggen(ctx, args[1:]); err != nil {
+				return fmt.Errorf("loggen: %w", err)
+			}
+			return nil
+		default:
+			return fmt.Errorf("unknown subcommand %q", args[0])
+		}
+	}
+
+	rawStore, err := store.Open(ctx, storeDriver, os.Getenv("STORE_DSN"))
+	if err != nil {
+		return err
+	}
+	storeScope := telemetry.Scope("store")
+	itemStore, err = store.Instrument(rawStore, storeDriver, dependencyPolicies.Policy("store"),
+		storeScope.Tracer(), storeScope.Meter())
+	if err != nil {
+		return err
+	}
+	shutdown.Add("store", func(context.Context) error { return itemStore.Close() })
+	if repo, ok := rawStore.(store.Repository); ok {
+		itemRepository = repo
+	}
+
+	if downstreamCacheTTL > 0 && cacheRedisURL != "" {
+		if responseCache, err = openResponseCache(ctx); err != nil {
+			return err
+		}
+		shutdown.Add("response cache", func(context.Context) error { return responseCache.Close() })
+	}
+
+	servers, err := queueServers()
+	if err != nil {
+		return err
+	}
+	if servers != "" {
+		if err := startWorkJobs(ctx, servers, shutdown); err != nil {
+			return err
+		}
+	}
+
+	if workAsync {
+		workerScope := telemetry.Scope("worker")
+		workers, err = worker.New(worker.Config{
+			Name:        "work",
+			Concurrency: workerConcurrency,
+			QueueSize:   workerQueueSize,
+		}, workerScope.Tracer(), workerScope.Meter())
+		if err != nil {
+			return err
+		}
+		// The pool drains after the HTTP server, so every job a request
+		// queued runs, and before the queue producer its jobs publish to.
+		shutdown.Add("worker pool", workers.Shutdown)
+	}
+
+	if cleanupSchedule != "" {
+		tasks, err := scheduler.New(telemetry.Scope("scheduler"))
+		if err != nil {
+			return err
+		}
+		if err := tasks.Add("webhook-dedup-cleanup", cleanupSchedule, time.Minute, webhooks.cleanup); err != nil {
+			return fmt.Errorf("invalid CLEANUP_SCHEDULE: %w", err)
+		}
+		tasks.Start()
+		shutdown.Add("scheduler", tasks.Stop)
+	}
+
+	dependencies, err := health.New(telemetry.Scope("health"), envDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second))
+	if err != nil {
+		return err
+	}
+	registerDependencies(ctx, dependencies)
+
+	if downstreamGRPCAddr != "" {
+		downstreamGRPCConn, err := newDownstreamGRPCConn(downstreamGRPCAddr, dependencyPolicies.Policy("downstream-grpc"))
+		if err != nil {
+			return err
+		}
+		downstreamDemoClient = demov1.NewDemoClient(downstreamGRPCConn)
+		shutdown.Add("downstream gRPC connection", func(context.Context) error { return downstreamGRPCConn.Close() })
+	}
+
+	if opampServerURL != "" {
+		opamp, err := startOpAMP(ctx, opampServerURL, configReload, dependencies)
+		if err != nil {
+			return err
+		}
+		shutdown.Add("OpAMP client", opamp.Stop)
+	}
+
+	httpMetrics, err := newHTTPServerMetrics(meter, requestCPUTimeEnabled)
+	if err != nil {
+		return err
+	}
+	endpointSwitches, err := newKillSwitches(meter, disabledEndpoints)
+	if err != nil {
+		return err
+	}
+	maintenance, err := newMaintenanceMode(meter, maintenanceEnabled)
+	if err != nil {
+		return err
+	}
+	userSessions, err := newSessions(meter, sessionTTL, journeyLinks)
+	if err != nil {
+		return err
+	}
+	userSessions.requests = preaggregate(shutdown, userSessions.requests)
+	limiter, err := newRateLimiter(meter, rateLimit, rateLimitBurst)
+	if err != nil {
+		return err
+	}
+
+	var clientIdentity, adaptiveSampling, trafficMirroring middleware.Middleware
+	if clientBudget != nil {
+		clientIdentity = clientBudget.Middleware
+	}
+	if adaptiveTraceSampler != nil {
+		adaptiveSampling = adaptiveTraceSampler.Middleware
+	}
+	if shadowTraffic != nil {
+		trafficMirroring = shadowTraffic.Middleware
+	}
+
+	// instrument wraps a route handler in the middleware chain, outermost
+	// first, less what the route's policy disables. The application
+	// middleware runs inside otelhttp so it sees the request span:
+	// measurements can carry exemplars, panics are recorded on the span and
+	// profiles can be filtered by trace id. The outer recovery only catches
+	// panics in the middleware that runs before the span.
+	instrument := func(name string, policy *effectiveRoutePolicy, h http.HandlerFunc) (http.Handler, error) {
+		chain := middleware.NewChain().
+			Disable(policy.Disabled...).
+			Use(middleware.Recovery, "recovery", recoveryMiddleware).
+			Use(middleware.RequestID, "request-id", middleware.RequestIDMiddleware).
+			Use(middleware.Auth, "client-identity", clientIdentity).
+			Use(middleware.Tracing, "otelhttp", otelhttp.NewMiddleware(name,
+				otelhttp.WithFilter(requestFilter(spanDropRules)),
+			)).
+			Use(middleware.Metrics, "http-metrics", httpMetrics.Middleware).
+			Use(middleware.Metrics, "active-requests", activeRequestsMiddleware).
+			Use(middleware.RateLimit, "rate-limit", limiter.Middleware(policy)).
+			Use(middleware.Application, "maintenance", maintenance.Middleware).
+			Use(middleware.Application, "kill-switch", endpointSwitches.Middleware).
+			Use(middleware.Application, "traffic-mirror", trafficMirroring).
+			Use(middleware.Application, "adaptive-sampling", adaptiveSampling).
+			Use(middleware.Application, "sessions", userSessions.Middleware).
+			Use(middleware.Application, "request-id-attribute", requestIDAttributeMiddleware).
+			Use(middleware.Application, "deadline", func(next http.Handler) http.Handler {
+				return deadlineMiddleware(policy.Timeout, next)
+			}).
+			Use(middleware.Application, "handler-recovery", recoveryMiddleware).
+			Use(middleware.Application, "pprof-labels", pprofLabelsMiddleware)
+		handler, err := chain.Then(h)
+		policy.Middleware = chain.Names()
+		return handler, err
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/hello", otelhttp.NewHandler(http.HandlerFunc(helloHandler), "hello"))
-	mux.Handle("/work", otelhttp.NewHandler(http.HandlerFunc(workHandler), "work"))
-	mux.Handle("/downstream", otelhttp.NewHandler(http.HandlerFunc(downstreamHandler), "downstream"))
+	var routeErrs []error
+	routes := &routeTable{}
+	route := func(pattern, name string, h http.HandlerFunc) {
+		policy := resolveRoutePolicy(configFile.Routes, pattern, name, requestTimeout)
+		handler, err := instrument(name, &policy, h)
+		if err != nil {
+			routeErrs = append(routeErrs, fmt.Errorf("route %s: %w", pattern, err))
+			return
+		}
+		routes.add(policy)
+		endpointSwitches.Register(pattern)
+		mux.Handle(pattern, countRequests(handler))
+	}
+	route("/hello", "hello", helloHandler)
+	route("/work", "work", workHandler)
+	route("/downstream", "downstream", downstreamHandler)
+	route("/downstream-grpc", "downstream-grpc", downstreamGRPCHandler)
+	route("/convert", "convert", convertHandler)
+	route("/items/{key}", "items", itemsHandler)
+	route("/experiment", "experiment", latencyExperiment.ServeHTTP)
+	route("/webhooks", "webhooks", webhooks.ServeHTTP)
+	routeErrs = append(routeErrs, routes.unmatched(configFile.Routes)...)
+	if err := errors.Join(routeErrs...); err != nil {
+		return err
+	}
 
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: activeRequestsMiddleware(mux),
+		Handler: mux,
+	}
+
+	// Probes, introspection and operational switches are served on their
+	// own port so they keep answering while the application port is
+	// saturated, and are never exposed with the application.
+	liveness := map[string]health.Check{}
+	if telemetryPipeline != nil {
+		liveness["telemetry-export"] = exportLivenessCheck(telemetryPipeline, exportFailureThreshold)
+	}
+	adminMux := newAdminMux(drainingReadiness(maintenance.Readiness(dependencies)), liveness, liveSpans, pprofEnabled)
+	if logSeverityFilter != nil {
+		adminMux.Handle("/admin/log-level", logSeverityFilter)
+	}
+	adminMux.Handle("/admin/endpoints", endpointSwitches)
+	adminMux.Handle("/admin/routes", routes)
+	adminMux.Handle("/admin/telemetry/canary", canary)
+	adminMux.Handle("/admin/maintenance", maintenance)
+	adminMux.HandleFunc("/admin/flush", flushHandler)
+	adminMux.Handle("/admin/reload", configReload)
+	adminServer := &http.Server{
+		Addr:    adminAddr,
+		Handler: adminMux,
 	}
 
+	// Listen before announcing the servers so a taken port fails startup.
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("HTTP server listen: %w", err)
+	}
+	adminLn, err := net.Listen("tcp", adminServer.Addr)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("admin server listen: %w", err)
+	}
+	var (
+		grpcSrv *grpcServer
+		grpcLn  net.Listener
+	)
+	if grpcAddr != "" {
+		grpcSrv = newGRPCServer()
+		if grpcLn, err = net.Listen("tcp", grpcAddr); err != nil {
+			ln.Close()
+			adminLn.Close()
+			return fmt.Errorf("gRPC server listen: %w", err)
+		}
+	}
+	serveErr := make(chan error, 3)
+	go func() {
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
 	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("HTTP server ListenAndServe: %v", err)
+		if err := adminServer.Serve(adminLn); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("admin server: %w", err)
 		}
 	}()
 
-	log.Println("Server started on :8080")
-	<-ctx.Done()
+	if grpcSrv != nil {
+		go func() {
+			if err := grpcSrv.server.Serve(grpcLn); err != nil {
+				serveErr <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+
+	// The probes keep answering until the application servers have drained.
+	shutdown.Add("admin server", adminServer.Shutdown)
+	if grpcSrv != nil {
+		shutdown.Add("gRPC server", grpcSrv.Shutdown)
+	}
+	shutdown.Add("HTTP server", func(ctx context.Context) error {
+		if err := server.Shutdown(ctx); err != nil {
+			return err
+		}
+		logger.Info("Server gracefully shutdown")
+		return nil
+	})
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("HTTP server shutdown failed: %v", err)
+	logStartupBanner(ctx, server.Addr)
+	if telemetryCanaryEnabled && !telemetryDisabled {
+		if err := canary.emit(ctx); err != nil {
+			return fmt.Errorf("failed to emit telemetry canary: %w", err)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		// A second signal kills the process instead of waiting for the
+		// drain.
+		stop()
+		preStop(preStopDelay)
+		return nil
+	case err := <-serveErr:
+		return err
 	}
-	log.Println("Server gracefully shutdown")
 }
 
 // Simple endpoint
 func helloHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	logger := global.Logger("helloHandler")
-
-	_, span := tracer.Start(ctx, "helloHandler.work")
+	_, span := hotOps.Start(ctx, "helloHandler.work")
 	defer span.End()
 
 	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/hello")))
 
-	emitLog(ctx, logger, otellog.SeverityInfo, "Received request for /hello")
+	appLog.Info(ctx, "Received request for /hello")
 
 	time.Sleep(50 * time.Millisecond)
 	span.AddEvent("Finished sleeping")
@@ -222,56 +1127,113 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 func workHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	startTime := time.Now()
-	logger := global.Logger("workHandler")
 
-	_, span := tracer.Start(ctx, "workHandler.mainOperation")
+	ctx, span := tracer.Start(ctx, "workHandler.mainOperation")
 	defer span.End()
 
 	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/work")))
-	emitLog(ctx, logger, otellog.SeverityInfo, "Starting complex work")
+	appLog.Info(ctx, "Starting complex work")
 
 	// 1. Simulate some initial work
 	time.Sleep(time.Duration(75+rand.Intn(50)) * time.Millisecond)
 	span.AddEvent("Initial processing complete")
 
-	// 2. Call the downstream service
-	emitLog(ctx, logger, otellog.SeverityInfo, "Calling downstream service")
-	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/downstream", nil)
-
-	// The instrumented client will automatically create a child span
-	res, err := downstreamAPIHTTPClient.Do(req)
+	// 2. Call the downstream service, or use its cached response
+	appLog.Info(ctx, "Calling downstream service")
+	var (
+		statusCode int
+		err        error
+	)
+	if downstreamCache != nil {
+		statusCode, _, err = downstreamCache.Get(ctx)
+	} else {
+		statusCode, err = fetchDownstream(ctx)
+	}
 	if err != nil {
 		http.Error(w, "Failed to call downstream service", http.StatusInternalServerError)
-		emitLog(ctx, logger, otellog.SeverityError, "Downstream call failed", otellog.String("error", err.Error()))
+		appLog.Error(ctx, "Downstream call failed", logging.Err(err))
 		return
 	}
-	defer res.Body.Close()
 
-	span.SetAttributes(attribute.Int("downstream.status_code", res.StatusCode))
+	span.SetAttributes(attribute.Int("downstream.status_code", statusCode))
 
-	// 3. Simulate final processing
+	// With WORK_ASYNC, the rest runs on the worker pool after the response.
+	if workers != nil {
+		err := workers.Submit(ctx, "work.finish", func(ctx context.Context) error {
+			finishWork(ctx, statusCode, startTime)
+			return nil
+		})
+		if err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too much work queued, try again later", http.StatusServiceUnavailable)
+			appLog.Warn(ctx, "Failed to queue work", logging.Err(err))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "Work accepted!")
+		return
+	}
+	finishWork(ctx, statusCode, startTime)
+	fmt.Fprintln(w, "Work complete!")
+}
+
+// finishWork is the part of /work after the downstream call: it hands
+// follow-up work to the queue and does the final processing, under the
+// span in ctx. The work duration is measured from startTime, so with
+// WORK_ASYNC it includes the time the job waited for a worker.
+func finishWork(ctx context.Context, statusCode int, startTime time.Time) {
+	span := trace.SpanFromContext(ctx)
+
+	// 3. Hand follow-up work to the asynchronous pipeline
+	if workJobs != nil {
+		publishWorkJob(ctx, statusCode)
+	}
+
+	// 4. Simulate final processing
 	time.Sleep(time.Duration(50+rand.Intn(25)) * time.Millisecond)
 	span.AddEvent("Final processing complete")
 
 	duration := time.Since(startTime).Seconds()
 	workDurationHistogram.Record(ctx, duration, metric.WithAttributes(attribute.Bool("success", true)))
 
-	emitLog(ctx, logger, otellog.SeverityInfo, "Complex work finished")
-	fmt.Fprintln(w, "Work complete!")
+	appLog.Info(ctx, "Complex work finished")
+}
+
+// fetchDownstream calls the downstream service and returns the response
+// status. The instrumented client automatically creates a child span.
+func fetchDownstream(ctx context.Context) (int, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:8080/downstream", nil)
+	res, err := downstreamAPIHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	return res.StatusCode, nil
 }
 
 // Endpoint that simulates a backend/downstream service
 func downstreamHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	logger := global.Logger("downstreamHandler")
-
-	_, span := tracer.Start(ctx, "downstreamHandler.databaseQuery")
+	ctx, span := hotOps.Start(r.Context(), "downstreamHandler.databaseQuery")
 	defer span.End()
 
 	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/downstream")))
-	emitLog(ctx, logger, otellog.SeverityInfo, "Downstream service received request")
+	appLog.Info(ctx, "Downstream service received request")
 
-	// Simulate a database query or some other backend task
+	// With a database-backed store, run a real query (traced by otelsql);
+	// otherwise simulate one.
+	if itemRepository != nil {
+		summary, err := itemRepository.Summary(ctx)
+		if err != nil {
+			http.Error(w, "Database query failed", http.StatusInternalServerError)
+			appLog.Error(ctx, "Database query failed", logging.Err(err))
+			return
+		}
+		span.SetAttributes(attribute.Int64("app.items.count", summary.Items))
+		span.AddEvent("Database query finished")
+		fmt.Fprintf(w, "Downstream work done: %d items, %d bytes.\n", summary.Items, summary.Bytes)
+		return
+	}
 	dbQueryTime := time.Duration(100+rand.Intn(150)) * time.Millisecond
 	time.Sleep(dbQueryTime)
 
@@ -281,14 +1243,42 @@ func downstreamHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "Downstream work done.")
 }
 
-// Helper to emit logs with context
-func emitLog(ctx context.Context, logger otellog.Logger, severity otellog.Severity, body string, attrs ...otellog.KeyValue) {
-	record := otellog.Record{}
-	record.SetTimestamp(time.Now())
-	record.SetSeverity(severity)
-	record.SetBody(otellog.StringValue(body))
-	if len(attrs) > 0 {
-		record.AddAttributes(attrs...)
+// Endpoint that shells out to a subprocess to upper-case the request body
+func convertHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/convert")))
+	appLog.Info(ctx, "Converting request body in subprocess")
+
+	out, err := runTracedCommand(ctx, http.MaxBytesReader(w, r.Body, 1<<20), "tr", "a-z", "A-Z")
+	if err != nil {
+		http.Error(w, "Conversion failed", http.StatusInternalServerError)
+		appLog.Error(ctx, "Subprocess failed", logging.Err(err))
+		return
+	}
+
+	w.Write(out)
+}
+
+// defaultAttributeValueLengthLimit caps span attribute values when neither
+// OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT nor OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT
+// is set. The SDK default is unlimited, which let a response body attached
+// as an attribute produce spans the collector rejected.
+const defaultAttributeValueLengthLimit = 4096
+
+// newSpanLimits reads the standard OTEL_SPAN_*_LIMIT, OTEL_EVENT_* and
+// OTEL_LINK_* settings, with OTEL_ATTRIBUTE_*_LIMIT as the fallback the spec
+// gives them, applying defaultAttributeValueLengthLimit instead of the SDK's
+// unlimited value length. They are read here rather than by
+// sdktrace.NewSpanLimits so that CONFIG_FILE can set them too.
+func newSpanLimits() sdktrace.SpanLimits {
+	return sdktrace.SpanLimits{
+		AttributeValueLengthLimit: envInt("OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT",
+			envInt("OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT", defaultAttributeValueLengthLimit)),
+		AttributeCountLimit: envInt("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT",
+			envInt("OTEL_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributeCountLimit)),
+		EventCountLimit:             envInt("OTEL_SPAN_EVENT_COUNT_LIMIT", sdktrace.DefaultEventCountLimit),
+		LinkCountLimit:              envInt("OTEL_SPAN_LINK_COUNT_LIMIT", sdktrace.DefaultLinkCountLimit),
+		AttributePerEventCountLimit: envInt("OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributePerEventCountLimit),
+		AttributePerLinkCountLimit:  envInt("OTEL_LINK_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributePerLinkCountLimit),
 	}
-	logger.Emit(ctx, record)
 }
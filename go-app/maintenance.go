@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/health"
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+)
+
+// maintenanceMode pauses the whole service for maintenance: every
+// application route answers 503 while admin routes keep working. The period
+// is exported as the app.maintenance gauge and rejected request spans carry
+// maintenance=true, so dashboards can exclude it from SLOs.
+type maintenanceMode struct {
+	mu    sync.RWMutex
+	since time.Time // zero when not in maintenance
+}
+
+func newMaintenanceMode(meter metric.Meter, enabled bool) (*maintenanceMode, error) {
+	m := &maintenanceMode{}
+	if enabled {
+		m.since = time.Now()
+	}
+	_, err := metrics.Maintenance.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if m.Active() {
+				o.Observe(1)
+			} else {
+				o.Observe(0)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Active reports whether maintenance mode is on.
+func (m *maintenanceMode) Active() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !m.since.IsZero()
+}
+
+// Set turns maintenance mode on or off and records who did it.
+func (m *maintenanceMode) Set(ctx context.Context, enabled bool, actor string) {
+	m.mu.Lock()
+	was := !m.since.IsZero()
+	var lasted time.Duration
+	switch {
+	case enabled && !was:
+		m.since = time.Now()
+	case !enabled && was:
+		lasted = time.Since(m.since)
+		m.since = time.Time{}
+	}
+	m.mu.Unlock()
+
+	attrs := []logging.Attr{
+		logging.Bool("audit", true),
+		logging.Bool("maintenance", enabled),
+		logging.Bool("previously_maintenance", was),
+		logging.String("actor", actor),
+	}
+	if lasted > 0 {
+		attrs = append(attrs, logging.Duration("maintenance.duration_s", lasted))
+	}
+	appLog.Warn(ctx, "Maintenance mode changed", attrs...)
+}
+
+// Middleware answers every request with 503 while maintenance mode is on.
+// Like the kill switches, it must run inside otelhttp and the HTTP metrics
+// middleware.
+func (m *maintenanceMode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.Active() {
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.Bool("maintenance", true))
+			w.Header().Set("Retry-After", "300")
+			http.Error(w, "The service is down for maintenance.", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Readiness serves the readiness probe from deps, reporting the pod unready
+// while maintenance mode is on so load balancers drain it. The dependencies
+// are still checked, keeping their metrics current.
+func (m *maintenanceMode) Readiness(deps *health.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ready, statuses := deps.Check(r.Context())
+		if m.Active() {
+			ready = false
+			statuses = append(statuses, health.Status{Name: "maintenance", Error: "maintenance mode is on"})
+		}
+		health.WriteStatus(w, ready, statuses)
+	})
+}
+
+// ServeHTTP reports the mode on GET and changes it on PUT or POST, e.g.
+// `curl -X PUT 'localhost:8081/admin/maintenance?enabled=true'`.
+func (m *maintenanceMode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		m.Set(r.Context(), enabled, r.RemoteAddr)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mu.RLock()
+	state := map[string]any{"maintenance": !m.since.IsZero()}
+	if !m.since.IsZero() {
+		state["since"] = m.since.UTC().Format(time.RFC3339)
+	}
+	m.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"my-go-app/pkg/metrics"
+)
+
+// cpuQuota is the CPU limit of the container in cores, as detected from its
+// cgroup, or 0 when it is not limited.
+var cpuQuota float64
+
+// gomaxprocsSource records where GOMAXPROCS came from: "env" (the
+// GOMAXPROCS variable), "cpu_quota" or "default" (the number of host CPUs).
+var gomaxprocsSource = "default"
+
+// CPU files of the container's cgroup, for cgroup v2 and v1.
+const (
+	cgroupV2CPUMax    = "/sys/fs/cgroup/cpu.max"
+	cgroupV1CPUQuota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// setMaxProcs sizes GOMAXPROCS to the container's CPU quota rather than the
+// host's CPU count, unless GOMAXPROCS is set explicitly. In a pod limited to
+// 2 CPUs on a 64-core node the default would run 64 threads that the kernel
+// throttles, which shows up as latency spikes unrelated to the code. The
+// quota is detected either way, and a cgroup that cannot be read only costs
+// the sizing: it is logged and the default kept.
+func setMaxProcs() {
+	quota, err := cgroupCPUQuota()
+	if err != nil {
+		stderrLog.Warn("Failed to read the CPU quota, keeping the default GOMAXPROCS", "error", err)
+		return
+	}
+	cpuQuota = quota
+	if os.Getenv("GOMAXPROCS") != "" {
+		gomaxprocsSource = "env"
+		if quota > 0 {
+			stderrLog.Info("GOMAXPROCS is set, not sizing it to the CPU quota",
+				"gomaxprocs", runtime.GOMAXPROCS(0), "cpu_quota", quota)
+		}
+		return
+	}
+	if !autoMaxProcsEnabled || quota == 0 {
+		return
+	}
+	runtime.GOMAXPROCS(max(1, int(math.Floor(quota))))
+	gomaxprocsSource = "cpu_quota"
+}
+
+// cgroupCPUQuota returns the CPU limit of the container's cgroup in cores,
+// or 0 when it has none or there is no cgroup to read.
+func cgroupCPUQuota() (float64, error) {
+	if b, err := os.ReadFile(cgroupV2CPUMax); err == nil {
+		// "<quota> <period>", or "max <period>" without a limit.
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("%s: unexpected content %q", cgroupV2CPUMax, b)
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		return cpuQuotaRatio(cgroupV2CPUMax, fields[0], fields[1])
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	quota, err := os.ReadFile(cgroupV1CPUQuota)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// -1 without a limit.
+	if strings.TrimSpace(string(quota)) == "-1" {
+		return 0, nil
+	}
+	period, err := os.ReadFile(cgroupV1CPUPeriod)
+	if err != nil {
+		return 0, err
+	}
+	return cpuQuotaRatio(cgroupV1CPUQuota, strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuotaRatio(path, quota, period string) (float64, error) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid quota: %w", path, err)
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("%s: invalid period %q", path, period)
+	}
+	return float64(q) / float64(p), nil
+}
+
+// registerMaxProcsGauges exports the detected CPU quota and the effective
+// GOMAXPROCS.
+func registerMaxProcsGauges(g *gaugeRegistry) error {
+	if cpuQuota > 0 {
+		if err := g.Float64Gauge(metrics.CPUQuota,
+			func(context.Context) float64 { return cpuQuota },
+		); err != nil {
+			return err
+		}
+	}
+	return g.Int64Gauge(metrics.GoMaxProcs,
+		func(context.Context) int64 { return int64(runtime.GOMAXPROCS(0)) },
+		attribute.String("gomaxprocs.source", gomaxprocsSource),
+	)
+}
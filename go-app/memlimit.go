@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	runtimemetrics "runtime/metrics"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"my-go-app/pkg/metrics"
+)
+
+// Memory files of the container's cgroup, for cgroup v2 and v1.
+const (
+	cgroupV2MemoryMax     = "/sys/fs/cgroup/memory.max"
+	cgroupV2MemoryCurrent = "/sys/fs/cgroup/memory.current"
+	cgroupV1MemoryLimit   = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+	cgroupV1MemoryUsage   = "/sys/fs/cgroup/memory/memory.usage_in_bytes"
+)
+
+// cgroupV1Unlimited is the smallest value cgroup v1 reports for "no limit"
+// (PAGE_COUNTER_MAX rounded to pages, depending on the page size).
+const cgroupV1Unlimited = 1 << 62
+
+// Runtime metrics behind the memory-pressure telemetry.
+const (
+	memoryTotalMetric    = "/memory/classes/total:bytes"
+	memoryReleasedMetric = "/memory/classes/heap/released:bytes"
+	gcAssistMetric       = "/cpu/classes/gc/mark/assist:cpu-seconds"
+)
+
+// memoryLimit is the memory limit of the container in bytes, as detected
+// from its cgroup, or 0 when it is not limited.
+var memoryLimit int64
+
+// gomemlimitSource records where GOMEMLIMIT came from: "env" (the GOMEMLIMIT
+// variable), "memory_limit" or "default" (no limit).
+var gomemlimitSource = "default"
+
+// setMemLimit detects the container's memory limit and, with
+// AUTOMEMLIMIT_ENABLED, sets GOMEMLIMIT to AUTOMEMLIMIT_RATIO of it unless
+// GOMEMLIMIT is set explicitly. Without a soft limit the GC only paces
+// itself by GOGC, so a heap that doubles between cycles can cross the
+// container limit and be OOM-killed while mostly garbage.
+func setMemLimit() error {
+	if autoMemLimitRatio <= 0 || autoMemLimitRatio > 1 {
+		return fmt.Errorf("AUTOMEMLIMIT_RATIO must be in (0, 1], got %g", autoMemLimitRatio)
+	}
+	memoryLimit = cgroupMemoryLimit()
+	if os.Getenv("GOMEMLIMIT") != "" {
+		gomemlimitSource = "env"
+		return nil
+	}
+	if !autoMemLimitEnabled || memoryLimit == 0 {
+		return nil
+	}
+	debug.SetMemoryLimit(int64(float64(memoryLimit) * autoMemLimitRatio))
+	gomemlimitSource = "memory_limit"
+	return nil
+}
+
+// gomemlimit returns the effective GOMEMLIMIT, or 0 when there is none.
+func gomemlimit() int64 {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
+
+// cgroupMemoryLimit returns the memory limit of the container's cgroup, or
+// 0 when it has none or it cannot be read.
+func cgroupMemoryLimit() int64 {
+	if v, err := readCgroupInt(cgroupV2MemoryMax); err == nil {
+		return v
+	}
+	if v, err := readCgroupInt(cgroupV1MemoryLimit); err == nil && v < cgroupV1Unlimited {
+		return v
+	}
+	return 0
+}
+
+// cgroupMemoryUsage returns the memory charged to the container's cgroup,
+// including the page cache, which is what the OOM killer compares with the
+// limit.
+func cgroupMemoryUsage() (int64, bool) {
+	for _, path := range []string{cgroupV2MemoryCurrent, cgroupV1MemoryUsage} {
+		if v, err := readCgroupInt(path); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// readCgroupInt reads a cgroup file holding one integer; "max" reads as 0.
+func readCgroupInt(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// registerMemLimitGauges exports the detected memory limit, the effective
+// GOMEMLIMIT, how close the process is to its limit and the CPU time
+// goroutines spent assisting the GC, which rises sharply as the heap nears
+// GOMEMLIMIT. Lined up with request latency, they show whether an OOM kill
+// was preceded by GC thrashing or by a sudden allocation spike.
+func registerMemLimitGauges(g *gaugeRegistry) error {
+	if memoryLimit > 0 {
+		if err := g.Int64Gauge(metrics.MemoryLimit,
+			func(context.Context) int64 { return memoryLimit },
+		); err != nil {
+			return err
+		}
+	}
+	if _, ok := cgroupMemoryUsage(); ok {
+		if err := g.Int64Gauge(metrics.MemoryUsage,
+			func(context.Context) int64 {
+				v, _ := cgroupMemoryUsage()
+				return v
+			},
+		); err != nil {
+			return err
+		}
+	}
+	if err := g.Int64Gauge(metrics.GoMemLimit,
+		func(context.Context) int64 { return gomemlimit() },
+		attribute.String("gomemlimit.source", gomemlimitSource),
+	); err != nil {
+		return err
+	}
+	if memoryLimit > 0 || gomemlimit() > 0 {
+		if err := g.Float64Gauge(metrics.MemoryUtilization,
+			func(context.Context) float64 { return memoryUtilization() },
+		); err != nil {
+			return err
+		}
+	}
+	return g.Float64Counter(metrics.GCAssistTime,
+		func(context.Context) float64 {
+			sample := []runtimemetrics.Sample{{Name: gcAssistMetric}}
+			runtimemetrics.Read(sample)
+			if sample[0].Value.Kind() != runtimemetrics.KindFloat64 {
+				return 0
+			}
+			return sample[0].Value.Float64()
+		},
+	)
+}
+
+// memoryUtilization returns the memory the runtime has mapped and not
+// returned to the OS, as a fraction of the applicable limit.
+func memoryUtilization() float64 {
+	limit := memoryLimit
+	if limit == 0 {
+		limit = gomemlimit()
+	}
+	if limit == 0 {
+		return 0
+	}
+	samples := []runtimemetrics.Sample{{Name: memoryTotalMetric}, {Name: memoryReleasedMetric}}
+	runtimemetrics.Read(samples)
+	var inUse uint64
+	if samples[0].Value.Kind() == runtimemetrics.KindUint64 && samples[1].Value.Kind() == runtimemetrics.KindUint64 {
+		inUse = samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	}
+	return float64(inUse) / float64(limit)
+}
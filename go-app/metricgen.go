@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"my-go-app/pkg/metrics"
+)
+
+// metricgenConfig controls how many series the metricgen subcommand emits
+// and how quickly their label sets are replaced.
+type metricgenConfig struct {
+	series   int
+	labels   int
+	churn    float64
+	interval time.Duration
+	duration time.Duration
+}
+
+func parseMetricgenFlags(args []string) (metricgenConfig, error) {
+	var cfg metricgenConfig
+	fs := flag.NewFlagSet("metricgen", flag.ContinueOnError)
+	fs.IntVar(&cfg.series, "series", 1000, "number of concurrently active series")
+	fs.IntVar(&cfg.labels, "labels", 3, "number of extra labels attached to every series")
+	fs.Float64Var(&cfg.churn, "churn", 0.1, "fraction (0-1) of series replaced by new label sets every interval")
+	fs.DurationVar(&cfg.interval, "interval", 10*time.Second, "how often every active series is updated")
+	fs.DurationVar(&cfg.duration, "duration", 0, "how long to run (0 runs until interrupted)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case cfg.series <= 0:
+		return cfg, errors.New("-series must be positive")
+	case cfg.labels < 0:
+		return cfg, errors.New("-labels must not be negative")
+	case cfg.churn < 0 || cfg.churn > 1:
+		return cfg, errors.New("-churn must be between 0 and 1")
+	case cfg.interval <= 0:
+		return cfg, errors.New("-interval must be positive")
+	case cfg.duration < 0:
+		return cfg, errors.New("-duration must not be negative")
+	}
+	return cfg, nil
+}
+
+// runMetricgen keeps cfg.series series alive on a counter and a histogram,
+// replacing a fraction of them with never-seen-before label sets on every
+// tick to simulate label churn.
+func runMetricgen(ctx context.Context, args []string) error {
+	cfg, err := parseMetricgenFlags(args)
+	if err != nil {
+		return err
+	}
+
+	counter, err := metrics.MetricgenEvents.Int64Counter(meter)
+	if err != nil {
+		return err
+	}
+	histogram, err := metrics.MetricgenLatency.Float64Histogram(meter)
+	if err != nil {
+		return err
+	}
+
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+
+	logger.InfoContext(ctx, "metricgen: emitting series", "series", cfg.series, "labels", cfg.labels, "churn", cfg.churn, "interval", cfg.interval)
+
+	active := make([]int, cfg.series)
+	nextID := 0
+	for i := range active {
+		active[i] = nextID
+		nextID++
+	}
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, id := range active {
+			opt := metric.WithAttributeSet(metricgenAttributes(id, cfg.labels))
+			counter.Add(ctx, 1, opt)
+			histogram.Record(ctx, rand.ExpFloat64()/10, opt)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "metricgen: stopped", "distinct_series", nextID)
+			return nil
+		case <-ticker.C:
+		}
+
+		churned := int(float64(cfg.series) * cfg.churn)
+		for _, i := range rand.Perm(cfg.series)[:churned] {
+			active[i] = nextID
+			nextID++
+		}
+	}
+}
+
+func metricgenAttributes(id, labels int) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, labels+1)
+	attrs = append(attrs, attribute.Int("metricgen.series", id))
+	for n := 0; n < labels; n++ {
+		attrs = append(attrs, attribute.String(fmt.Sprintf("metricgen.label_%d", n), fmt.Sprintf("value-%d-%d", id, n)))
+	}
+	return attribute.NewSet(attrs...)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// intervalReader collects and exports metrics every interval, like
+// sdkmetric.PeriodicReader, except that the interval can be changed while it
+// runs (see SetInterval). timeout bounds a single collection and export.
+type intervalReader struct {
+	*sdkmetric.ManualReader
+
+	exporter sdkmetric.Exporter
+	timeout  time.Duration
+	interval atomic.Int64
+	reset    chan struct{}
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newIntervalReader starts exporting every interval, which must be
+// positive.
+func newIntervalReader(exporter sdkmetric.Exporter, interval, timeout time.Duration, opts ...sdkmetric.ManualReaderOption) *intervalReader {
+	opts = append([]sdkmetric.ManualReaderOption{
+		sdkmetric.WithTemporalitySelector(exporter.Temporality),
+		sdkmetric.WithAggregationSelector(exporter.Aggregation),
+	}, opts...)
+	r := &intervalReader{
+		ManualReader: sdkmetric.NewManualReader(opts...),
+		exporter:     exporter,
+		timeout:      timeout,
+		reset:        make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	r.interval.Store(int64(interval))
+	go r.run()
+	return r
+}
+
+// Interval returns the current export interval.
+func (r *intervalReader) Interval() time.Duration {
+	return time.Duration(r.interval.Load())
+}
+
+// SetInterval changes the export interval; the next export happens one new
+// interval from now. A non-positive interval is ignored, since the timer
+// would fire continuously.
+func (r *intervalReader) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	r.interval.Store(int64(d))
+	select {
+	case r.reset <- struct{}{}:
+	default:
+	}
+}
+
+func (r *intervalReader) run() {
+	defer close(r.done)
+	timer := time.NewTimer(r.Interval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+			if err := r.export(ctx); err != nil {
+				otel.Handle(err)
+			}
+			cancel()
+		case <-r.reset:
+		case <-r.stop:
+			return
+		}
+		timer.Reset(r.Interval())
+	}
+}
+
+func (r *intervalReader) export(ctx context.Context) error {
+	var rm metricdata.ResourceMetrics
+	if err := r.Collect(ctx, &rm); err != nil {
+		return err
+	}
+	return r.exporter.Export(ctx, &rm)
+}
+
+// ForceFlush exports the current metrics immediately.
+func (r *intervalReader) ForceFlush(ctx context.Context) error {
+	return errors.Join(r.export(ctx), r.exporter.ForceFlush(ctx))
+}
+
+// Shutdown stops the periodic export, exports one last time and shuts the
+// exporter down.
+func (r *intervalReader) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	return errors.Join(r.export(ctx), r.ManualReader.Shutdown(ctx), r.exporter.Shutdown(ctx))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// countingExporter counts exports.
+type countingExporter struct {
+	exports chan struct{}
+}
+
+func (e countingExporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+func (e countingExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e countingExporter) Export(context.Context, *metricdata.ResourceMetrics) error {
+	select {
+	case e.exports <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (countingExporter) ForceFlush(context.Context) error { return nil }
+func (countingExporter) Shutdown(context.Context) error   { return nil }
+
+func TestIntervalReaderIgnoresNonPositiveInterval(t *testing.T) {
+	exporter := countingExporter{exports: make(chan struct{}, 1)}
+	r := newIntervalReader(exporter, time.Hour, time.Second)
+	sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+	defer r.Shutdown(context.Background())
+
+	r.SetInterval(0)
+	r.SetInterval(-time.Second)
+	if got := r.Interval(); got != time.Hour {
+		t.Errorf("Interval() = %s, want it unchanged at 1h", got)
+	}
+	select {
+	case <-exporter.exports:
+		t.Error("exported right away, want the next export an hour out")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
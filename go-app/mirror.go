@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+)
+
+// mirrorMaxBody bounds the request bodies buffered for mirroring; larger
+// requests are not mirrored.
+const mirrorMaxBody = 1 << 20
+
+// trafficMirror duplicates a percentage of inbound requests to a shadow
+// backend for canary comparison. Mirrored calls run in the background with
+// their responses discarded; they are traced as children of the inbound
+// request span with shadow=true so they can be told apart from real traffic.
+type trafficMirror struct {
+	client  *http.Client
+	percent float64
+}
+
+func newTrafficMirror(target string, percent float64, timeout time.Duration) (*trafficMirror, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid mirror URL %q", target)
+	}
+	opts := append(clientTraceOptions("mirror"),
+		otelhttp.WithSpanOptions(trace.WithAttributes(attribute.Bool("shadow", true))),
+	)
+	transport := otelhttp.NewTransport(mirrorTransport{http.DefaultTransport, u}, opts...)
+	return &trafficMirror{
+		client:  &http.Client{Transport: transport, Timeout: timeout},
+		percent: percent,
+	}, nil
+}
+
+// Middleware mirrors the selected requests before passing them on to next.
+// It must run inside otelhttp so mirrored calls join the request's trace.
+func (m *trafficMirror) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never mirror mirrored traffic, in case the shadow routes back here.
+		if r.Header.Get("X-Shadow-Request") == "" && rand.Float64()*100 < m.percent {
+			m.mirror(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *trafficMirror) mirror(r *http.Request) {
+	// Buffer the body so both the handler and the mirror can read it,
+	// giving up on bodies too large to hold in memory.
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(r.Body, mirrorMaxBody+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		if err != nil || len(buf) > mirrorMaxBody {
+			return
+		}
+		body = buf
+	}
+
+	// The mirrored call outlives the inbound request but keeps its trace.
+	ctx := context.WithoutCancel(r.Context())
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Set("X-Shadow-Request", "true")
+
+	go func() {
+		res, err := m.client.Do(req)
+		if err != nil {
+			appLog.Warn(ctx, "Mirrored request failed", logging.Err(err))
+			return
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}()
+}
+
+// mirrorTransport sends requests to the shadow backend, keeping their path
+// and query under the backend URL's path.
+type mirrorTransport struct {
+	base   http.RoundTripper
+	target *url.URL
+}
+
+func (t mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = t.target.Scheme
+	out.URL.Host = t.target.Host
+	out.URL.Path = strings.TrimSuffix(t.target.Path, "/") + req.URL.Path
+	out.Host = ""
+	return t.base.RoundTrip(out)
+}
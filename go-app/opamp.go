@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opamp-go/client"
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"gopkg.in/yaml.v3"
+
+	"my-go-app/pkg/health"
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/telemetry"
+)
+
+// opampHealthInterval is how often dependency health is reported.
+const opampHealthInterval = 30 * time.Second
+
+// opampAgent connects the service to an OpAMP server for central
+// management. It reports the service's identity, its health (the readiness
+// dependencies) and its effective configuration, and accepts remote
+// configuration that overrides the sampling ratio and log level of this
+// instance (see configReloader.SetRemote).
+//
+// The remote configuration is YAML (or JSON) of the form
+//
+//	sampling: {ratio: 0.1}
+//	logs: {level: debug}
+//
+// Removing a setting from it restores the local value.
+type opampAgent struct {
+	client   client.OpAMPClient
+	reloader *configReloader
+	deps     *health.Registry
+	start    time.Time
+}
+
+// startOpAMP connects to the OpAMP server at serverURL, over WebSocket for
+// ws:// and wss:// URLs and plain HTTP polling for http:// and https://.
+func startOpAMP(ctx context.Context, serverURL string, reloader *configReloader, deps *health.Registry) (*opampAgent, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPAMP_SERVER_URL: %w", err)
+	}
+	a := &opampAgent{reloader: reloader, deps: deps, start: time.Now()}
+	switch u.Scheme {
+	case "ws", "wss":
+		a.client = client.NewWebSocket(opampLogger{})
+	case "http", "https":
+		a.client = client.NewHTTP(opampLogger{})
+	default:
+		return nil, fmt.Errorf("invalid OPAMP_SERVER_URL scheme %q, want ws, wss, http or https", u.Scheme)
+	}
+
+	if err := a.client.SetAgentDescription(a.description()); err != nil {
+		return nil, fmt.Errorf("failed to set OpAMP agent description: %w", err)
+	}
+	if err := a.client.SetHealth(a.health(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to set OpAMP health: %w", err)
+	}
+	caps := protobufs.AgentCapabilities_AgentCapabilities_ReportsStatus |
+		protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig |
+		protobufs.AgentCapabilities_AgentCapabilities_ReportsRemoteConfig |
+		protobufs.AgentCapabilities_AgentCapabilities_ReportsEffectiveConfig |
+		protobufs.AgentCapabilities_AgentCapabilities_ReportsHealth
+	if err := a.client.SetCapabilities(&caps); err != nil {
+		return nil, fmt.Errorf("failed to set OpAMP capabilities: %w", err)
+	}
+	err = a.client.Start(ctx, types.StartSettings{
+		OpAMPServerURL: serverURL,
+		InstanceUid:    types.InstanceUid(uuid.NewSHA1(uuid.NameSpaceURL, []byte(instanceID()))),
+		Callbacks: types.Callbacks{
+			OnConnect: func(ctx context.Context) {
+				appLog.Info(ctx, "Connected to OpAMP server", logging.String("opamp.server", serverURL))
+			},
+			OnConnectFailed: func(ctx context.Context, err error) {
+				appLog.Warn(ctx, "OpAMP connection failed", logging.String("opamp.server", serverURL), logging.Err(err))
+			},
+			OnMessage:          a.onMessage,
+			GetEffectiveConfig: a.effectiveConfig,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OpAMP client: %w", err)
+	}
+	go a.reportHealth(ctx)
+	return a, nil
+}
+
+// Stop disconnects from the server.
+func (a *opampAgent) Stop(ctx context.Context) error {
+	return a.client.Stop(ctx)
+}
+
+func (a *opampAgent) description() *protobufs.AgentDescription {
+	return &protobufs.AgentDescription{
+		IdentifyingAttributes: []*protobufs.KeyValue{
+			opampString(string(semconv.ServiceNameKey), serviceName),
+			opampString(string(semconv.ServiceInstanceIDKey), instanceID()),
+			opampString(string(semconv.ServiceVersionKey), telemetry.Version),
+		},
+		NonIdentifyingAttributes: []*protobufs.KeyValue{
+			opampString(string(semconv.OSTypeKey), runtime.GOOS),
+			opampString(string(semconv.HostArchKey), runtime.GOARCH),
+			opampString(string(semconv.ProcessRuntimeVersionKey), runtime.Version()),
+		},
+	}
+}
+
+func (a *opampAgent) health(ctx context.Context) *protobufs.ComponentHealth {
+	now := uint64(time.Now().UnixNano())
+	healthy, statuses := a.deps.Check(ctx)
+	h := &protobufs.ComponentHealth{
+		Healthy:            healthy,
+		StartTimeUnixNano:  uint64(a.start.UnixNano()),
+		StatusTimeUnixNano: now,
+		Status:             "ready",
+		ComponentHealthMap: make(map[string]*protobufs.ComponentHealth, len(statuses)),
+	}
+	if !healthy {
+		h.Status = "unready"
+	}
+	for _, s := range statuses {
+		h.ComponentHealthMap[s.Name] = &protobufs.ComponentHealth{
+			Healthy:            s.Healthy,
+			LastError:          s.Error,
+			StatusTimeUnixNano: now,
+		}
+	}
+	return h
+}
+
+func (a *opampAgent) reportHealth(ctx context.Context) {
+	ticker := time.NewTicker(opampHealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.client.SetHealth(a.health(ctx)); err != nil {
+				appLog.Debug(ctx, "Failed to report OpAMP health", logging.Err(err))
+			}
+		}
+	}
+}
+
+func (a *opampAgent) onMessage(ctx context.Context, msg *types.MessageData) {
+	rc := msg.RemoteConfig
+	if rc == nil {
+		return
+	}
+	status := &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: rc.ConfigHash,
+		Status:               protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED,
+	}
+	overrides, err := parseRemoteConfig(rc.GetConfig())
+	if err == nil {
+		_, err = a.reloader.SetRemote(ctx, overrides, "opamp")
+	}
+	if err != nil {
+		appLog.Error(ctx, "Rejected OpAMP remote configuration", logging.Err(err))
+		status.Status = protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED
+		status.ErrorMessage = err.Error()
+	}
+	if err := a.client.SetRemoteConfigStatus(status); err != nil {
+		appLog.Debug(ctx, "Failed to report OpAMP remote config status", logging.Err(err))
+	}
+	if err := a.client.UpdateEffectiveConfig(ctx); err != nil {
+		appLog.Debug(ctx, "Failed to report OpAMP effective config", logging.Err(err))
+	}
+}
+
+// effectiveConfig reports the configuration served at /debug/config, with
+// the sampling ratio and log level currently in effect.
+func (a *opampAgent) effectiveConfig(context.Context) (*protobufs.EffectiveConfig, error) {
+	doc := configDocument()
+	applied := a.reloader.Applied()
+	doc["sampling_ratio"] = applied.sampleRatio
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufs.EffectiveConfig{
+		ConfigMap: &protobufs.AgentConfigMap{
+			ConfigMap: map[string]*protobufs.AgentConfigFile{
+				"": {Body: body, ContentType: "application/json"},
+			},
+		},
+	}, nil
+}
+
+// remoteConfig is the schema of the remote configuration.
+type remoteConfig struct {
+	Sampling struct {
+		Ratio *float64 `yaml:"ratio"`
+	} `yaml:"sampling"`
+	Logs struct {
+		Level string `yaml:"level"`
+	} `yaml:"logs"`
+}
+
+// parseRemoteConfig merges the files of the remote configuration in name
+// order into overrides.
+func parseRemoteConfig(cfg *protobufs.AgentConfigMap) (remoteOverrides, error) {
+	var o remoteOverrides
+	files := cfg.GetConfigMap()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		var rc remoteConfig
+		dec := yaml.NewDecoder(bytes.NewReader(files[name].GetBody()))
+		dec.KnownFields(true)
+		if err := dec.Decode(&rc); err != nil && !errors.Is(err, io.EOF) {
+			return remoteOverrides{}, fmt.Errorf("invalid remote config %q: %w", name, err)
+		}
+		if rc.Sampling.Ratio != nil {
+			o.sampleRatio = rc.Sampling.Ratio
+		}
+		if rc.Logs.Level != "" {
+			o.logLevel = rc.Logs.Level
+		}
+	}
+	return o, nil
+}
+
+// instanceID returns the service.instance.id of the resource.
+func instanceID() string {
+	if serviceResource != nil {
+		if v, ok := serviceResource.Set().Value(semconv.ServiceInstanceIDKey); ok {
+			return v.AsString()
+		}
+	}
+	return ""
+}
+
+func opampString(key, value string) *protobufs.KeyValue {
+	return &protobufs.KeyValue{
+		Key:   key,
+		Value: &protobufs.AnyValue{Value: &protobufs.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// opampLogger routes the OpAMP client's own diagnostics to the application
+// log.
+type opampLogger struct{}
+
+func (opampLogger) Debugf(ctx context.Context, format string, v ...any) {
+	appLog.Debug(ctx, fmt.Sprintf(format, v...))
+}
+
+func (opampLogger) Errorf(ctx context.Context, format string, v ...any) {
+	appLog.Error(ctx, fmt.Sprintf(format, v...))
+}
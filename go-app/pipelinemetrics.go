@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// pipelineMetrics holds the self-observability instruments of the telemetry
+// pipeline: how many spans were queued, how many items each exporter sent or
+// failed to send, how long exports took, and how many spans and log records
+// the batch processors dropped. It also counts export requests split for
+// exceeding the maximum message size, items dropped because they exceeded
+// it on their own, and the memory held by the export buffers.
+type pipelineMetrics struct {
+	spansQueued     metric.Int64Counter
+	exportItems     metric.Int64Counter
+	exportFailures  metric.Int64Counter
+	exportDuration  metric.Float64Histogram
+	exportSplits    metric.Int64Counter
+	exportOversized metric.Int64Counter
+	bufferDropped   metric.Int64Counter
+
+	// Drop counts are only reported by the SDK through its internal
+	// logger, see sdkLogSink.
+	spansDropped atomic.Int64
+	logsDropped  atomic.Int64
+
+	// failingSince holds, per signal, when the current run of failed
+	// exports started; signals whose last export succeeded are absent.
+	mu           sync.Mutex
+	failingSince map[string]time.Time
+	buffers      []bufferUsage
+	// totals accumulate, per signal, what the shutdown summary reports.
+	totals map[string]*signalTotals
+}
+
+// bufferUsage reports what an exportBuffer holds, see addBuffer.
+type bufferUsage interface {
+	usage() (signal string, bytes, limit int64)
+}
+
+func newPipelineMetrics(meter metric.Meter) (*pipelineMetrics, error) {
+	var (
+		m   = pipelineMetrics{failingSince: make(map[string]time.Time), totals: make(map[string]*signalTotals)}
+		err error
+	)
+	m.spansQueued, err = metrics.TelemetrySpansQueued.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.exportItems, err = metrics.TelemetryExportItems.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.exportFailures, err = metrics.TelemetryExportFailures.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.exportDuration, err = metrics.TelemetryExportDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.exportSplits, err = metrics.TelemetryExportSplits.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.exportOversized, err = metrics.TelemetryExportOversized.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.bufferDropped, err = metrics.TelemetryBufferDropped.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := metrics.TelemetryBufferMemory.Int64ObservableGauge(meter)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := metrics.TelemetryBufferMemoryLimit.Int64ObservableGauge(meter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		buffers := m.buffers
+		m.mu.Unlock()
+		for _, b := range buffers {
+			signal, bytes, max := b.usage()
+			attrs := metric.WithAttributes(attribute.String("signal", signal))
+			o.ObserveInt64(memory, bytes, attrs)
+			o.ObserveInt64(limit, max, attrs)
+		}
+		return nil
+	}, memory, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = metrics.TelemetrySpansDropped.Int64ObservableCounter(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(m.spansDropped.Load())
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = metrics.TelemetryLogsDropped.Int64ObservableCounter(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(m.logsDropped.Load())
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *pipelineMetrics) recordExport(ctx context.Context, signal string, items int, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		m.exportFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", signal)))
+	}
+	m.exportItems.Add(ctx, int64(items), metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("outcome", outcome),
+	))
+	m.exportDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attribute.String("signal", signal)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.signalTotals(signal)
+	if err == nil {
+		t.Exported += int64(items)
+		delete(m.failingSince, signal)
+		return
+	}
+	t.Failed += int64(items)
+	t.FailedExports++
+	if _, ok := m.failingSince[signal]; !ok {
+		m.failingSince[signal] = start
+	}
+}
+
+// recordBufferDrop counts n items of signal dropped by a full export
+// buffer.
+func (m *pipelineMetrics) recordBufferDrop(ctx context.Context, signal string, n int) {
+	m.bufferDropped.Add(ctx, int64(n), metric.WithAttributes(attribute.String("signal", signal)))
+	m.mu.Lock()
+	m.signalTotals(signal).Dropped += int64(n)
+	m.mu.Unlock()
+}
+
+// signalTotals returns the totals of signal. m.mu must be held.
+func (m *pipelineMetrics) signalTotals(signal string) *signalTotals {
+	t, ok := m.totals[signal]
+	if !ok {
+		t = &signalTotals{}
+		m.totals[signal] = t
+	}
+	return t
+}
+
+// Totals returns what every signal exported, failed to export and dropped
+// so far, drops by the batch processors included.
+func (m *pipelineMetrics) Totals() map[string]signalTotals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totals := make(map[string]signalTotals, len(m.totals)+2)
+	for signal, t := range m.totals {
+		totals[signal] = *t
+	}
+	for signal, dropped := range map[string]int64{"traces": m.spansDropped.Load(), "logs": m.logsDropped.Load()} {
+		t := totals[signal]
+		t.Dropped += dropped
+		totals[signal] = t
+	}
+	return totals
+}
+
+// addBuffer reports b in the buffer memory gauges.
+func (m *pipelineMetrics) addBuffer(b bufferUsage) {
+	m.mu.Lock()
+	m.buffers = append(m.buffers, b)
+	m.mu.Unlock()
+}
+
+// failingLongest returns the signal whose exports have been failing without
+// interruption for the longest time, and for how long; ok is false when the
+// last export of every signal succeeded.
+func (m *pipelineMetrics) failingLongest() (signal string, d time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for s, since := range m.failingSince {
+		if age := time.Since(since); age > d {
+			signal, d, ok = s, age, true
+		}
+	}
+	return signal, d, ok
+}
+
+// instrumentedSpanExporter records pipelineMetrics for every export.
+type instrumentedSpanExporter struct {
+	sdktrace.SpanExporter
+	metrics *pipelineMetrics
+}
+
+func (e instrumentedSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.metrics.recordExport(ctx, "traces", len(spans), start, err)
+	return err
+}
+
+// instrumentedLogExporter records pipelineMetrics for every export.
+type instrumentedLogExporter struct {
+	sdklog.Exporter
+	metrics *pipelineMetrics
+}
+
+func (e instrumentedLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.metrics.recordExport(ctx, "logs", len(records), start, err)
+	return err
+}
+
+// instrumentedMetricExporter records pipelineMetrics for every export.
+type instrumentedMetricExporter struct {
+	sdkmetric.Exporter
+	metrics *pipelineMetrics
+}
+
+func (e instrumentedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	items := 0
+	for _, sm := range rm.ScopeMetrics {
+		items += len(sm.Metrics)
+	}
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.metrics.recordExport(ctx, "metrics", items, start, err)
+	return err
+}
+
+// queueCountingProcessor counts the sampled spans passed on to the wrapped
+// processor.
+type queueCountingProcessor struct {
+	sdktrace.SpanProcessor
+	metrics *pipelineMetrics
+}
+
+func (p queueCountingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.metrics.spansQueued.Add(context.Background(), 1)
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// sdkLogSink is installed as the OTel SDK's internal logger. The batch
+// processors only report dropped items through that logger, so the sink
+// extracts the drop counts into pipelineMetrics. Everything at the default
+// verbosity is forwarded to stderr as before.
+type sdkLogSink struct {
+	metrics  *pipelineMetrics
+	fallback logr.Logger
+}
+
+func newSDKLogger(m *pipelineMetrics) logr.Logger {
+	return logr.New(&sdkLogSink{
+		metrics:  m,
+		fallback: stdr.New(log.New(os.Stderr, "", log.LstdFlags)),
+	})
+}
+
+func (s *sdkLogSink) Init(logr.RuntimeInfo) {}
+
+// Enabled reports true for every level, the batch span processor only
+// reports its drop count at debug verbosity.
+func (s *sdkLogSink) Enabled(int) bool { return true }
+
+func (s *sdkLogSink) Info(level int, msg string, kv ...any) {
+	switch msg {
+	case "exporting spans":
+		// total_dropped is cumulative since the processor started.
+		if n, ok := kvInt(kv, "total_dropped"); ok {
+			s.metrics.spansDropped.Store(n)
+		}
+	case "dropped log records":
+		// dropped is the delta since the previous report.
+		if n, ok := kvInt(kv, "dropped"); ok {
+			s.metrics.logsDropped.Add(n)
+		}
+	}
+	if level == 0 {
+		s.fallback.Info(msg, kv...)
+	}
+}
+
+func (s *sdkLogSink) Error(err error, msg string, kv ...any) {
+	s.fallback.Error(err, msg, kv...)
+}
+
+func (s *sdkLogSink) WithValues(kv ...any) logr.LogSink {
+	return &sdkLogSink{metrics: s.metrics, fallback: s.fallback.WithValues(kv...)}
+}
+
+func (s *sdkLogSink) WithName(name string) logr.LogSink {
+	return &sdkLogSink{metrics: s.metrics, fallback: s.fallback.WithName(name)}
+}
+
+// kvInt looks up key in a logr key/value list and returns it as an int64.
+func kvInt(kv []any, key string) (int64, bool) {
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); !ok || k != key {
+			continue
+		}
+		switch v := kv[i+1].(type) {
+		case int:
+			return int64(v), true
+		case int64:
+			return v, true
+		case uint32:
+			return int64(v), true
+		case uint64:
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
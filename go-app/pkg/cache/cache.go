@@ -0,0 +1,23 @@
+// Package cache defines the shared response cache in front of slow
+// dependencies, backed by Redis, and an instrumentation decorator that
+// traces every operation.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get for keys that are not cached or have expired.
+var ErrMiss = errors.New("cache: miss")
+
+// Cache is a key-value cache with per-entry expiry.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set caches value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Ping checks that the cache is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumented decorates a Cache with a client span per operation, labeled
+// with the backend as db.system.
+type instrumented struct {
+	next   Cache
+	system string
+	tracer trace.Tracer
+}
+
+// Instrument wraps c so every operation is traced under the db.system of
+// backend, such as "redis". Lookups record cache.hit on their span; a
+// failed Get is not a hit.
+func Instrument(c Cache, backend string, tracer trace.Tracer) Cache {
+	return &instrumented{next: c, system: backend, tracer: tracer}
+}
+
+func (c *instrumented) Get(ctx context.Context, key string) ([]byte, error) {
+	var v []byte
+	err := c.observe(ctx, "GET", func(ctx context.Context) error {
+		var err error
+		v, err = c.next.Get(ctx, key)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", err == nil))
+		return err
+	})
+	return v, err
+}
+
+func (c *instrumented) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.observe(ctx, "SET", func(ctx context.Context) error {
+		return c.next.Set(ctx, key, value, ttl)
+	})
+}
+
+// Ping is not traced: it is called by health checks, not by requests.
+func (c *instrumented) Ping(ctx context.Context) error { return c.next.Ping(ctx) }
+
+func (c *instrumented) Close() error { return c.next.Close() }
+
+func (c *instrumented) observe(ctx context.Context, op string, fn func(context.Context) error) error {
+	ctx, span := c.tracer.Start(ctx, "cache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(c.system),
+			semconv.DBOperation(op),
+		),
+	)
+	defer span.End()
+
+	err := fn(ctx)
+	// A miss is an expected outcome, not a cache failure.
+	if err != nil && !errors.Is(err, ErrMiss) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
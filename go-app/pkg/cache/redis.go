@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redis is a Cache backed by Redis string keys under the "cache:" prefix,
+// shared by every replica. The client is instrumented with redisotel, so
+// each command also gets its own client span (db.system=redis, with the
+// command as db.statement) under the cache span, and the connection pool
+// is exported as the db.client.connections.* metrics.
+type Redis struct {
+	client *redis.Client
+}
+
+// OpenRedis connects to the Redis server at url (redis://host:port/db),
+// tracing and measuring its commands with tp and mp.
+func OpenRedis(ctx context.Context, url string, tp trace.TracerProvider, mp metric.MeterProvider) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := redisotel.InstrumentTracing(client, redisotel.WithTracerProvider(tp)); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to instrument redis tracing: %w", err)
+	}
+	if err := redisotel.InstrumentMetrics(client, redisotel.WithMeterProvider(mp)); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to instrument redis metrics: %w", err)
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to cache redis: %w", err)
+	}
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.client.Get(ctx, "cache:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return v, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, "cache:"+key, value, ttl).Err()
+}
+
+func (r *Redis) Ping(ctx context.Context) error { return r.client.Ping(ctx).Err() }
+
+func (r *Redis) Close() error { return r.client.Close() }
@@ -0,0 +1,197 @@
+package dependency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/telemetry"
+)
+
+// ErrCircuitOpen is returned for calls the circuit breaker short-circuits.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Span attributes set by the circuit breaker on the caller's span.
+const (
+	BreakerStateKey          = attribute.Key("circuit_breaker.state")
+	BreakerShortCircuitedKey = attribute.Key("circuit_breaker.short_circuited")
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	// Closed lets every call through.
+	Closed BreakerState = "closed"
+	// Open fails calls straight away with ErrCircuitOpen.
+	Open BreakerState = "open"
+	// HalfOpen lets a single probe call through to test the dependency.
+	HalfOpen BreakerState = "half_open"
+)
+
+var breakerStates = []BreakerState{Closed, Open, HalfOpen}
+
+// CircuitBreaker returns a transport that stops sending requests through
+// base once p.BreakerFailures calls in a row failed with a retryable error
+// (see Policy.Classify), so a dependency that is down is not waited on by
+// every request. After p.BreakerCooldown it lets one probe through: the
+// breaker closes if the probe succeeds and opens again if it fails. Fatal
+// failures, such as 4xx responses, count as successes: the dependency
+// answered.
+//
+// Wrap Retry with it, so a call and its retries are one outcome. The
+// caller's span records circuit_breaker.state, and
+// circuit_breaker.short_circuited for calls failed with ErrCircuitOpen.
+// State changes are logged, and app.dependency.circuit_breaker.state
+// reports 1 for the current state and 0 for the others.
+func CircuitBreaker(scope telemetry.InstrumentationScope, p Policy, base http.RoundTripper) (http.RoundTripper, error) {
+	b := &breaker{policy: p, base: base, log: logging.New(scope), state: Closed}
+	dep := NameKey.String(p.Name)
+	_, err := metrics.DependencyCircuitBreakerState.Int64ObservableGauge(scope.Meter(),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			b.mu.Lock()
+			current := b.state
+			b.mu.Unlock()
+			for _, s := range breakerStates {
+				v := int64(0)
+				if s == current {
+					v = 1
+				}
+				o.Observe(v, metric.WithAttributes(dep, BreakerStateKey.String(string(s))))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+type breaker struct {
+	policy Policy
+	base   http.RoundTripper
+	log    *logging.Logger
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // consecutive, while closed
+	openedAt time.Time // while open
+	probing  bool      // a probe is in flight, while half-open
+}
+
+func (b *breaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if b.policy.BreakerFailures <= 0 {
+		return b.base.RoundTrip(req)
+	}
+	state, ok := b.admit(ctx)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(BreakerStateKey.String(string(state)))
+	if !ok {
+		span.SetAttributes(BreakerShortCircuitedKey.Bool(true))
+		return nil, ErrCircuitOpen
+	}
+
+	res, err := b.base.RoundTrip(req)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up; that says nothing about the dependency.
+		b.release(state)
+		return res, err
+	}
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	b.record(ctx, state, b.policy.Classify(err, status) == Retryable)
+	return res, err
+}
+
+// release ends a call admitted in state without counting its outcome.
+func (b *breaker) release(state BreakerState) {
+	if state != HalfOpen {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// admit decides whether a call may go through, returning the state it was
+// admitted (or refused) in.
+func (b *breaker) admit(ctx context.Context) (BreakerState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.policy.BreakerCooldown {
+		b.transition(ctx, HalfOpen)
+	}
+	switch b.state {
+	case Open:
+		return Open, false
+	case HalfOpen:
+		if b.probing {
+			return HalfOpen, false
+		}
+		b.probing = true
+		return HalfOpen, true
+	}
+	return Closed, true
+}
+
+// record counts the outcome of a call admitted in state.
+func (b *breaker) record(ctx context.Context, state BreakerState, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state == HalfOpen {
+		b.probing = false
+		if failed {
+			b.transition(ctx, Open)
+		} else {
+			b.transition(ctx, Closed)
+		}
+		return
+	}
+	if b.state != Closed {
+		return // a probe decided meanwhile
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.policy.BreakerFailures {
+		b.transition(ctx, Open)
+	}
+}
+
+// transition moves the breaker to state and logs the change. b.mu must be
+// held.
+func (b *breaker) transition(ctx context.Context, state BreakerState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.failures = 0
+	if state == Open {
+		b.openedAt = time.Now()
+	}
+	attrs := []logging.Attr{
+		logging.String(string(NameKey), b.policy.Name),
+		logging.String("circuit_breaker.previous_state", string(from)),
+		logging.String(string(BreakerStateKey), string(state)),
+	}
+	if state == Open {
+		b.log.Warn(ctx, "Circuit breaker opened", append(attrs, logging.Duration("circuit_breaker.cooldown", b.policy.BreakerCooldown))...)
+		return
+	}
+	b.log.Info(ctx, "Circuit breaker state changed", attrs...)
+}
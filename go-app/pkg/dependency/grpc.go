@@ -0,0 +1,46 @@
+package dependency
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor applies p to every unary call on a gRPC client
+// connection, as Transport does for HTTP: the call is bounded by the
+// policy's timeout and failures are classified on the span in the call's
+// context. The otelgrpc client span only starts inside the call, so the
+// classification lands on the caller's span.
+func UnaryClientInterceptor(p Policy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		callCtx, cancel := p.WithTimeout(ctx)
+		defer cancel()
+		err := invoker(callCtx, method, req, reply, cc, opts...)
+		p.Annotate(ctx, p.ClassifyGRPC(err))
+		return err
+	}
+}
+
+// ClassifyGRPC returns the class of a failed gRPC call. Rules matching the
+// error text come first; otherwise Unavailable, DeadlineExceeded,
+// ResourceExhausted and Aborted are retryable and every other code is
+// fatal. It returns "" for successful calls.
+func (p Policy) ClassifyGRPC(err error) Class {
+	if err == nil {
+		return ""
+	}
+	for _, rule := range p.Rules {
+		if rule.Contains != "" && strings.Contains(err.Error(), rule.Contains) {
+			return rule.Class
+		}
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return Retryable
+	default:
+		return Fatal
+	}
+}
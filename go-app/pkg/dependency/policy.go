@@ -0,0 +1,260 @@
+// Package dependency centralizes how the service treats each of its
+// dependencies: how long a call may take, which failures are worth
+// retrying, how often, and when to stop calling. Policies come from
+// compiled-in defaults, optionally replaced by a JSON file, and are applied
+// by the client wrappers (the store decorator and the outgoing HTTP
+// transport) rather than by each caller.
+package dependency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Class is the classification of a failed dependency call.
+type Class string
+
+const (
+	// Retryable failures are transient: timeouts, refused or reset
+	// connections, throttling and 5xx responses.
+	Retryable Class = "retryable"
+	// Fatal failures will fail again if retried.
+	Fatal Class = "fatal"
+)
+
+// Span attributes set on the client span of a failed call.
+const (
+	NameKey           = attribute.Key("dependency.name")
+	ClassificationKey = attribute.Key("error.classification")
+)
+
+// Rule classifies failures whose error message contains Contains, or HTTP
+// responses with status code Status.
+type Rule struct {
+	Contains string `json:"contains,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Class    Class  `json:"class"`
+}
+
+// Policy is the timeout, classification rules, retry and circuit breaker
+// settings of one dependency. Rules are tried in order before the built-in
+// classification; zero values disable retries and the breaker.
+type Policy struct {
+	Name    string        `json:"dependency"`
+	Timeout time.Duration `json:"-"`
+	Rules   []Rule        `json:"rules,omitempty"`
+
+	// Retries resends a request that failed with a retryable error (see
+	// Retry), waiting Backoff, then twice as long before each next retry.
+	// RetryBudget caps retries at that fraction of requests.
+	Retries     int           `json:"retries,omitempty"`
+	Backoff     time.Duration `json:"-"`
+	RetryBudget float64       `json:"retry_budget,omitempty"`
+
+	// BreakerFailures retryable failures in a row open the circuit breaker
+	// (see CircuitBreaker) for BreakerCooldown.
+	BreakerFailures int           `json:"breaker_failures,omitempty"`
+	BreakerCooldown time.Duration `json:"-"`
+}
+
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	type policy Policy
+	var raw struct {
+		policy
+		Timeout string `json:"timeout"`
+		Backoff string `json:"backoff"`
+
+		BreakerCooldown string `json:"breaker_cooldown"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Policy(raw.policy)
+	if raw.Timeout != "" {
+		d, err := time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout for dependency %q: %w", p.Name, err)
+		}
+		p.Timeout = d
+	}
+	if raw.Backoff != "" {
+		d, err := time.ParseDuration(raw.Backoff)
+		if err != nil {
+			return fmt.Errorf("invalid backoff for dependency %q: %w", p.Name, err)
+		}
+		p.Backoff = d
+	}
+	if raw.BreakerCooldown != "" {
+		d, err := time.ParseDuration(raw.BreakerCooldown)
+		if err != nil {
+			return fmt.Errorf("invalid breaker_cooldown for dependency %q: %w", p.Name, err)
+		}
+		p.BreakerCooldown = d
+	}
+	return nil
+}
+
+// DefaultPolicies bound store calls and queue publishes to 2s and
+// downstream HTTP calls to 5s, opening the downstream circuit breaker for
+// 10s after 5 failures in a row; the "*" entry applies to every other
+// dependency. Nothing is retried unless a policy file asks for it: a retry
+// multiplies the load on a dependency that may already be failing under it.
+var DefaultPolicies = []Policy{
+	{Name: "store", Timeout: 2 * time.Second},
+	{Name: "queue", Timeout: 2 * time.Second},
+	{Name: "downstream", Timeout: 5 * time.Second, BreakerFailures: 5, BreakerCooldown: 10 * time.Second},
+	{Name: "*", Timeout: 10 * time.Second},
+}
+
+// renamedDependencies maps the former names of dependencies to their
+// current ones. A policy under a former name still applies, unless the
+// current name has a policy of its own.
+var renamedDependencies = map[string]string{"kafka": "queue"}
+
+// Registry holds the policies of every dependency.
+type Registry struct {
+	policies map[string]Policy
+	renamed  map[string]string // former names used, to current names
+}
+
+// NewRegistry indexes policies by dependency name.
+func NewRegistry(policies []Policy) (*Registry, error) {
+	r := &Registry{policies: make(map[string]Policy, len(policies))}
+	for _, p := range policies {
+		if p.Name == "" {
+			return nil, errors.New("dependency policy is missing a dependency name")
+		}
+		for _, rule := range p.Rules {
+			if rule.Class != Retryable && rule.Class != Fatal {
+				return nil, fmt.Errorf("dependency %q: unknown error class %q, want retryable or fatal", p.Name, rule.Class)
+			}
+		}
+		if p.Retries < 0 || p.Backoff < 0 || p.RetryBudget < 0 || p.BreakerFailures < 0 || p.BreakerCooldown < 0 {
+			return nil, fmt.Errorf("dependency %q: retry and breaker settings must not be negative", p.Name)
+		}
+		r.policies[p.Name] = p
+	}
+	for former, name := range renamedDependencies {
+		p, ok := r.policies[former]
+		if !ok {
+			continue
+		}
+		if r.renamed == nil {
+			r.renamed = make(map[string]string)
+		}
+		r.renamed[former] = name
+		if _, ok := r.policies[name]; !ok {
+			p.Name = name
+			r.policies[name] = p
+		}
+	}
+	return r, nil
+}
+
+// Renamed returns the former dependency names the policies use, mapped to
+// the current names, so callers can warn about them.
+func (r *Registry) Renamed() map[string]string {
+	return r.renamed
+}
+
+// Load reads policies from the JSON file at path, or uses DefaultPolicies
+// when path is empty.
+func Load(path string) (*Registry, error) {
+	if path == "" {
+		return NewRegistry(DefaultPolicies)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dependency policy file: %w", err)
+	}
+	var policies []Policy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse dependency policy file %s: %w", path, err)
+	}
+	return NewRegistry(policies)
+}
+
+// Policy returns the policy of the named dependency, falling back to the
+// "*" entry. The zero timeout means calls are not bounded.
+func (r *Registry) Policy(name string) Policy {
+	p, ok := r.policies[name]
+	if !ok {
+		p = r.policies["*"]
+	}
+	p.Name = name
+	return p
+}
+
+// WithTimeout bounds ctx by the policy's timeout, if any.
+func (p Policy) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
+
+// Classify returns the class of a failed call, given its error and, for
+// HTTP calls, the response status code (0 if there was no response). It
+// returns "" for successful calls.
+func (p Policy) Classify(err error, status int) Class {
+	if err == nil && status < 400 {
+		return ""
+	}
+	for _, rule := range p.Rules {
+		if rule.Status != 0 && rule.Status == status {
+			return rule.Class
+		}
+		if rule.Contains != "" && err != nil && strings.Contains(err.Error(), rule.Contains) {
+			return rule.Class
+		}
+	}
+	if err != nil {
+		return classifyError(err)
+	}
+	switch {
+	case status >= 500, status == http.StatusTooManyRequests, status == http.StatusRequestTimeout:
+		return Retryable
+	default:
+		return Fatal
+	}
+}
+
+func classifyError(err error) Class {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+		return Retryable
+	case errors.Is(err, context.Canceled):
+		// The caller gave up; retrying on its behalf is pointless.
+		return Fatal
+	case errors.As(err, &netErr):
+		return Retryable
+	default:
+		return Fatal
+	}
+}
+
+// Annotate records class on the span in ctx. It does nothing for "".
+func (p Policy) Annotate(ctx context.Context, class Class) {
+	if class == "" {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		NameKey.String(p.Name),
+		ClassificationKey.String(string(class)),
+	)
+}
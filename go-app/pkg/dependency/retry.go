@@ -0,0 +1,187 @@
+package dependency
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/telemetry"
+)
+
+// retryBudgetReserve is how many retries the budget holds when full, so a
+// burst of failures right after startup can still be retried.
+const retryBudgetReserve = 10
+
+// Retry returns a transport that resends requests through base when they
+// fail with a retryable error (see Policy.Classify), up to p.Retries times.
+// Wrap otelhttp.NewTransport with it, not the other way round, so every
+// attempt gets its own client span; attempts after the first carry
+// http.request.resend_count. Each retry is also recorded as a "retry" event
+// on the caller's span, and counted in app.dependency.retries. Failures
+// returned because no retry was left are counted in
+// app.dependency.retries.exhausted with reason "attempts" or "budget".
+//
+// Only idempotent requests are retried: GET, HEAD, OPTIONS, TRACE, PUT and
+// DELETE, or any request with an Idempotency-Key header, and only if their
+// body can be read again (Request.GetBody).
+func Retry(scope telemetry.InstrumentationScope, p Policy, base http.RoundTripper) (http.RoundTripper, error) {
+	meter := scope.Meter()
+	retries, err := metrics.DependencyRetries.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	exhausted, err := metrics.DependencyRetriesExhausted.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	t := &retryTransport{policy: p, base: base, retries: retries, exhausted: exhausted}
+	if p.RetryBudget > 0 {
+		t.budget = &retryBudget{ratio: p.RetryBudget, tokens: retryBudgetReserve}
+	}
+	return t, nil
+}
+
+type retryTransport struct {
+	policy    Policy
+	base      http.RoundTripper
+	budget    *retryBudget // nil without a budget
+	retries   metric.Int64Counter
+	exhausted metric.Int64Counter
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.policy.Retries == 0 || !retryable(req) {
+		return t.base.RoundTrip(req)
+	}
+	if t.budget != nil {
+		t.budget.deposit()
+	}
+	attrs := metric.WithAttributes(NameKey.String(t.policy.Name), semconv.HTTPRequestMethodKey.String(req.Method))
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(contextWithAttempt(ctx, attempt))
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		res, err := t.base.RoundTrip(attemptReq)
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		class := t.policy.Classify(err, status)
+		if class != Retryable {
+			return res, err
+		}
+
+		reason := ""
+		switch {
+		case attempt >= t.policy.Retries:
+			reason = "attempts"
+		case t.budget != nil && !t.budget.withdraw():
+			reason = "budget"
+		}
+		if reason != "" {
+			t.exhausted.Add(ctx, 1, attrs, metric.WithAttributes(attribute.String("reason", reason)))
+			return res, err
+		}
+
+		delay := t.backoff(attempt)
+		eventAttrs := []attribute.KeyValue{
+			semconv.HTTPRequestResendCount(attempt + 1),
+			attribute.Int64("retry.delay_ms", delay.Milliseconds()),
+		}
+		if err != nil {
+			eventAttrs = append(eventAttrs, attribute.String("exception.message", err.Error()))
+		} else {
+			eventAttrs = append(eventAttrs, semconv.HTTPResponseStatusCode(status))
+		}
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(eventAttrs...))
+		t.retries.Add(ctx, 1, attrs)
+		if res != nil {
+			// Drained so the connection can be reused by the retry.
+			io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the delay before retry attempt+1: Backoff doubled per earlier
+// retry, jittered between half and one and a half times that so clients
+// that failed together do not retry together.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.Backoff << attempt
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + d/2
+}
+
+// retryable reports whether req may be sent more than once.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryBudget is a token bucket: every request adds ratio tokens, every
+// retry takes one, so over time retries stay below ratio times the
+// requests.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	b.tokens = min(b.tokens+b.ratio, retryBudgetReserve)
+	b.mu.Unlock()
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type attemptKey struct{}
+
+// contextWithAttempt records that requests sent with ctx are the attempt-th
+// resend, for Transport to put on the client span.
+func contextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
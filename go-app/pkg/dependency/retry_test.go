@@ -0,0 +1,177 @@
+package dependency
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"my-go-app/pkg/telemetry"
+)
+
+// stubTransport answers every request with the next of statuses, repeating
+// the last one, and records what it was sent.
+type stubTransport struct {
+	statuses []int
+	calls    int
+	bodies   []string
+	attempts []int
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := s.statuses[min(s.calls, len(s.statuses)-1)]
+	s.calls++
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		s.bodies = append(s.bodies, string(b))
+	}
+	attempt, _ := req.Context().Value(attemptKey{}).(int)
+	s.attempts = append(s.attempts, attempt)
+	return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+}
+
+func newTestRetry(t *testing.T, p Policy, base http.RoundTripper) http.RoundTripper {
+	t.Helper()
+	rt, err := Retry(telemetry.Scope("dependency-test"), p, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt
+}
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		key       string
+		body      io.Reader
+		noGetBody bool
+		wantCalls int
+	}{
+		{name: "get", method: http.MethodGet, wantCalls: 3},
+		{name: "put", method: http.MethodPut, body: strings.NewReader("x"), wantCalls: 3},
+		{name: "delete", method: http.MethodDelete, wantCalls: 3},
+		{name: "post", method: http.MethodPost, body: strings.NewReader("x"), wantCalls: 1},
+		{name: "patch", method: http.MethodPatch, body: strings.NewReader("x"), wantCalls: 1},
+		{name: "post with idempotency key", method: http.MethodPost, key: "k1", body: strings.NewReader("x"), wantCalls: 3},
+		{name: "put without GetBody", method: http.MethodPut, body: strings.NewReader("x"), noGetBody: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubTransport{statuses: []int{http.StatusServiceUnavailable}}
+			rt := newTestRetry(t, Policy{Name: "test", Retries: 2}, stub)
+			req, err := http.NewRequest(tt.method, "http://dependency.test/", tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+			if tt.noGetBody {
+				req.GetBody = nil
+			}
+			res, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want the last failure, %d", res.StatusCode, http.StatusServiceUnavailable)
+			}
+			if stub.calls != tt.wantCalls {
+				t.Errorf("sent %d times, want %d", stub.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnFatalFailure(t *testing.T) {
+	stub := &stubTransport{statuses: []int{http.StatusNotFound}}
+	rt := newTestRetry(t, Policy{Name: "test", Retries: 2}, stub)
+	req, _ := http.NewRequest(http.MethodGet, "http://dependency.test/", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("sent %d times, want 1", stub.calls)
+	}
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	stub := &stubTransport{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}}
+	rt := newTestRetry(t, Policy{Name: "test", Retries: 2}, stub)
+	req, err := http.NewRequest(http.MethodPost, "http://dependency.test/", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "k1")
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if want := []string{"payload", "payload", "payload"}; strings.Join(stub.bodies, ",") != strings.Join(want, ",") {
+		t.Errorf("bodies sent = %q, want %q", stub.bodies, want)
+	}
+	for i, attempt := range stub.attempts {
+		if attempt != i {
+			t.Errorf("attempt %d was sent as resend %d", i, attempt)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	stub := &stubTransport{statuses: []int{http.StatusServiceUnavailable}}
+	rt := newTestRetry(t, Policy{Name: "test", Retries: 1, RetryBudget: 0.5}, stub)
+	retried := func() bool {
+		req, _ := http.NewRequest(http.MethodGet, "http://dependency.test/", nil)
+		before := stub.calls
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		return stub.calls-before > 1
+	}
+
+	// The reserve of retryBudgetReserve tokens, topped up by half a token
+	// per request, lasts 19 requests.
+	for i := range 19 {
+		if !retried() {
+			t.Fatalf("request %d was not retried within the reserve", i+1)
+		}
+	}
+	if retried() {
+		t.Fatal("request 20 was retried with the budget exhausted")
+	}
+	// From then on, every other request earns a retry.
+	n := 0
+	for range 40 {
+		if retried() {
+			n++
+		}
+	}
+	if n != 20 {
+		t.Errorf("%d of 40 requests retried with an exhausted budget of 0.5, want 20", n)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rt := &retryTransport{policy: Policy{Backoff: 100 * time.Millisecond}}
+	for attempt := range 5 {
+		base := 100 * time.Millisecond << attempt
+		for range 1000 {
+			if d := rt.backoff(attempt); d < base/2 || d >= base*3/2 {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s)", attempt, d, base/2, base*3/2)
+			}
+		}
+	}
+
+	rt.policy.Backoff = 0
+	if d := rt.backoff(3); d != 0 {
+		t.Errorf("backoff without Backoff = %s, want 0", d)
+	}
+}
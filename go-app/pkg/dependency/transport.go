@@ -0,0 +1,69 @@
+package dependency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TimeoutHeader carries the time a request has left, in milliseconds, so
+// the server it is sent to can give up when its caller does.
+const TimeoutHeader = "X-Request-Timeout"
+
+// Transport applies p to every request sent through base: the request is
+// bounded by the policy's timeout, or the caller's deadline if sooner, and
+// sent with the time left in TimeoutHeader. Failures are classified on the
+// span in the request's context, as is the resend count of a request
+// retried by Retry. Wrap it with otelhttp.NewTransport so both land on the
+// client span.
+func Transport(p Policy, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{policy: p, base: base}
+}
+
+type transport struct {
+	policy Policy
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if attempt, ok := req.Context().Value(attemptKey{}).(int); ok {
+		trace.SpanFromContext(req.Context()).SetAttributes(semconv.HTTPRequestResendCount(attempt))
+	}
+	ctx, cancel := t.policy.WithTimeout(req.Context())
+	req = req.Clone(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(TimeoutHeader, strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10))
+	}
+	res, err := t.base.RoundTrip(req)
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	t.policy.Annotate(ctx, t.policy.Classify(err, status))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body, so it is released on Close.
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,227 @@
+// Package health aggregates the health of the service's dependencies
+// (database, cache, queues, downstream HTTP services, the telemetry
+// collector) into a readiness verdict. Each integration registers a check;
+// every evaluation is timed, exported as metrics and logged when a
+// dependency changes state.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/telemetry"
+)
+
+// Check reports whether a dependency is usable; a nil error means healthy.
+type Check func(ctx context.Context) error
+
+// Status is the outcome of one dependency's check.
+type Status struct {
+	Name    string        `json:"name"`
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"-"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	type status Status
+	return json.Marshal(struct {
+		status
+		LatencyMS float64 `json:"latency_ms"`
+	}{status(s), float64(s.Latency.Microseconds()) / 1000})
+}
+
+type dependency struct {
+	name  string
+	check Check
+	last  *Status // nil until first checked
+}
+
+// Registry holds the registered dependency checks.
+type Registry struct {
+	timeout  time.Duration
+	log      *logging.Logger
+	duration metric.Float64Histogram
+
+	mu   sync.Mutex
+	deps []*dependency
+}
+
+// New returns an empty registry whose checks are each bounded by timeout.
+func New(scope telemetry.InstrumentationScope, timeout time.Duration) (*Registry, error) {
+	r := &Registry{timeout: timeout, log: logging.New(scope)}
+	meter := scope.Meter()
+	var err error
+	r.duration, err = metrics.DependencyCheckDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = metrics.DependencyHealthy.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for _, d := range r.deps {
+				if d.last == nil {
+					continue
+				}
+				var v int64
+				if d.last.Healthy {
+					v = 1
+				}
+				o.Observe(v, metric.WithAttributes(attribute.String("dependency", d.name)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Register adds a dependency check. Registering a name again replaces its
+// check.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deps {
+		if d.name == name {
+			d.check = check
+			return
+		}
+	}
+	r.deps = append(r.deps, &dependency{name: name, check: check})
+}
+
+// Check runs every registered check concurrently and reports whether all of
+// them passed, with the per-dependency results in registration order.
+func (r *Registry) Check(ctx context.Context) (bool, []Status) {
+	r.mu.Lock()
+	deps := make([]*dependency, len(r.deps))
+	copy(deps, r.deps)
+	checks := make([]Check, len(r.deps))
+	for i, d := range r.deps {
+		checks[i] = d.check
+	}
+	r.mu.Unlock()
+
+	statuses := make([]Status, len(deps))
+	var wg sync.WaitGroup
+	for i, d := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = r.run(ctx, d.name, checks[i])
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for i, d := range deps {
+		ready = ready && statuses[i].Healthy
+		r.record(ctx, d, statuses[i])
+	}
+	return ready, statuses
+}
+
+func (r *Registry) run(ctx context.Context, name string, check Check) Status {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	start := time.Now()
+	err := check(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("no answer within %s: %w", r.timeout, err)
+	}
+	s := Status{Name: name, Healthy: err == nil, Latency: time.Since(start)}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	outcome := "healthy"
+	if !s.Healthy {
+		outcome = "unhealthy"
+	}
+	r.duration.Record(ctx, s.Latency.Seconds(), metric.WithAttributes(
+		attribute.String("dependency", name),
+		attribute.String("outcome", outcome),
+	))
+	return s
+}
+
+// record stores s as d's latest status and logs state changes. A dependency
+// that is healthy on its first check is not logged.
+func (r *Registry) record(ctx context.Context, d *dependency, s Status) {
+	r.mu.Lock()
+	prev := d.last
+	d.last = &s
+	r.mu.Unlock()
+
+	switch {
+	case !s.Healthy && (prev == nil || prev.Healthy):
+		r.log.Warn(ctx, "Dependency unhealthy",
+			logging.String("dependency", s.Name),
+			logging.String("error", s.Error),
+			logging.Duration("check.duration_s", s.Latency),
+		)
+	case s.Healthy && prev != nil && !prev.Healthy:
+		r.log.Info(ctx, "Dependency recovered",
+			logging.String("dependency", s.Name),
+			logging.Duration("check.duration_s", s.Latency),
+		)
+	}
+}
+
+// ServeHTTP serves the readiness probe: 200 when every dependency is
+// healthy, 503 otherwise, with the per-dependency results as JSON.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ready, statuses := r.Check(req.Context())
+	WriteStatus(w, ready, statuses)
+}
+
+// WriteStatus writes a probe response for statuses: "pass" with 200 when ok,
+// "fail" with 503 otherwise.
+func WriteStatus(w http.ResponseWriter, ok bool, statuses []Status) {
+	body := struct {
+		Status       string   `json:"status"`
+		Dependencies []Status `json:"dependencies"`
+	}{"pass", statuses}
+	code := http.StatusOK
+	if !ok {
+		body.Status, code = "fail", http.StatusServiceUnavailable
+	}
+	if body.Dependencies == nil {
+		body.Dependencies = []Status{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// HTTP returns a check that passes when a GET of url answers with a non-5xx
+// status.
+func HTTP(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode >= 500 {
+			return fmt.Errorf("GET %s: %s", url, res.Status)
+		}
+		return nil
+	}
+}
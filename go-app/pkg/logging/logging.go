@@ -0,0 +1,133 @@
+// Package logging is a small structured logging API on top of the OTel Logs
+// API. It fills in severity text, takes typed attributes that can be
+// evaluated lazily, and turns errors into semantic-convention exception
+// attributes, so call sites don't build log records by hand.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"my-go-app/pkg/telemetry"
+)
+
+// Attr is a record attribute. Lazy attributes are only evaluated when the
+// record is actually emitted.
+type Attr struct {
+	kvs  []otellog.KeyValue
+	lazy func() otellog.KeyValue
+}
+
+func (a Attr) keyValues() []otellog.KeyValue {
+	if a.lazy != nil {
+		return []otellog.KeyValue{a.lazy()}
+	}
+	return a.kvs
+}
+
+func attr(kv otellog.KeyValue) Attr { return Attr{kvs: []otellog.KeyValue{kv}} }
+
+// String returns a string attribute.
+func String(key, value string) Attr { return attr(otellog.String(key, value)) }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return attr(otellog.Int(key, value)) }
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attr { return attr(otellog.Int64(key, value)) }
+
+// Float64 returns a floating point attribute.
+func Float64(key string, value float64) Attr { return attr(otellog.Float64(key, value)) }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return attr(otellog.Bool(key, value)) }
+
+// Duration returns the duration as a floating point number of seconds under
+// key.
+func Duration(key string, value time.Duration) Attr {
+	return attr(otellog.Float64(key, value.Seconds()))
+}
+
+// Lazy returns an attribute whose value is computed by fn only if the record
+// is emitted, for values that are expensive to build.
+func Lazy(key string, fn func() otellog.Value) Attr {
+	return Attr{lazy: func() otellog.KeyValue { return otellog.KeyValue{Key: key, Value: fn()} }}
+}
+
+// Err describes err with the exception.message and exception.type
+// attributes. The type is that of the innermost error in the chain, so a
+// wrapped *os.PathError is reported as such rather than as *fmt.wrapError.
+func Err(err error) Attr {
+	if err == nil {
+		return Attr{}
+	}
+	cause := err
+	for {
+		next := errors.Unwrap(cause)
+		if next == nil {
+			break
+		}
+		cause = next
+	}
+	return Attr{kvs: []otellog.KeyValue{
+		otellog.String(string(semconv.ExceptionMessageKey), err.Error()),
+		otellog.String(string(semconv.ExceptionTypeKey), fmt.Sprintf("%T", cause)),
+	}}
+}
+
+// Logger emits records under one instrumentation scope.
+type Logger struct {
+	logger otellog.Logger
+}
+
+// New returns a logger for scope. It may be created before the global
+// LoggerProvider is set; records are delegated once it is.
+func New(scope telemetry.InstrumentationScope) *Logger {
+	return &Logger{logger: scope.Logger()}
+}
+
+// Debug emits a debug record.
+func (l *Logger) Debug(ctx context.Context, msg string, attrs ...Attr) {
+	l.emit(ctx, otellog.SeverityDebug, msg, attrs)
+}
+
+// Info emits an info record.
+func (l *Logger) Info(ctx context.Context, msg string, attrs ...Attr) {
+	l.emit(ctx, otellog.SeverityInfo, msg, attrs)
+}
+
+// Warn emits a warning record.
+func (l *Logger) Warn(ctx context.Context, msg string, attrs ...Attr) {
+	l.emit(ctx, otellog.SeverityWarn, msg, attrs)
+}
+
+// Error emits an error record.
+func (l *Logger) Error(ctx context.Context, msg string, attrs ...Attr) {
+	l.emit(ctx, otellog.SeverityError, msg, attrs)
+}
+
+// Log emits a record at an arbitrary severity.
+func (l *Logger) Log(ctx context.Context, severity otellog.Severity, msg string, attrs ...Attr) {
+	l.emit(ctx, severity, msg, attrs)
+}
+
+func (l *Logger) emit(ctx context.Context, severity otellog.Severity, msg string, attrs []Attr) {
+	if !l.logger.Enabled(ctx, otellog.EnabledParameters{Severity: severity}) {
+		return
+	}
+
+	var record otellog.Record
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(severity)
+	record.SetSeverityText(severity.String())
+	record.SetBody(otellog.StringValue(msg))
+	for _, a := range attrs {
+		record.AddAttributes(a.keyValues()...)
+	}
+	l.logger.Emit(ctx, record)
+}
@@ -0,0 +1,675 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+// The catalog defines every instrument the service and its commands create:
+// name, kind, unit, description and bucket advice live here, and call sites
+// only pick a definition and add callbacks. Grouped by the component that
+// records them.
+
+// HTTP server (main package, httpmetrics.go, recovery.go, ratelimit.go).
+var (
+	HTTPServerRequests = Definition{
+		Name:        "http.server.requests",
+		Kind:        KindCounter,
+		Description: "Total number of incoming HTTP requests.",
+		Unit:        "{request}",
+	}
+	HTTPServerActiveRequests = Definition{
+		Name:        "http.server.active_requests",
+		Kind:        KindUpDownCounter,
+		Description: "Number of active HTTP requests.",
+		Unit:        "{request}",
+	}
+	HTTPServerLastRequestTimestamp = Definition{
+		Name:        "http.server.last_request.timestamp",
+		Kind:        KindGauge,
+		Description: "Unix time at which the last HTTP request was received.",
+		Unit:        "s",
+	}
+	HTTPServerPanics = Definition{
+		Name:        "http.server.panics",
+		Kind:        KindCounter,
+		Description: "Number of handler panics recovered by the server.",
+		Unit:        "{panic}",
+	}
+	HTTPServerRequestDuration = Definition{
+		Name:        "http.server.request.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of HTTP server requests.",
+		Unit:        "s",
+	}
+	HTTPServerRequestBodySize = Definition{
+		Name:        "http.server.request.body.size",
+		Kind:        KindHistogram,
+		Description: "Size of HTTP server request bodies.",
+		Unit:        "By",
+	}
+	HTTPServerResponseBodySize = Definition{
+		Name:        "http.server.response.body.size",
+		Kind:        KindHistogram,
+		Description: "Size of HTTP server response bodies.",
+		Unit:        "By",
+	}
+	HTTPServerThrottledRequests = Definition{
+		Name:        "http.server.throttled_requests",
+		Kind:        KindCounter,
+		Description: "Number of HTTP server requests rejected with 429 for exceeding a rate limit.",
+		Unit:        "{request}",
+	}
+	HTTPServerErrors = Definition{
+		Name:        "http.server.errors",
+		Kind:        KindCounter,
+		Description: "Number of HTTP server requests that ended with a 5xx status code.",
+		Unit:        "{request}",
+	}
+	HTTPServerUnsampledDuration = Definition{
+		Name:        "http.server.unsampled.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of HTTP server requests whose traces were not sampled.",
+		Unit:        "s",
+	}
+	HTTPServerRequestCPUTime = Definition{
+		Name:        "http.server.request.cpu_time",
+		Kind:        KindHistogram,
+		Description: "CPU time spent by the goroutine serving the request.",
+		Unit:        "s",
+	}
+	SessionRequests = Definition{
+		Name:        "app.session.requests",
+		Kind:        KindCounter,
+		Description: "Requests served within a session, by route.",
+		Unit:        "{request}",
+	}
+	EndpointEnabled = Definition{
+		Name:        "app.endpoint.enabled",
+		Kind:        KindGauge,
+		Description: "Whether an endpoint is enabled (1) or switched off by its kill switch (0).",
+		Unit:        "1",
+	}
+	Maintenance = Definition{
+		Name:        "app.maintenance",
+		Kind:        KindGauge,
+		Description: "Whether the service is in maintenance mode (1) or serving normally (0).",
+		Unit:        "1",
+	}
+)
+
+// HTTP client (httpclientmetrics.go, gauges.go). otelhttp records
+// http.client.request.duration and http.client.request.body.size itself.
+var (
+	HTTPClientResponseBodySize = Definition{
+		Name:        semconv.HTTPClientResponseBodySizeName,
+		Kind:        KindHistogram,
+		Description: semconv.HTTPClientResponseBodySizeDescription,
+		Unit:        semconv.HTTPClientResponseBodySizeUnit,
+	}
+	HTTPClientRetries = Definition{
+		Name:        "app.http.client.retries",
+		Kind:        KindCounter,
+		Description: "Requests the transport sent again on a new connection after a reused one failed.",
+		Unit:        "{request}",
+	}
+	HTTPClientOpenConnections = Definition{
+		Name:        "http.client.open_connections",
+		Kind:        KindGauge,
+		Description: "Open connections of the downstream HTTP client, by http.connection.state (active or idle).",
+		Unit:        "{connection}",
+	}
+	HTTPClientDialedConnections = Definition{
+		Name:        "http.client.dialed_connections",
+		Kind:        KindCounter,
+		Description: "Connections dialed by the downstream HTTP client.",
+		Unit:        "{connection}",
+	}
+)
+
+// Application work.
+var (
+	WorkDuration = Definition{
+		Name:        "app.work.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of the work operation.",
+		Unit:        "s",
+	}
+	ExperimentDuration = Definition{
+		Name:        "app.experiment.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of /experiment requests, by variant.",
+		Unit:        "s",
+	}
+	OperationCalls = Definition{
+		Name:        "app.operation.calls",
+		Kind:        KindCounter,
+		Description: "Number of calls to hot internal operations recorded as metrics instead of spans.",
+		Unit:        "{call}",
+	}
+	OperationDuration = Definition{
+		Name:        "app.operation.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of hot internal operations recorded as metrics instead of spans.",
+		Unit:        "s",
+	}
+	WebhookEvents = Definition{
+		Name:        "app.webhook.events",
+		Kind:        KindCounter,
+		Description: "Webhook events received, including duplicates, by webhook.event.type and duplicate.",
+		Unit:        "{event}",
+	}
+	WebhookDuplicates = Definition{
+		Name:        "app.webhook.duplicates",
+		Kind:        KindCounter,
+		Description: "Webhook events acknowledged without processing because their ID was seen within the dedup window.",
+		Unit:        "{event}",
+	}
+	WebhookDuplicateAge = Definition{
+		Name:        "app.webhook.duplicate.age",
+		Kind:        KindHistogram,
+		Description: "Time between the first delivery of a webhook event and a duplicate of it.",
+		Unit:        "s",
+	}
+)
+
+// dbConnectionTimeBuckets cover connection pool waits and connection
+// setup, from a millisecond to the driver's default 30s timeouts, in
+// seconds.
+var dbConnectionTimeBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Caches and stores.
+var (
+	DownstreamCacheRequests = Definition{
+		Name:        "app.downstream.cache.requests",
+		Kind:        KindCounter,
+		Description: "Downstream cache lookups, by cache.result (hit, stale or miss).",
+		Unit:        "{request}",
+	}
+	DownstreamCacheStaleness = Definition{
+		Name:        "app.downstream.cache.staleness",
+		Kind:        KindHistogram,
+		Description: "How far past its TTL a stale response was when it was served.",
+		Unit:        "s",
+	}
+	DownstreamCacheRefreshFailures = Definition{
+		Name:        "app.downstream.cache.refresh.failures",
+		Kind:        KindCounter,
+		Description: "Failed background refreshes of a stale downstream response.",
+		Unit:        "{refresh}",
+	}
+	StoreOperationDuration = Definition{
+		Name:        "app.store.operation.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of key-value store operations, by driver and operation.",
+		Unit:        "s",
+	}
+	DBClientConnectionCount = Definition{
+		Name:        semconv.DBClientConnectionCountName,
+		Kind:        KindUpDownCounter,
+		Description: semconv.DBClientConnectionCountDescription,
+		Unit:        semconv.DBClientConnectionCountUnit,
+	}
+	DBClientConnectionCreateTime = Definition{
+		Name:        semconv.DBClientConnectionCreateTimeName,
+		Kind:        KindHistogram,
+		Description: semconv.DBClientConnectionCreateTimeDescription,
+		Unit:        semconv.DBClientConnectionCreateTimeUnit,
+		Buckets:     dbConnectionTimeBuckets,
+	}
+	DBClientConnectionWaitTime = Definition{
+		Name:        semconv.DBClientConnectionWaitTimeName,
+		Kind:        KindHistogram,
+		Description: semconv.DBClientConnectionWaitTimeDescription,
+		Unit:        semconv.DBClientConnectionWaitTimeUnit,
+		Buckets:     dbConnectionTimeBuckets,
+	}
+	DBClientConnectionTimeouts = Definition{
+		Name:        semconv.DBClientConnectionTimeoutsName,
+		Kind:        KindCounter,
+		Description: semconv.DBClientConnectionTimeoutsDescription,
+		Unit:        semconv.DBClientConnectionTimeoutsUnit,
+	}
+	DependencyCheckDuration = Definition{
+		Name:        "app.dependency.check.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of dependency health checks, by dependency and outcome.",
+		Unit:        "s",
+	}
+	DependencyHealthy = Definition{
+		Name:        "app.dependency.healthy",
+		Kind:        KindGauge,
+		Description: "Whether the last health check of a dependency passed (1) or failed (0).",
+		Unit:        "1",
+	}
+	DependencyRetries = Definition{
+		Name:        "app.dependency.retries",
+		Kind:        KindCounter,
+		Description: "Requests resent to a dependency after a retryable failure.",
+		Unit:        "{retry}",
+	}
+	DependencyRetriesExhausted = Definition{
+		Name:        "app.dependency.retries.exhausted",
+		Kind:        KindCounter,
+		Description: "Retryable failures returned to the caller because the attempts or the retry budget ran out, by reason.",
+		Unit:        "{request}",
+	}
+	DependencyCircuitBreakerState = Definition{
+		Name:        "app.dependency.circuit_breaker.state",
+		Kind:        KindGauge,
+		Description: "1 for the current state of a dependency's circuit breaker (closed, open, half_open), 0 for the others.",
+		Unit:        "1",
+	}
+)
+
+// messagingDurationBuckets are the bucket boundaries the semantic
+// conventions advise for the messaging duration histograms, in seconds.
+var messagingDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Messaging (pkg/queue), following the semantic conventions where they
+// define the instrument.
+var (
+	MessagingClientOperationDuration = Definition{
+		Name:        semconv.MessagingClientOperationDurationName,
+		Kind:        KindHistogram,
+		Description: semconv.MessagingClientOperationDurationDescription,
+		Unit:        semconv.MessagingClientOperationDurationUnit,
+		Buckets:     messagingDurationBuckets,
+	}
+	MessagingClientSentMessages = Definition{
+		Name:        semconv.MessagingClientSentMessagesName,
+		Kind:        KindCounter,
+		Description: semconv.MessagingClientSentMessagesDescription,
+		Unit:        semconv.MessagingClientSentMessagesUnit,
+	}
+	MessagingProcessDuration = Definition{
+		Name:        semconv.MessagingProcessDurationName,
+		Kind:        KindHistogram,
+		Description: semconv.MessagingProcessDurationDescription,
+		Unit:        semconv.MessagingProcessDurationUnit,
+		Buckets:     messagingDurationBuckets,
+	}
+	MessagingClientConsumedMessages = Definition{
+		Name:        semconv.MessagingClientConsumedMessagesName,
+		Kind:        KindCounter,
+		Description: semconv.MessagingClientConsumedMessagesDescription,
+		Unit:        semconv.MessagingClientConsumedMessagesUnit,
+	}
+	MessagingQueueTime = Definition{
+		Name:        "app.messaging.queue.time",
+		Kind:        KindHistogram,
+		Description: "Time between a message being published, by its timestamp, and its processing starting.",
+		Unit:        "s",
+		Buckets:     []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}
+	MessagingConsumerLag = Definition{
+		Name:        "app.messaging.consumer.lag",
+		Kind:        KindHistogram,
+		Description: "Messages the group had yet to fetch after each message, when it was fetched.",
+		Unit:        "{message}",
+		Buckets:     []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000, 10000, 50000},
+	}
+	MessagingConsumerPending = Definition{
+		Name:        "app.messaging.consumer.pending",
+		Kind:        KindGauge,
+		Description: "Messages the group had yet to fetch after the last one the consumer fetched.",
+		Unit:        "{message}",
+	}
+)
+
+// workerDurationBuckets cover jobs from milliseconds to half a minute, in
+// seconds.
+var workerDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10, 30}
+
+// Background work (pkg/worker, pkg/scheduler).
+var (
+	WorkerJobDuration = Definition{
+		Name:        "app.worker.job.duration",
+		Kind:        KindHistogram,
+		Description: "Time jobs took to run, by worker.pool, job.name and error.type.",
+		Unit:        "s",
+		Buckets:     workerDurationBuckets,
+	}
+	WorkerJobWait = Definition{
+		Name:        "app.worker.job.wait",
+		Kind:        KindHistogram,
+		Description: "Time jobs waited in the queue for a worker.",
+		Unit:        "s",
+		Buckets:     workerDurationBuckets,
+	}
+	WorkerJobsRejected = Definition{
+		Name:        "app.worker.jobs.rejected",
+		Kind:        KindCounter,
+		Description: "Jobs Submit turned away, by reason (queue_full or shut_down).",
+		Unit:        "{job}",
+	}
+	WorkerQueueDepth = Definition{
+		Name:        "app.worker.queue.depth",
+		Kind:        KindGauge,
+		Description: "Jobs waiting for a worker.",
+		Unit:        "{job}",
+	}
+	WorkerJobsActive = Definition{
+		Name:        "app.worker.jobs.active",
+		Kind:        KindGauge,
+		Description: "Jobs running.",
+		Unit:        "{job}",
+	}
+	CronRuns = Definition{
+		Name:        "app.cron.runs",
+		Kind:        KindCounter,
+		Description: "Scheduled task runs, by cron.task and cron.outcome.",
+		Unit:        "{run}",
+	}
+	CronRunDuration = Definition{
+		Name:        "app.cron.run.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of scheduled task runs, by cron.task and cron.outcome.",
+		Unit:        "s",
+	}
+	CronRunsMissed = Definition{
+		Name:        "app.cron.runs.missed",
+		Kind:        KindCounter,
+		Description: "Scheduled ticks skipped because the task's previous run was still going.",
+		Unit:        "{run}",
+	}
+	CronLastSuccess = Definition{
+		Name:        "app.cron.last_success",
+		Kind:        KindGauge,
+		Description: "Unix time of the last successful run of each scheduled task.",
+		Unit:        "s",
+	}
+)
+
+// Telemetry pipeline self-monitoring.
+var (
+	TelemetrySpansQueued = Definition{
+		Name:        "app.telemetry.spans.queued",
+		Kind:        KindCounter,
+		Description: "Sampled spans handed to the batch span processor.",
+		Unit:        "{span}",
+	}
+	TelemetryExportItems = Definition{
+		Name:        "app.telemetry.export.items",
+		Kind:        KindCounter,
+		Description: "Items (spans, log records, metrics) passed to an exporter, by outcome.",
+		Unit:        "{item}",
+	}
+	TelemetryExportFailures = Definition{
+		Name:        "app.telemetry.export.failures",
+		Kind:        KindCounter,
+		Description: "Failed export calls.",
+		Unit:        "{call}",
+	}
+	TelemetryExportDuration = Definition{
+		Name:        "app.telemetry.export.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of export calls.",
+		Unit:        "s",
+	}
+	TelemetryExportSplits = Definition{
+		Name:        "app.telemetry.export.splits",
+		Kind:        KindCounter,
+		Description: "Export requests split in two for exceeding the maximum message size.",
+		Unit:        "{request}",
+	}
+	TelemetryExportOversized = Definition{
+		Name:        "app.telemetry.export.oversized",
+		Kind:        KindCounter,
+		Description: "Items dropped because they exceed the maximum message size on their own.",
+		Unit:        "{item}",
+	}
+	TelemetrySpansDropped = Definition{
+		Name:        "app.telemetry.spans.dropped",
+		Kind:        KindCounter,
+		Description: "Spans dropped because the batch span processor queue was full.",
+		Unit:        "{span}",
+	}
+	TelemetryLogsDropped = Definition{
+		Name:        "app.telemetry.logs.dropped",
+		Kind:        KindCounter,
+		Description: "Log records dropped because the batch log processor queue was full.",
+		Unit:        "{record}",
+	}
+	TelemetryBufferDropped = Definition{
+		Name:        "app.telemetry.buffer.dropped",
+		Kind:        KindCounter,
+		Description: "Spans and log records dropped, oldest first, because the export buffer reached its memory limit.",
+		Unit:        "{item}",
+	}
+	TelemetryBufferMemory = Definition{
+		Name:        "app.telemetry.buffer.memory",
+		Kind:        KindGauge,
+		Description: "Estimated memory held by spans and log records waiting to be exported.",
+		Unit:        "By",
+	}
+	TelemetryBufferMemoryLimit = Definition{
+		Name:        "app.telemetry.buffer.memory.limit",
+		Kind:        KindGauge,
+		Description: "Memory the export buffer may hold before dropping the oldest items.",
+		Unit:        "By",
+	}
+	TelemetrySpanAttributesDropped = Definition{
+		Name:        "app.telemetry.span_attributes.dropped",
+		Kind:        KindCounter,
+		Description: "Span attributes removed by the attribute allowlist/denylist, by key.",
+		Unit:        "{attribute}",
+	}
+	TelemetryCanary = Definition{
+		Name:        "app.telemetry.canary",
+		Kind:        KindCounter,
+		Description: "Startup self-test points; one per process start, by canary.id.",
+		Unit:        "{canary}",
+	}
+	ClientTelemetryItems = Definition{
+		Name:        "app.client.telemetry.items",
+		Kind:        KindCounter,
+		Description: "Spans and log records generated on behalf of each client.",
+		Unit:        "{item}",
+	}
+	ClientTelemetryThrottled = Definition{
+		Name:        "app.client.telemetry.throttled",
+		Kind:        KindCounter,
+		Description: "Traces and log records suppressed because the client exhausted its telemetry budget.",
+		Unit:        "{item}",
+	}
+	SDKErrors = Definition{
+		Name:        "otel.sdk.errors",
+		Kind:        KindCounter,
+		Description: "Errors reported by the OpenTelemetry SDK through its error handler, by class.",
+		Unit:        "{error}",
+	}
+	SpanMetricsCalls = Definition{
+		Name:        "traces.span.metrics.calls",
+		Kind:        KindCounter,
+		Description: "Number of spans, by span name, kind and status.",
+		Unit:        "{call}",
+	}
+	SpanMetricsErrors = Definition{
+		Name:        "traces.span.metrics.errors",
+		Kind:        KindCounter,
+		Description: "Number of spans ending with an error status.",
+		Unit:        "{call}",
+	}
+	SpanMetricsDuration = Definition{
+		Name:        "traces.span.metrics.duration",
+		Kind:        KindHistogram,
+		Description: "Duration of spans, by span name, kind and status.",
+		Unit:        "s",
+	}
+)
+
+// Process and runtime.
+var (
+	ProcessUptime = Definition{
+		Name:        "process.uptime",
+		Kind:        KindGauge,
+		Description: "Time since the process started.",
+		Unit:        "s",
+	}
+	Goroutines = Definition{
+		Name:        "app.goroutines",
+		Kind:        KindGauge,
+		Description: "Number of live goroutines.",
+		Unit:        "{goroutine}",
+	}
+	CPUQuota = Definition{
+		Name:        "app.cpu.quota",
+		Kind:        KindGauge,
+		Description: "CPU limit of the container, detected from its cgroup.",
+		Unit:        "{cpu}",
+	}
+	GoMaxProcs = Definition{
+		Name:        "app.gomaxprocs",
+		Kind:        KindGauge,
+		Description: "Effective GOMAXPROCS, by where it was derived from.",
+		Unit:        "{thread}",
+	}
+	MemoryLimit = Definition{
+		Name:        "app.memory.limit",
+		Kind:        KindGauge,
+		Description: "Memory limit of the container, detected from its cgroup.",
+		Unit:        "By",
+	}
+	MemoryUsage = Definition{
+		Name:        "app.memory.usage",
+		Kind:        KindGauge,
+		Description: "Memory charged to the container's cgroup, including page cache.",
+		Unit:        "By",
+	}
+	GoMemLimit = Definition{
+		Name:        "app.gomemlimit",
+		Kind:        KindGauge,
+		Description: "Effective GOMEMLIMIT (0 when unlimited), by where it was derived from.",
+		Unit:        "By",
+	}
+	MemoryUtilization = Definition{
+		Name:        "app.memory.utilization",
+		Kind:        KindGauge,
+		Description: "Memory mapped by the Go runtime as a fraction of the container memory limit, or of GOMEMLIMIT when the container is not limited.",
+		Unit:        "1",
+	}
+	GCAssistTime = Definition{
+		Name:        "go.gc.assist.time",
+		Kind:        KindCounter,
+		Description: "Approximate CPU time goroutines have spent assisting the GC instead of running application code.",
+		Unit:        "s",
+	}
+	MutexWait = Definition{
+		Name:        "go.sync.mutex.wait",
+		Kind:        KindCounter,
+		Description: "Approximate cumulative time goroutines have spent blocked on a sync.Mutex, sync.RWMutex or runtime-internal lock.",
+		Unit:        "s",
+	}
+)
+
+// The metricgen subcommand.
+var (
+	MetricgenEvents = Definition{
+		Name:        "metricgen.events",
+		Kind:        KindCounter,
+		Description: "Synthetic events emitted by the metricgen subcommand.",
+		Unit:        "{event}",
+	}
+	MetricgenLatency = Definition{
+		Name:        "metricgen.latency",
+		Kind:        KindHistogram,
+		Description: "Synthetic latency values emitted by the metricgen subcommand.",
+		Unit:        "s",
+	}
+)
+
+// Catalog lists every definition above.
+var Catalog = []Definition{
+	HTTPServerRequests, HTTPServerActiveRequests, HTTPServerLastRequestTimestamp, HTTPServerPanics,
+	HTTPServerRequestDuration, HTTPServerRequestBodySize, HTTPServerResponseBodySize, HTTPServerErrors,
+	HTTPServerThrottledRequests, HTTPServerUnsampledDuration, HTTPServerRequestCPUTime, SessionRequests, EndpointEnabled, Maintenance,
+	HTTPClientResponseBodySize, HTTPClientRetries, HTTPClientOpenConnections, HTTPClientDialedConnections,
+	WorkDuration, ExperimentDuration, OperationCalls, OperationDuration,
+	WebhookEvents, WebhookDuplicates, WebhookDuplicateAge,
+	DownstreamCacheRequests, DownstreamCacheStaleness, DownstreamCacheRefreshFailures,
+	StoreOperationDuration,
+	DBClientConnectionCount, DBClientConnectionCreateTime, DBClientConnectionWaitTime, DBClientConnectionTimeouts,
+	DependencyCheckDuration, DependencyHealthy, DependencyRetries, DependencyRetriesExhausted,
+	DependencyCircuitBreakerState,
+	MessagingClientOperationDuration, MessagingClientSentMessages, MessagingProcessDuration,
+	MessagingClientConsumedMessages, MessagingQueueTime, MessagingConsumerLag, MessagingConsumerPending,
+	WorkerJobDuration, WorkerJobWait, WorkerJobsRejected, WorkerQueueDepth, WorkerJobsActive,
+	CronRuns, CronRunDuration, CronRunsMissed, CronLastSuccess,
+	TelemetrySpansQueued, TelemetryExportItems, TelemetryExportFailures, TelemetryExportDuration,
+	TelemetryExportSplits, TelemetryExportOversized, TelemetrySpansDropped, TelemetryLogsDropped,
+	TelemetryBufferDropped, TelemetryBufferMemory, TelemetryBufferMemoryLimit,
+	TelemetrySpanAttributesDropped, TelemetryCanary, ClientTelemetryItems, ClientTelemetryThrottled,
+	SDKErrors, SpanMetricsCalls, SpanMetricsErrors, SpanMetricsDuration,
+	ProcessUptime, Goroutines,
+	CPUQuota, GoMaxProcs, MemoryLimit, MemoryUsage, GoMemLimit, MemoryUtilization, GCAssistTime, MutexWait,
+	MetricgenEvents, MetricgenLatency,
+}
+
+// CheckCatalog reports every way defs drift from the naming rules at once:
+// duplicate names, names that would collide once exported to Prometheus,
+// names carrying a Prometheus suffix (the exporter adds _total and the
+// unit itself), missing descriptions or units, and unsorted buckets.
+func CheckCatalog(defs []Definition) error {
+	var errs []error
+	names := make(map[string]bool, len(defs))
+	exported := make(map[string]string, len(defs))
+	for _, d := range defs {
+		if names[d.Name] {
+			errs = append(errs, fmt.Errorf("metric %q is defined twice", d.Name))
+			continue
+		}
+		names[d.Name] = true
+		if prev, ok := exported[PrometheusName(d)]; ok {
+			errs = append(errs, fmt.Errorf("metrics %q and %q are both exported as %s", prev, d.Name, PrometheusName(d)))
+		}
+		exported[PrometheusName(d)] = d.Name
+		for _, suffix := range []string{"_total", "_seconds", "_bytes", "_count", "_sum", "_bucket"} {
+			if strings.HasSuffix(d.Name, suffix) {
+				errs = append(errs, fmt.Errorf("metric %q ends with the Prometheus suffix %s", d.Name, suffix))
+			}
+		}
+		if !d.Kind.valid() {
+			errs = append(errs, fmt.Errorf("metric %q has unknown kind %q", d.Name, d.Kind))
+		}
+		if d.Description == "" || d.Unit == "" {
+			errs = append(errs, fmt.Errorf("metric %q needs a description and a unit", d.Name))
+		}
+		if len(d.Buckets) > 0 && (d.Kind != KindHistogram || !slices.IsSorted(d.Buckets)) {
+			errs = append(errs, fmt.Errorf("metric %q: buckets need a histogram and ascending boundaries", d.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// prometheusUnits are the unit suffixes the collector's Prometheus exporter
+// appends for the units the catalog uses; annotations such as {request}
+// add none.
+var prometheusUnits = map[string]string{"s": "seconds", "ms": "milliseconds", "By": "bytes"}
+
+// PrometheusName is the name d is exported under by the collector's
+// Prometheus exporter with metric suffixes enabled, e.g.
+// http_server_requests_total for http.server.requests.
+func PrometheusName(d Definition) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, d.Name)
+	unit := prometheusUnits[d.Unit]
+	if d.Unit == "1" && d.Kind == KindGauge {
+		unit = "ratio"
+	}
+	if unit != "" && !strings.HasSuffix(name, "_"+unit) {
+		name += "_" + unit
+	}
+	if d.Kind == KindCounter && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	if err := CheckCatalog(Catalog); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckCatalogReportsDrift(t *testing.T) {
+	tests := []struct {
+		name    string
+		defs    []Definition
+		wantErr string
+	}{
+		{
+			name:    "defined twice",
+			defs:    []Definition{HTTPServerRequests, HTTPServerRequests},
+			wantErr: `metric "http.server.requests" is defined twice`,
+		},
+		{
+			name: "same Prometheus name",
+			defs: []Definition{
+				HTTPServerRequests,
+				{Name: "http_server.requests", Kind: KindCounter, Description: "d", Unit: "{request}"},
+			},
+			wantErr: "both exported as http_server_requests_total",
+		},
+		{
+			name:    "Prometheus suffix",
+			defs:    []Definition{{Name: "app.jobs_total", Kind: KindCounter, Description: "d", Unit: "{job}"}},
+			wantErr: "ends with the Prometheus suffix _total",
+		},
+		{
+			name:    "no unit",
+			defs:    []Definition{{Name: "app.jobs", Kind: KindCounter, Description: "d"}},
+			wantErr: "needs a description and a unit",
+		},
+		{
+			name:    "unknown kind",
+			defs:    []Definition{{Name: "app.jobs", Kind: "summary", Description: "d", Unit: "{job}"}},
+			wantErr: "unknown kind",
+		},
+		{
+			name:    "unsorted buckets",
+			defs:    []Definition{{Name: "app.wait", Kind: KindHistogram, Description: "d", Unit: "s", Buckets: []float64{1, 0.5}}},
+			wantErr: "ascending boundaries",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCatalog(tt.defs)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckCatalog() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		def  Definition
+		want string
+	}{
+		{HTTPServerRequests, "http_server_requests_total"},
+		{HTTPServerActiveRequests, "http_server_active_requests"},
+		{HTTPServerRequestDuration, "http_server_request_duration_seconds"},
+		{HTTPServerRequestBodySize, "http_server_request_body_size_bytes"},
+		{HTTPServerLastRequestTimestamp, "http_server_last_request_timestamp_seconds"},
+		{HTTPServerThrottledRequests, "http_server_throttled_requests_total"},
+		{EndpointEnabled, "app_endpoint_enabled_ratio"},
+		{MemoryUtilization, "app_memory_utilization_ratio"},
+	}
+	for _, tt := range tests {
+		if got := PrometheusName(tt.def); got != tt.want {
+			t.Errorf("PrometheusName(%s) = %s, want %s", tt.def.Name, got, tt.want)
+		}
+	}
+}
+
+// catalogEntries parses catalog.go and returns the names of the Definition
+// variables it declares and of those listed in Catalog.
+func catalogEntries(t *testing.T) (defined, listed map[string]bool) {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "catalog.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defined, listed = make(map[string]bool), make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if i >= len(spec.Values) {
+				break
+			}
+			lit, ok := spec.Values[i].(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			switch typ := lit.Type.(type) {
+			case *ast.Ident: // Definition{...}
+				if typ.Name == "Definition" {
+					defined[name.Name] = true
+				}
+			case *ast.ArrayType: // Catalog = []Definition{...}
+				if name.Name == "Catalog" {
+					for _, elt := range lit.Elts {
+						if id, ok := elt.(*ast.Ident); ok {
+							listed[id.Name] = true
+						}
+					}
+				}
+			}
+		}
+		return false
+	})
+	return defined, listed
+}
+
+// TestCommandsUseCatalog checks that the commands, and the code genhandler
+// generates, create and query instruments through catalog entries rather
+// than spelling metric names out.
+func TestCommandsUseCatalog(t *testing.T) {
+	defined, listed := catalogEntries(t)
+	ref := regexp.MustCompile(`\bmetrics\.([A-Z]\w*)`)
+	for command, paths := range map[string][]string{
+		"smoketest": {"../../cmd/smoketest/main.go"},
+		"genhandler": {
+			"../../cmd/genhandler/templates/handler.go.tmpl",
+			"../../cmd/genhandler/templates/handler_test.go.tmpl",
+		},
+	} {
+		t.Run(command, func(t *testing.T) {
+			var src string
+			for _, path := range paths {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				src += string(b)
+			}
+			for _, d := range Catalog {
+				if strings.Contains(src, `"`+d.Name+`"`) || strings.Contains(src, PrometheusName(d)) {
+					t.Errorf("spells out metric %s instead of using its catalog entry", d.Name)
+				}
+			}
+			uses := 0
+			for _, m := range ref.FindAllStringSubmatch(src, -1) {
+				if !defined[m[1]] {
+					continue
+				}
+				uses++
+				if !listed[m[1]] {
+					t.Errorf("uses metrics.%s, which is missing from Catalog", m[1])
+				}
+			}
+			if uses == 0 {
+				t.Error("uses no catalog entry")
+			}
+		})
+	}
+}
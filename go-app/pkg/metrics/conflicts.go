@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instrumentIdentity is what makes two instruments of the same name
+// compatible: creating one again with the same identity returns the same
+// instrument, while any difference yields a divergent, duplicate stream.
+type instrumentIdentity struct {
+	kind        string
+	unit        string
+	description string
+}
+
+type registration struct {
+	instrumentIdentity
+	site string
+}
+
+// conflicts remembers every instrument created through a checked meter in
+// the process. It is shared across meters because exporters such as
+// Prometheus merge same-named instruments from different scopes.
+var conflicts = struct {
+	sync.Mutex
+	seen map[string]registration
+}{seen: make(map[string]registration)}
+
+// checkRegistration records name with id, or reports a conflict with an
+// earlier registration of the same name. skip is the number of frames
+// between the instrument's creator and this function.
+func checkRegistration(name string, id instrumentIdentity, skip int) error {
+	site := "unknown"
+	if _, file, line, ok := runtime.Caller(skip + 1); ok {
+		site = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	conflicts.Lock()
+	defer conflicts.Unlock()
+	prev, ok := conflicts.seen[name]
+	if !ok {
+		conflicts.seen[name] = registration{id, site}
+		return nil
+	}
+	if prev.instrumentIdentity == id {
+		return nil
+	}
+	return fmt.Errorf("conflicting registration of instrument %q: %s (unit %q, description %q) at %s, already registered as %s (unit %q, description %q) at %s",
+		name, id.kind, id.unit, id.description, site,
+		prev.kind, prev.unit, prev.description, prev.site)
+}
+
+// Checked wraps meter so creating an instrument whose name is already
+// registered in the process with a different kind, unit or description
+// fails with an error naming both call sites, instead of silently creating
+// divergent instruments.
+func Checked(meter metric.Meter) metric.Meter {
+	return checkedMeter{meter}
+}
+
+type checkedMeter struct {
+	metric.Meter
+}
+
+// check is called directly by the checkedMeter methods, so the creator is
+// two frames above it.
+func check(name, kind string, unit, description string) error {
+	return checkRegistration(name, instrumentIdentity{kind, unit, description}, 2)
+}
+
+func (m checkedMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	c := metric.NewInt64CounterConfig(opts...)
+	if err := check(name, "Int64Counter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64Counter(name, opts...)
+}
+
+func (m checkedMeter) Int64UpDownCounter(name string, opts ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	c := metric.NewInt64UpDownCounterConfig(opts...)
+	if err := check(name, "Int64UpDownCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64UpDownCounter(name, opts...)
+}
+
+func (m checkedMeter) Int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	c := metric.NewInt64HistogramConfig(opts...)
+	if err := check(name, "Int64Histogram", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64Histogram(name, opts...)
+}
+
+func (m checkedMeter) Int64Gauge(name string, opts ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	c := metric.NewInt64GaugeConfig(opts...)
+	if err := check(name, "Int64Gauge", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64Gauge(name, opts...)
+}
+
+func (m checkedMeter) Int64ObservableCounter(name string, opts ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	c := metric.NewInt64ObservableCounterConfig(opts...)
+	if err := check(name, "Int64ObservableCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64ObservableCounter(name, opts...)
+}
+
+func (m checkedMeter) Int64ObservableUpDownCounter(name string, opts ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
+	c := metric.NewInt64ObservableUpDownCounterConfig(opts...)
+	if err := check(name, "Int64ObservableUpDownCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64ObservableUpDownCounter(name, opts...)
+}
+
+func (m checkedMeter) Int64ObservableGauge(name string, opts ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	c := metric.NewInt64ObservableGaugeConfig(opts...)
+	if err := check(name, "Int64ObservableGauge", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Int64ObservableGauge(name, opts...)
+}
+
+func (m checkedMeter) Float64Counter(name string, opts ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	c := metric.NewFloat64CounterConfig(opts...)
+	if err := check(name, "Float64Counter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64Counter(name, opts...)
+}
+
+func (m checkedMeter) Float64UpDownCounter(name string, opts ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
+	c := metric.NewFloat64UpDownCounterConfig(opts...)
+	if err := check(name, "Float64UpDownCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64UpDownCounter(name, opts...)
+}
+
+func (m checkedMeter) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	c := metric.NewFloat64HistogramConfig(opts...)
+	if err := check(name, "Float64Histogram", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64Histogram(name, opts...)
+}
+
+func (m checkedMeter) Float64Gauge(name string, opts ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	c := metric.NewFloat64GaugeConfig(opts...)
+	if err := check(name, "Float64Gauge", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64Gauge(name, opts...)
+}
+
+func (m checkedMeter) Float64ObservableCounter(name string, opts ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	c := metric.NewFloat64ObservableCounterConfig(opts...)
+	if err := check(name, "Float64ObservableCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64ObservableCounter(name, opts...)
+}
+
+func (m checkedMeter) Float64ObservableUpDownCounter(name string, opts ...metric.Float64ObservableUpDownCounterOption) (metric.Float64ObservableUpDownCounter, error) {
+	c := metric.NewFloat64ObservableUpDownCounterConfig(opts...)
+	if err := check(name, "Float64ObservableUpDownCounter", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64ObservableUpDownCounter(name, opts...)
+}
+
+func (m checkedMeter) Float64ObservableGauge(name string, opts ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	c := metric.NewFloat64ObservableGaugeConfig(opts...)
+	if err := check(name, "Float64ObservableGauge", c.Unit(), c.Description()); err != nil {
+		return nil, err
+	}
+	return m.Meter.Float64ObservableGauge(name, opts...)
+}
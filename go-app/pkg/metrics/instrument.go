@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// valid reports whether k is one of the known kinds.
+func (k Kind) valid() bool {
+	switch k {
+	case KindCounter, KindUpDownCounter, KindHistogram, KindGauge:
+		return true
+	}
+	return false
+}
+
+// The constructors below create d's instrument with m, applying its
+// description, unit and bucket advice. opts come after the definition's, so
+// callers can add callbacks or override the buckets. Errors name the
+// instrument, so call sites can return them as they are.
+
+// Int64Counter creates d as an Int64Counter.
+func (d Definition) Int64Counter(m metric.Meter, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return create(d, KindCounter, "counter", func() (metric.Int64Counter, error) {
+		return m.Int64Counter(d.Name, append([]metric.Int64CounterOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Float64Counter creates d as a Float64Counter.
+func (d Definition) Float64Counter(m metric.Meter, opts ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	return create(d, KindCounter, "counter", func() (metric.Float64Counter, error) {
+		return m.Float64Counter(d.Name, append([]metric.Float64CounterOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Int64UpDownCounter creates d as an Int64UpDownCounter.
+func (d Definition) Int64UpDownCounter(m metric.Meter, opts ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	return create(d, KindUpDownCounter, "counter", func() (metric.Int64UpDownCounter, error) {
+		return m.Int64UpDownCounter(d.Name, append([]metric.Int64UpDownCounterOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Float64Histogram creates d as a Float64Histogram. The definition's
+// buckets are advisory; views still take precedence.
+func (d Definition) Float64Histogram(m metric.Meter, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return create(d, KindHistogram, "histogram", func() (metric.Float64Histogram, error) {
+		base := []metric.Float64HistogramOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}
+		if len(d.Buckets) > 0 {
+			base = append(base, metric.WithExplicitBucketBoundaries(d.Buckets...))
+		}
+		return m.Float64Histogram(d.Name, append(base, opts...)...)
+	})
+}
+
+// Int64Histogram creates d as an Int64Histogram.
+func (d Definition) Int64Histogram(m metric.Meter, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	return create(d, KindHistogram, "histogram", func() (metric.Int64Histogram, error) {
+		base := []metric.Int64HistogramOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}
+		if len(d.Buckets) > 0 {
+			base = append(base, metric.WithExplicitBucketBoundaries(d.Buckets...))
+		}
+		return m.Int64Histogram(d.Name, append(base, opts...)...)
+	})
+}
+
+// Int64Gauge creates d as a synchronous Int64Gauge.
+func (d Definition) Int64Gauge(m metric.Meter, opts ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	return create(d, KindGauge, "gauge", func() (metric.Int64Gauge, error) {
+		return m.Int64Gauge(d.Name, append([]metric.Int64GaugeOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Int64ObservableGauge creates d as an Int64ObservableGauge.
+func (d Definition) Int64ObservableGauge(m metric.Meter, opts ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	return create(d, KindGauge, "gauge", func() (metric.Int64ObservableGauge, error) {
+		return m.Int64ObservableGauge(d.Name, append([]metric.Int64ObservableGaugeOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Float64ObservableGauge creates d as a Float64ObservableGauge.
+func (d Definition) Float64ObservableGauge(m metric.Meter, opts ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	return create(d, KindGauge, "gauge", func() (metric.Float64ObservableGauge, error) {
+		return m.Float64ObservableGauge(d.Name, append([]metric.Float64ObservableGaugeOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Int64ObservableCounter creates d as an Int64ObservableCounter.
+func (d Definition) Int64ObservableCounter(m metric.Meter, opts ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	return create(d, KindCounter, "counter", func() (metric.Int64ObservableCounter, error) {
+		return m.Int64ObservableCounter(d.Name, append([]metric.Int64ObservableCounterOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+// Float64ObservableCounter creates d as a Float64ObservableCounter.
+func (d Definition) Float64ObservableCounter(m metric.Meter, opts ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	return create(d, KindCounter, "counter", func() (metric.Float64ObservableCounter, error) {
+		return m.Float64ObservableCounter(d.Name, append([]metric.Float64ObservableCounterOption{metric.WithDescription(d.Description), metric.WithUnit(d.Unit)}, opts...)...)
+	})
+}
+
+func create[T any](d Definition, kind Kind, noun string, fn func() (T, error)) (T, error) {
+	var zero T
+	if d.Kind != kind {
+		return zero, fmt.Errorf("metric %q is defined as a %s, not a %s", d.Name, d.Kind, kind)
+	}
+	inst, err := fn()
+	if err != nil {
+		return zero, fmt.Errorf("failed to create %s %s: %w", d.Name, noun, err)
+	}
+	return inst, nil
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+// PreaggregatedCounter is an Int64Counter for extremely hot call sites. Add
+// only bumps a sum in one of several independently locked shards, picked at
+// random so concurrent callers rarely contend; the sums are flushed to the
+// wrapped counter every interval. Exported values lag by up to one interval,
+// and since measurements reach the SDK without their context, they carry no
+// exemplars.
+type PreaggregatedCounter struct {
+	embedded.Int64Counter
+
+	counter metric.Int64Counter
+	shards  []preaggShard
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type preaggShard struct {
+	mu   sync.Mutex
+	sums map[attribute.Distinct]*preaggSum
+	// Keeps neighbouring shards' locks off the same cache line.
+	_ [64]byte
+}
+
+type preaggSum struct {
+	attrs attribute.Set
+	value int64
+}
+
+// Preaggregate wraps counter and starts flushing it every interval until
+// Close is called.
+func Preaggregate(counter metric.Int64Counter, interval time.Duration) *PreaggregatedCounter {
+	c := &PreaggregatedCounter{
+		counter: counter,
+		shards:  make([]preaggShard, runtime.GOMAXPROCS(0)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i].sums = make(map[attribute.Distinct]*preaggSum)
+	}
+	go c.flushEvery(interval)
+	return c
+}
+
+// Add adds incr to the sum for the measurement's attributes.
+func (c *PreaggregatedCounter) Add(_ context.Context, incr int64, options ...metric.AddOption) {
+	attrs := metric.NewAddConfig(options).Attributes()
+	shard := &c.shards[rand.N(len(c.shards))]
+
+	shard.mu.Lock()
+	sum, ok := shard.sums[attrs.Equivalent()]
+	if !ok {
+		sum = &preaggSum{attrs: attrs}
+		shard.sums[attrs.Equivalent()] = sum
+	}
+	sum.value += incr
+	shard.mu.Unlock()
+}
+
+// Flush adds the pending sums to the wrapped counter.
+func (c *PreaggregatedCounter) Flush(ctx context.Context) {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.mu.Lock()
+		sums := shard.sums
+		shard.sums = make(map[attribute.Distinct]*preaggSum, len(sums))
+		shard.mu.Unlock()
+
+		for _, sum := range sums {
+			c.counter.Add(ctx, sum.value, metric.WithAttributeSet(sum.attrs))
+		}
+	}
+}
+
+// Close stops the periodic flush and flushes what is pending. It should run
+// before the meter provider shuts down.
+func (c *PreaggregatedCounter) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+	c.Flush(ctx)
+	return nil
+}
+
+func (c *PreaggregatedCounter) flushEvery(interval time.Duration) {
+	defer close(c.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush(context.Background())
+		case <-c.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package metrics provides a registry of named instruments whose
+// description, unit and histogram buckets come from declarative definitions
+// (compiled-in defaults, optionally overridden by a config file) instead of
+// being hand-constructed where they are used.
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Kind is the type of instrument a Definition describes.
+type Kind string
+
+const (
+	KindCounter       Kind = "counter"
+	KindUpDownCounter Kind = "updowncounter"
+	KindHistogram     Kind = "histogram"
+	KindGauge         Kind = "gauge"
+)
+
+// Definition holds the defaults of a named instrument.
+type Definition struct {
+	Name        string    `json:"name"`
+	Kind        Kind      `json:"kind"`
+	Description string    `json:"description,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Buckets     []float64 `json:"buckets,omitempty"`
+}
+
+// LoadDefinitions reads a JSON array of definitions from path.
+func LoadDefinitions(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric definitions: %w", err)
+	}
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("failed to parse metric definitions %s: %w", path, err)
+	}
+	return defs, nil
+}
+
+// Registry creates instruments from definitions and caches them by name, so
+// every caller asking for the same name shares one instrument.
+type Registry struct {
+	meter metric.Meter
+
+	mu          sync.Mutex
+	defs        map[string]Definition
+	instruments map[string]any
+}
+
+// NewRegistry returns a registry creating instruments with meter. Later
+// definitions override earlier ones with the same name, so file-based
+// definitions can be appended to compiled-in defaults.
+func NewRegistry(meter metric.Meter, defs ...Definition) (*Registry, error) {
+	r := &Registry{
+		meter:       meter,
+		defs:        make(map[string]Definition, len(defs)),
+		instruments: make(map[string]any),
+	}
+	for _, d := range defs {
+		if d.Name == "" {
+			return nil, errors.New("metric definition without a name")
+		}
+		if !d.Kind.valid() {
+			return nil, fmt.Errorf("metric definition %q has unknown kind %q", d.Name, d.Kind)
+		}
+		r.defs[d.Name] = d
+	}
+	return r, nil
+}
+
+// Counter returns the Int64Counter named name.
+func (r *Registry) Counter(name string) (metric.Int64Counter, error) {
+	return instrument(r, name, KindCounter, func(d Definition) (metric.Int64Counter, error) {
+		return d.Int64Counter(r.meter)
+	})
+}
+
+// UpDownCounter returns the Int64UpDownCounter named name.
+func (r *Registry) UpDownCounter(name string) (metric.Int64UpDownCounter, error) {
+	return instrument(r, name, KindUpDownCounter, func(d Definition) (metric.Int64UpDownCounter, error) {
+		return d.Int64UpDownCounter(r.meter)
+	})
+}
+
+// Histogram returns the Float64Histogram named name. Buckets from the
+// definition are passed as advisory boundaries; views still take precedence.
+func (r *Registry) Histogram(name string) (metric.Float64Histogram, error) {
+	return instrument(r, name, KindHistogram, func(d Definition) (metric.Float64Histogram, error) {
+		return d.Float64Histogram(r.meter)
+	})
+}
+
+// Gauge returns the Int64Gauge named name.
+func (r *Registry) Gauge(name string) (metric.Int64Gauge, error) {
+	return instrument(r, name, KindGauge, func(d Definition) (metric.Int64Gauge, error) {
+		return d.Int64Gauge(r.meter)
+	})
+}
+
+// instrument returns the cached instrument named name, creating it from its
+// definition on first use. Names without a definition get an instrument
+// without description or unit.
+func instrument[T any](r *Registry, name string, kind Kind, create func(Definition) (T, error)) (T, error) {
+	var zero T
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.defs[name]
+	if !ok {
+		d = Definition{Name: name, Kind: kind}
+	}
+	if d.Kind != kind {
+		return zero, fmt.Errorf("metric %q is defined as a %s, not a %s", name, d.Kind, kind)
+	}
+	if inst, ok := r.instruments[name]; ok {
+		return inst.(T), nil
+	}
+
+	inst, err := create(d)
+	if err != nil {
+		return zero, err
+	}
+	r.instruments[name] = inst
+	return inst, nil
+}
@@ -0,0 +1,153 @@
+// Package middleware assembles HTTP middleware in a fixed order of stages,
+// so the guarantees individual middleware rely on (running inside the
+// request span, being measured, seeing the caller's identity) hold by
+// construction rather than by careful nesting.
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Middleware wraps a handler.
+type Middleware func(http.Handler) http.Handler
+
+// Stage is the position of a middleware in a Chain. Stages run from the
+// outermost, Recovery, to the innermost, Application.
+type Stage int
+
+const (
+	// Recovery turns a panic anywhere in the chain into a 500.
+	Recovery Stage = iota
+	// RequestID assigns the request its ID.
+	RequestID
+	// Auth identifies the caller, before the request span starts so
+	// sampling can take the caller into account.
+	Auth
+	// Tracing starts the request span (otelhttp).
+	Tracing
+	// Metrics measures the request, inside the request span so
+	// measurements carry exemplars.
+	Metrics
+	// RateLimit rejects requests over their limit, inside the request span
+	// and the metrics so rejections are traced and measured.
+	RateLimit
+	// Application is everything else, run in registration order.
+	Application
+)
+
+var stageNames = [...]string{"recovery", "request-id", "auth", "tracing", "metrics", "rate-limit", "application"}
+
+func (s Stage) String() string {
+	if s < 0 || int(s) >= len(stageNames) {
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+	return stageNames[s]
+}
+
+// repeatable reports whether more than one middleware may use the stage.
+func (s Stage) repeatable() bool { return s == Metrics || s == Application }
+
+type entry struct {
+	stage Stage
+	name  string
+	mw    Middleware
+}
+
+// Chain builds a handler from middleware registered stage by stage.
+// Middleware must be registered in stage order, and every stage but
+// Metrics and Application takes at most one; Then reports violations.
+type Chain struct {
+	entries  []entry
+	errs     []error
+	known    map[string]bool // every name passed to Use
+	disabled map[string]bool
+}
+
+// NewChain returns an empty chain.
+func NewChain() *Chain {
+	return &Chain{known: make(map[string]bool), disabled: make(map[string]bool)}
+}
+
+// Disable leaves the named middleware out of the chain, whether it is
+// registered before or after the call. Then fails on names no Use
+// registered, so a misspelt name is not silently ignored.
+func (c *Chain) Disable(names ...string) *Chain {
+	for _, name := range names {
+		c.disabled[name] = true
+	}
+	c.entries = slices.DeleteFunc(c.entries, func(e entry) bool { return c.disabled[e.name] })
+	return c
+}
+
+// Use registers mw, named for error messages and Disable, at stage. A nil
+// mw is skipped, for middleware that is switched off.
+func (c *Chain) Use(stage Stage, name string, mw Middleware) *Chain {
+	c.known[name] = true
+	if mw == nil || c.disabled[name] {
+		return c
+	}
+	if stage < Recovery || stage > Application {
+		c.errs = append(c.errs, fmt.Errorf("middleware %q: unknown stage %d", name, int(stage)))
+		return c
+	}
+	if n := len(c.entries); n > 0 {
+		last := c.entries[n-1]
+		switch {
+		case stage < last.stage:
+			c.errs = append(c.errs, fmt.Errorf("middleware %q (%s) registered after %q (%s): stages must run in the order %s",
+				name, stage, last.name, last.stage, strings.Join(stageNames[:], ", ")))
+			return c
+		case stage == last.stage && !stage.repeatable():
+			c.errs = append(c.errs, fmt.Errorf("middleware %q (%s) registered after %q: stage %s takes one middleware",
+				name, stage, last.name, stage))
+			return c
+		}
+	}
+	c.entries = append(c.entries, entry{stage: stage, name: name, mw: mw})
+	return c
+}
+
+// Then wraps h in the chain's middleware, the first registered outermost.
+// It fails if the chain was built out of order, if it measures requests
+// without tracing them, or if it disables middleware it does not have.
+func (c *Chain) Then(h http.Handler) (http.Handler, error) {
+	errs := c.errs
+	for _, name := range slices.Sorted(maps.Keys(c.disabled)) {
+		if !c.known[name] {
+			errs = append(errs, fmt.Errorf("cannot disable unknown middleware %q", name))
+		}
+	}
+	if c.has(Metrics) && !c.has(Tracing) {
+		errs = append(errs, errors.New("metrics middleware needs a tracing middleware outside it"))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid middleware chain: %w", errors.Join(errs...))
+	}
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		h = c.entries[i].mw(h)
+	}
+	return h, nil
+}
+
+// Names returns the names of the middleware in the chain, outermost first.
+func (c *Chain) Names() []string {
+	names := make([]string, len(c.entries))
+	for i, e := range c.entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+func (c *Chain) has(stage Stage) bool {
+	for _, e := range c.entries {
+		if e.stage == stage {
+			return true
+		}
+	}
+	return false
+}
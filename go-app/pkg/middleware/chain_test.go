@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// record returns a middleware appending name to the X-Order response
+// header on the way in.
+func record(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type use struct {
+	stage Stage
+	name  string
+	mw    Middleware
+}
+
+func build(uses []use, disable []string) *Chain {
+	c := NewChain().Disable(disable...)
+	for _, u := range uses {
+		c.Use(u.stage, u.name, u.mw)
+	}
+	return c
+}
+
+func TestChainThen(t *testing.T) {
+	tests := []struct {
+		name    string
+		uses    []use
+		disable []string
+		want    []string // order the middleware ran in, outermost first
+	}{
+		{
+			name: "stage order",
+			uses: []use{
+				{Recovery, "recover", record("recover")},
+				{RequestID, "request-id", record("request-id")},
+				{Tracing, "otelhttp", record("otelhttp")},
+				{Metrics, "active", record("active")},
+				{Metrics, "http-metrics", record("http-metrics")},
+				{Application, "sessions", record("sessions")},
+				{Application, "logging", record("logging")},
+			},
+			want: []string{"recover", "request-id", "otelhttp", "active", "http-metrics", "sessions", "logging"},
+		},
+		{
+			// Rejected requests are still traced and measured.
+			name: "rate limit inside tracing and metrics",
+			uses: []use{
+				{Auth, "client-identity", record("client-identity")},
+				{Tracing, "otelhttp", record("otelhttp")},
+				{Metrics, "http-metrics", record("http-metrics")},
+				{RateLimit, "rate-limit", record("rate-limit")},
+				{Application, "sessions", record("sessions")},
+			},
+			want: []string{"client-identity", "otelhttp", "http-metrics", "rate-limit", "sessions"},
+		},
+		{
+			name: "nil middleware skipped",
+			uses: []use{
+				{Recovery, "recover", record("recover")},
+				{Auth, "auth", nil},
+				{Application, "logging", record("logging")},
+			},
+			want: []string{"recover", "logging"},
+		},
+		{
+			name: "disabled middleware skipped",
+			uses: []use{
+				{Recovery, "recover", record("recover")},
+				{Application, "sessions", record("sessions")},
+				{Application, "logging", record("logging")},
+			},
+			disable: []string{"sessions"},
+			want:    []string{"recover", "logging"},
+		},
+		{
+			name: "disabled single-slot stage frees it",
+			uses: []use{
+				{Tracing, "otelhttp", record("otelhttp")},
+				{Tracing, "other-tracing", record("other-tracing")},
+			},
+			disable: []string{"otelhttp"},
+			want:    []string{"other-tracing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := build(tt.uses, tt.disable)
+			if got := c.Names(); !slices.Equal(got, tt.want) {
+				t.Errorf("Names() = %v, want %v", got, tt.want)
+			}
+			h, err := c.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Order", "handler")
+			}))
+			if err != nil {
+				t.Fatalf("Then() error = %v", err)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got, want := rec.Header().Values("X-Order"), append(slices.Clone(tt.want), "handler"); !slices.Equal(got, want) {
+				t.Errorf("ran %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestChainDisableAfterUse(t *testing.T) {
+	c := NewChain().
+		Use(Recovery, "recover", record("recover")).
+		Use(Application, "sessions", record("sessions")).
+		Disable("sessions")
+	if got, want := c.Names(), []string{"recover"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestChainErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		uses    []use
+		disable []string
+		wantErr string
+	}{
+		{
+			name: "out of stage order",
+			uses: []use{
+				{Tracing, "otelhttp", record("otelhttp")},
+				{Recovery, "recover", record("recover")},
+			},
+			wantErr: `middleware "recover" (recovery) registered after "otelhttp" (tracing)`,
+		},
+		{
+			name: "rate limit outside tracing",
+			uses: []use{
+				{RateLimit, "rate-limit", record("rate-limit")},
+				{Tracing, "otelhttp", record("otelhttp")},
+			},
+			wantErr: `middleware "otelhttp" (tracing) registered after "rate-limit" (rate-limit)`,
+		},
+		{
+			name: "duplicate single-slot stage",
+			uses: []use{
+				{RequestID, "request-id", record("request-id")},
+				{RequestID, "other-id", record("other-id")},
+			},
+			wantErr: "stage request-id takes one middleware",
+		},
+		{
+			name:    "unknown stage",
+			uses:    []use{{Stage(42), "odd", record("odd")}},
+			wantErr: `middleware "odd": unknown stage 42`,
+		},
+		{
+			name: "metrics without tracing",
+			uses: []use{
+				{Recovery, "recover", record("recover")},
+				{Metrics, "http-metrics", record("http-metrics")},
+			},
+			wantErr: "metrics middleware needs a tracing middleware outside it",
+		},
+		{
+			name: "tracing disabled under metrics",
+			uses: []use{
+				{Tracing, "otelhttp", record("otelhttp")},
+				{Metrics, "http-metrics", record("http-metrics")},
+			},
+			disable: []string{"otelhttp"},
+			wantErr: "metrics middleware needs a tracing middleware outside it",
+		},
+		{
+			name:    "disable unknown name",
+			uses:    []use{{Recovery, "recover", record("recover")}},
+			disable: []string{"sesions"},
+			wantErr: `cannot disable unknown middleware "sesions"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := build(tt.uses, tt.disable).Then(http.NotFoundHandler())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Then() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStageString(t *testing.T) {
+	for stage, want := range map[Stage]string{
+		Recovery:    "recovery",
+		Tracing:     "tracing",
+		Application: "application",
+		Stage(-1):   "Stage(-1)",
+	} {
+		if got := stage.String(); got != want {
+			t.Errorf("Stage(%d).String() = %q, want %q", int(stage), got, want)
+		}
+	}
+}
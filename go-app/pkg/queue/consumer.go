@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// Consumer reads one topic as a member of a group.
+type Consumer struct {
+	receiver   receiver
+	topic      string
+	group      string
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	duration   metric.Float64Histogram
+	consumed   metric.Int64Counter
+	queueTime  metric.Float64Histogram
+	lagDist    metric.Int64Histogram
+	objective  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]int64 // by partition, as of the last message fetched
+}
+
+// newConsumer returns a consumer of r. Besides the
+// messaging.process.duration and messaging.client.consumed.messages
+// metrics, it exports app.messaging.consumer.pending: per partition, if the
+// transport has them, how many messages were still behind the last one
+// fetched.
+//
+// For a consumer lag SLO, every message also records how long it waited
+// between being published and being processed, by the message timestamp,
+// in app.messaging.queue.time, and the lag when it was fetched in
+// app.messaging.consumer.lag. objective, the time in queue the consumer is
+// meant to keep up with, is added to the queue time buckets, so the share
+// of messages within it can be read off the histogram exactly.
+func newConsumer(r receiver, topic, group string, objective time.Duration, tracer trace.Tracer, meter metric.Meter, propagator propagation.TextMapPropagator) (*Consumer, error) {
+	c := &Consumer{
+		receiver:   r,
+		topic:      topic,
+		group:      group,
+		tracer:     tracer,
+		propagator: propagator,
+		objective:  objective,
+		pending:    make(map[string]int64),
+	}
+	var err error
+	c.duration, err = metrics.MessagingProcessDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	c.consumed, err = metrics.MessagingClientConsumedMessages.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	buckets := metrics.MessagingQueueTime.Buckets
+	if objective > 0 && !slices.Contains(buckets, objective.Seconds()) {
+		buckets = append(slices.Clone(buckets), objective.Seconds())
+		slices.Sort(buckets)
+	}
+	c.queueTime, err = metrics.MessagingQueueTime.Float64Histogram(meter,
+		metric.WithExplicitBucketBoundaries(buckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.lagDist, err = metrics.MessagingConsumerLag.Int64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = metrics.MessagingConsumerPending.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for partition, pending := range c.pending {
+				attrs := []attribute.KeyValue{
+					r.system(),
+					semconv.MessagingDestinationName(c.topic),
+					semconv.MessagingConsumerGroupName(c.group),
+				}
+				if partition != "" {
+					attrs = append(attrs, semconv.MessagingDestinationPartitionID(partition))
+				}
+				o.Observe(pending, metric.WithAttributes(attrs...))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Backoff between failed fetches: doubled after each failure in a row, up
+// to the maximum, and reset by the next message.
+const (
+	minFetchBackoff = 100 * time.Millisecond
+	maxFetchBackoff = 30 * time.Second
+)
+
+// Run fetches messages and passes each to handle under a consumer span,
+// acknowledging it afterwards, until ctx is done. A message whose handler
+// fails is still acknowledged: the failure is recorded on its span, and
+// retrying is up to the handler.
+//
+// Transport errors do not stop it: a failed fetch is passed to onError with
+// the delay before the next attempt, and retried with exponential backoff;
+// a failed acknowledgement is passed to onError and left to the transport,
+// which delivers the message again.
+func (c *Consumer) Run(ctx context.Context, handle Handler, onError func(err error, retryIn time.Duration)) {
+	backoff := minFetchBackoff
+	for {
+		d, err := c.receiver.fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			onError(fmt.Errorf("failed to fetch message from %s: %w", c.topic, err), backoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			backoff = min(2*backoff, maxFetchBackoff)
+			continue
+		}
+		backoff = minFetchBackoff
+		c.process(ctx, d, handle)
+		if err := d.ack(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			onError(fmt.Errorf("failed to acknowledge message from %s: %w", c.topic, err), 0)
+		}
+	}
+}
+
+// process runs handle under a "process <topic>" span. The span starts a new
+// trace linked to the producer span found in the message headers, and
+// carries the message's baggage.
+//
+// The span also records where the message's end-to-end latency went before
+// processing: queue.time (seconds since the message timestamp, clamped at
+// zero against clock skew), queue.lag and queue.within_objective. With the
+// linked send span and this span's own duration, that splits the latency
+// into publishing, waiting and processing.
+func (c *Consumer) process(ctx context.Context, d delivery, handle Handler) {
+	c.mu.Lock()
+	c.pending[d.partition] = d.pending
+	c.mu.Unlock()
+
+	producerCtx := c.propagator.Extract(ctx, d.headers)
+	attrs := []attribute.KeyValue{
+		c.receiver.system(),
+		semconv.MessagingOperationTypeProcess,
+		semconv.MessagingOperationName("process"),
+		semconv.MessagingDestinationName(c.topic),
+		semconv.MessagingConsumerGroupName(c.group),
+	}
+	if d.partition != "" {
+		attrs = append(attrs, semconv.MessagingDestinationPartitionID(d.partition))
+	}
+	ctx, span := c.tracer.Start(producerCtx, "process "+c.topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(producerCtx)),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(d.attrs...),
+		trace.WithAttributes(semconv.MessagingMessageBodySize(len(d.value))),
+	)
+	defer span.End()
+
+	queueAttrs := metric.WithAttributes(
+		c.receiver.system(),
+		semconv.MessagingDestinationName(c.topic),
+		semconv.MessagingConsumerGroupName(c.group),
+	)
+	span.SetAttributes(attribute.Int64("queue.lag", d.pending))
+	c.lagDist.Record(ctx, d.pending, queueAttrs)
+	if !d.published.IsZero() {
+		waited := max(time.Since(d.published), 0)
+		span.SetAttributes(attribute.Float64("queue.time", waited.Seconds()))
+		if c.objective > 0 {
+			span.SetAttributes(attribute.Bool("queue.within_objective", waited <= c.objective))
+		}
+		c.queueTime.Record(ctx, waited.Seconds(), queueAttrs)
+	}
+
+	start := time.Now()
+	err := handle(ctx, d.key, d.value)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	}
+	c.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	c.consumed.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// Close stops consuming and closes the connections.
+func (c *Consumer) Close() error { return c.receiver.Close() }
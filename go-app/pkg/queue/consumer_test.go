@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// flakyReceiver fails its first failures fetches, then delivers messages
+// until ctx is done.
+type flakyReceiver struct {
+	failures int
+	fetches  int
+}
+
+func (r *flakyReceiver) system() attribute.KeyValue {
+	return semconv.MessagingSystemKafka
+}
+
+func (r *flakyReceiver) fetch(ctx context.Context) (delivery, error) {
+	r.fetches++
+	if r.fetches <= r.failures {
+		return delivery{}, errors.New("broker unavailable")
+	}
+	return delivery{
+		headers: propagation.MapCarrier{},
+		ack:     func(context.Context) error { return nil },
+	}, ctx.Err()
+}
+
+func (r *flakyReceiver) Close() error { return nil }
+
+func TestConsumerRetriesFailedFetches(t *testing.T) {
+	r := &flakyReceiver{failures: 3}
+	c, err := newConsumer(r, "work-jobs", "test", 0, tracenoop.NewTracerProvider().Tracer("test"), noop.NewMeterProvider().Meter("test"), propagation.TraceContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var delays []time.Duration
+	c.Run(ctx, func(context.Context, []byte, []byte) error {
+		cancel()
+		return nil
+	}, func(err error, retryIn time.Duration) {
+		delays = append(delays, retryIn)
+	})
+
+	// Three failures, the message, and the fetch cut short by the cancel.
+	if r.fetches != 5 {
+		t.Errorf("fetched %d times, want 5", r.fetches)
+	}
+	want := []time.Duration{minFetchBackoff, 2 * minFetchBackoff, 4 * minFetchBackoff}
+	if len(delays) != len(want) {
+		t.Fatalf("retry delays = %v, want %v", delays, want)
+	}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("retry delays = %v, want %v", delays, want)
+			break
+		}
+	}
+}
+
+func TestConsumerStopsWhileBackingOff(t *testing.T) {
+	r := &flakyReceiver{failures: 1 << 30}
+	c, err := newConsumer(r, "work-jobs", "test", 0, tracenoop.NewTracerProvider().Tracer("test"), noop.NewMeterProvider().Meter("test"), propagation.TraceContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx, func(context.Context, []byte, []byte) error { return nil }, func(error, time.Duration) {})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after its context was canceled")
+	}
+}
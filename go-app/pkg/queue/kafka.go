@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+type kafkaSender struct {
+	writer *kafka.Writer
+}
+
+// newKafkaSender writes to cfg.Topic synchronously, so send returns once
+// the partition leader has acknowledged the message.
+func newKafkaSender(cfg Config) *kafkaSender {
+	return &kafkaSender{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Servers...),
+		Topic:                  cfg.Topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		AllowAutoTopicCreation: true,
+		// Messages are sent per request; do not hold them back waiting
+		// for a fuller batch.
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+}
+
+func (s *kafkaSender) system() attribute.KeyValue { return semconv.MessagingSystemKafka }
+
+func (s *kafkaSender) keyAttribute(key []byte) attribute.KeyValue {
+	return semconv.MessagingKafkaMessageKey(string(key))
+}
+
+func (s *kafkaSender) send(ctx context.Context, key, value []byte, headers map[string]string) error {
+	msg := kafka.Message{Key: key, Value: value}
+	for k, v := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return s.writer.WriteMessages(ctx, msg)
+}
+
+// Close flushes pending messages and closes the connections.
+func (s *kafkaSender) Close() error { return s.writer.Close() }
+
+type kafkaReceiver struct {
+	reader *kafka.Reader
+}
+
+func newKafkaReceiver(cfg Config) *kafkaReceiver {
+	return &kafkaReceiver{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: cfg.Servers,
+		Topic:   cfg.Topic,
+		GroupID: cfg.Group,
+		MaxWait: time.Second,
+	})}
+}
+
+func (r *kafkaReceiver) system() attribute.KeyValue { return semconv.MessagingSystemKafka }
+
+// fetch returns the next message; its ack commits the offset.
+func (r *kafkaReceiver) fetch(ctx context.Context) (delivery, error) {
+	msg, err := r.reader.FetchMessage(ctx)
+	if err != nil {
+		return delivery{}, err
+	}
+	d := delivery{
+		key:       msg.Key,
+		value:     msg.Value,
+		headers:   headerCarrier{&msg.Headers},
+		published: msg.Time,
+		pending:   max(msg.HighWaterMark-msg.Offset-1, 0),
+		partition: strconv.Itoa(msg.Partition),
+		attrs:     []attribute.KeyValue{semconv.MessagingKafkaOffset(int(msg.Offset))},
+		ack:       func(ctx context.Context) error { return r.reader.CommitMessages(ctx, msg) },
+	}
+	if len(msg.Key) > 0 {
+		d.attrs = append(d.attrs, semconv.MessagingKafkaMessageKey(string(msg.Key)))
+	}
+	return d, nil
+}
+
+// Close leaves the consumer group and closes the connections.
+func (r *kafkaReceiver) Close() error { return r.reader.Close() }
+
+// headerCarrier adapts Kafka message headers to the propagation API, so
+// traceparent, tracestate and baggage travel with the message.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+// natsSystem is the messaging.system of NATS, which the semantic
+// conventions do not list.
+var natsSystem = semconv.MessagingSystemKey.String("nats")
+
+// openNATS connects to the NATS servers of cfg and makes sure a JetStream
+// stream stores cfg.Topic, a subject, and that the durable consumer
+// cfg.Group reads it, creating both if needed.
+func openNATS(ctx context.Context, cfg Config) (*natsSender, *natsReceiver, error) {
+	nc, err := nats.Connect(strings.Join(cfg.Servers, ","), nats.Name(cfg.Group))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+	stream := natsStreamName(cfg.Topic)
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{cfg.Topic},
+	}); err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create jetstream stream %s: %w", stream, err)
+	}
+	consumer, err := js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:   cfg.Group,
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create jetstream consumer %s: %w", cfg.Group, err)
+	}
+	messages, err := consumer.Messages()
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to consume jetstream stream %s: %w", stream, err)
+	}
+	return &natsSender{conn: nc, js: js, subject: cfg.Topic},
+		&natsReceiver{conn: nc, messages: messages},
+		nil
+}
+
+// natsStreamName names the stream of subject, e.g. WORK_JOBS for
+// work-jobs.
+func natsStreamName(subject string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, subject)
+}
+
+type natsSender struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+func (s *natsSender) system() attribute.KeyValue { return natsSystem }
+
+// keyAttribute describes the key as the message ID: the key is sent as
+// Nats-Msg-Id, so JetStream drops a message published twice with the same
+// key within the stream's duplicate window.
+func (s *natsSender) keyAttribute(key []byte) attribute.KeyValue {
+	return semconv.MessagingMessageID(string(key))
+}
+
+// send publishes the message and waits for JetStream to store it.
+func (s *natsSender) send(ctx context.Context, key, value []byte, headers map[string]string) error {
+	msg := nats.NewMsg(s.subject)
+	msg.Data = value
+	for k, v := range headers {
+		msg.Header.Set(k, v)
+	}
+	if len(key) > 0 {
+		msg.Header.Set(jetstream.MsgIDHeader, string(key))
+	}
+	_, err := s.js.PublishMsg(ctx, msg)
+	return err
+}
+
+func (s *natsSender) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+type natsReceiver struct {
+	conn     *nats.Conn
+	messages jetstream.MessagesContext
+}
+
+func (r *natsReceiver) system() attribute.KeyValue { return natsSystem }
+
+// fetch returns the next message; its ack acknowledges it to JetStream.
+func (r *natsReceiver) fetch(ctx context.Context) (delivery, error) {
+	msg, err := r.messages.Next(jetstream.NextContext(ctx))
+	if err != nil {
+		return delivery{}, err
+	}
+	d := delivery{
+		value:   msg.Data(),
+		headers: natsHeaderCarrier(msg.Headers()),
+		ack:     func(context.Context) error { return msg.Ack() },
+	}
+	if id := msg.Headers().Get(jetstream.MsgIDHeader); id != "" {
+		d.key = []byte(id)
+		d.attrs = append(d.attrs, semconv.MessagingMessageID(id))
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		d.published = meta.Timestamp
+		d.pending = int64(meta.NumPending)
+		d.attrs = append(d.attrs, attribute.Int64("messaging.nats.stream.sequence", int64(meta.Sequence.Stream)))
+	}
+	return d, nil
+}
+
+// Close stops consuming and closes the connection.
+func (r *natsReceiver) Close() error {
+	r.messages.Stop()
+	r.conn.Close()
+	return nil
+}
+
+// natsHeaderCarrier adapts NATS message headers to the propagation API.
+// Unlike propagation.HeaderCarrier it does not canonicalize keys, since
+// NATS headers are case-sensitive.
+type natsHeaderCarrier nats.Header
+
+var _ propagation.TextMapCarrier = natsHeaderCarrier{}
+
+func (c natsHeaderCarrier) Get(key string) string { return nats.Header(c).Get(key) }
+
+func (c natsHeaderCarrier) Set(key, value string) { nats.Header(c).Set(key, value) }
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// Producer publishes messages to one topic.
+type Producer struct {
+	sender     sender
+	topic      string
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	duration   metric.Float64Histogram
+	sent       metric.Int64Counter
+}
+
+func newProducer(s sender, topic string, tracer trace.Tracer, meter metric.Meter, propagator propagation.TextMapPropagator) (*Producer, error) {
+	duration, err := metrics.MessagingClientOperationDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := metrics.MessagingClientSentMessages.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &Producer{
+		sender:     s,
+		topic:      topic,
+		tracer:     tracer,
+		propagator: propagator,
+		duration:   duration,
+		sent:       sent,
+	}, nil
+}
+
+// Publish sends value keyed by key under a "send <topic>" producer span,
+// whose context is injected into the message headers. Publish returns once
+// the transport has stored the message.
+func (p *Producer) Publish(ctx context.Context, key, value []byte) error {
+	attrs := []attribute.KeyValue{
+		p.sender.system(),
+		semconv.MessagingOperationTypeSend,
+		semconv.MessagingOperationName("send"),
+		semconv.MessagingDestinationName(p.topic),
+	}
+	ctx, span := p.tracer.Start(ctx, "send "+p.topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(semconv.MessagingMessageBodySize(len(value))),
+	)
+	defer span.End()
+	if len(key) > 0 {
+		span.SetAttributes(p.sender.keyAttribute(key))
+	}
+
+	headers := propagation.MapCarrier{}
+	p.propagator.Inject(ctx, headers)
+
+	start := time.Now()
+	err := p.sender.send(ctx, key, value, headers)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	}
+	p.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	p.sent.Add(ctx, 1, metric.WithAttributes(attrs...))
+	return err
+}
+
+// Close flushes pending messages and closes the connections.
+func (p *Producer) Close() error { return p.sender.Close() }
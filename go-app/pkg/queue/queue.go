@@ -0,0 +1,110 @@
+// Package queue publishes and consumes messages over a transport chosen at
+// runtime, Kafka or NATS JetStream, with the trace context carried in the
+// message headers and the same telemetry for both, following the
+// OpenTelemetry messaging semantic conventions: a "send <topic>" producer
+// span per message, and a "process <topic>" consumer span that starts a new
+// trace linked to the producer's, since processing happens after, and
+// outlives, the request that published the message.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler processes the key and value of one message. The context carries
+// the consumer span.
+type Handler func(ctx context.Context, key, value []byte) error
+
+// Config selects and configures the transport.
+type Config struct {
+	// Transport is "kafka" or "nats".
+	Transport string
+	// Servers are the Kafka brokers or the NATS server URLs.
+	Servers []string
+	// Topic is the Kafka topic or the NATS subject, which is stored in a
+	// JetStream stream named after it.
+	Topic string
+	// Group is the Kafka consumer group or the durable JetStream
+	// consumer.
+	Group string
+	// QueueTimeObjective is the time in queue the consumer is meant to keep
+	// up with; see NewConsumer.
+	QueueTimeObjective time.Duration
+}
+
+// sender writes messages to the transport.
+type sender interface {
+	// system is the messaging.system of the transport.
+	system() attribute.KeyValue
+	// keyAttribute describes a message key on the send span.
+	keyAttribute(key []byte) attribute.KeyValue
+	send(ctx context.Context, key, value []byte, headers map[string]string) error
+	Close() error
+}
+
+// receiver reads messages from the transport as a member of a group.
+type receiver interface {
+	system() attribute.KeyValue
+	// fetch blocks until the next message arrives or ctx is done.
+	fetch(ctx context.Context) (delivery, error)
+	Close() error
+}
+
+// delivery is a received message with what the consumer's telemetry needs
+// to know about it.
+type delivery struct {
+	key, value []byte
+	headers    propagation.TextMapCarrier
+	// published is when the transport accepted the message; zero if
+	// unknown.
+	published time.Time
+	// pending is how many messages the group has yet to fetch after this
+	// one.
+	pending int64
+	// partition is "" for transports without partitions.
+	partition string
+	// attrs describe the message on the process span.
+	attrs []attribute.KeyValue
+	// ack marks the message processed.
+	ack func(context.Context) error
+}
+
+// Open connects to the transport of cfg and returns a producer and a
+// consumer of cfg.Topic.
+func Open(ctx context.Context, cfg Config, tracer trace.Tracer, meter metric.Meter, propagator propagation.TextMapPropagator) (*Producer, *Consumer, error) {
+	var (
+		s   sender
+		r   receiver
+		err error
+	)
+	switch cfg.Transport {
+	case "kafka":
+		s, r = newKafkaSender(cfg), newKafkaReceiver(cfg)
+	case "nats":
+		if s, r, err = openNATS(ctx, cfg); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown queue transport %q, want kafka or nats", cfg.Transport)
+	}
+	producer, err := newProducer(s, cfg.Topic, tracer, meter, propagator)
+	if err != nil {
+		s.Close()
+		r.Close()
+		return nil, nil, err
+	}
+	consumer, err := newConsumer(r, cfg.Topic, cfg.Group, cfg.QueueTimeObjective, tracer, meter, propagator)
+	if err != nil {
+		s.Close()
+		r.Close()
+		return nil, nil, err
+	}
+	return producer, consumer, nil
+}
@@ -0,0 +1,258 @@
+// Package scheduler runs periodic tasks on cron schedules, observable like
+// request work: every run is the root span of its own trace, is counted and
+// timed by outcome, and logs through the OTel log pipeline under the run's
+// span, so its records carry the run's trace and span IDs.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/telemetry"
+)
+
+// Task is the work of one run. The context carries the run's span and is
+// canceled when the run's timeout expires or the scheduler stops.
+type Task func(ctx context.Context) error
+
+// Run outcomes, recorded as cron.outcome.
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+type task struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	timeout  time.Duration
+	run      Task
+
+	mu          sync.Mutex
+	lastSuccess time.Time // zero until the first successful run
+}
+
+// Scheduler runs the tasks added to it, each on its own goroutine. Runs of
+// one task never overlap: a run that outlasts its interval makes the
+// scheduler skip the ticks it covered, which are counted in
+// app.cron.runs.missed.
+type Scheduler struct {
+	tracer trace.Tracer
+	log    *logging.Logger
+
+	mu      sync.Mutex
+	tasks   []*task
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	runs     metric.Int64Counter
+	duration metric.Float64Histogram
+	missed   metric.Int64Counter
+}
+
+// New returns a scheduler with no tasks. Besides app.cron.runs and
+// app.cron.run.duration, by cron.task and cron.outcome, it exports
+// app.cron.last_success, the Unix time of each task's last successful run,
+// so a task that silently stopped succeeding can be alerted on.
+func New(scope telemetry.InstrumentationScope) (*Scheduler, error) {
+	s := &Scheduler{tracer: scope.Tracer(), log: logging.New(scope)}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	meter := scope.Meter()
+	var err error
+	s.runs, err = metrics.CronRuns.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	s.duration, err = metrics.CronRunDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	s.missed, err = metrics.CronRunsMissed.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = metrics.CronLastSuccess.Float64ObservableGauge(meter,
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, t := range s.tasks {
+				t.mu.Lock()
+				last := t.lastSuccess
+				t.mu.Unlock()
+				if !last.IsZero() {
+					o.Observe(float64(last.UnixNano())/1e9, metric.WithAttributes(attribute.String("cron.task", t.name)))
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add schedules run under name. spec is a standard five-field cron
+// expression or a descriptor such as "@hourly" or "@every 1m"; each run is
+// bounded by timeout, if positive. Tasks added after Start start at once.
+func (s *Scheduler) Add(name, spec string, timeout time.Duration, run Task) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for task %s: %w", spec, name, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %q for task %s never fires", spec, name)
+	}
+	t := &task{name: name, spec: spec, schedule: schedule, timeout: timeout, run: run}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, t)
+	if s.started {
+		s.loop(t)
+	}
+	return nil
+}
+
+// Start starts running the tasks on their schedules.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, t := range s.tasks {
+		s.loop(t)
+	}
+}
+
+// Stop stops scheduling runs and waits for the running ones to return.
+// Their contexts are canceled, so runs are expected to return promptly;
+// Stop gives up waiting when ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduled runs still going: %w", ctx.Err())
+	}
+}
+
+// loop runs t on its schedule until the scheduler stops. s.mu must be held.
+func (s *Scheduler) loop(t *task) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		next := t.schedule.Next(time.Now())
+		for !next.IsZero() {
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			s.runOnce(t, next)
+
+			var missed int64
+			next, missed = nextRun(s.ctx, t.schedule, next, time.Now())
+			if missed > 0 {
+				s.missed.Add(s.ctx, missed, metric.WithAttributes(attribute.String("cron.task", t.name)))
+			}
+		}
+	}()
+}
+
+// nextRun returns the first time schedule fires after last that is not
+// before now, and how many times it fired in between, which a run that
+// outlasted its interval missed. It returns the zero time when schedule
+// fires no more or ctx is done.
+func nextRun(ctx context.Context, schedule cron.Schedule, last, now time.Time) (time.Time, int64) {
+	var missed int64
+	next := schedule.Next(last)
+	for !next.IsZero() && next.Before(now) {
+		if ctx.Err() != nil {
+			return time.Time{}, missed
+		}
+		missed++
+		next = schedule.Next(next)
+	}
+	return next, missed
+}
+
+// runOnce runs t under a root span, recording a panic as the run's error.
+func (s *Scheduler) runOnce(t *task, scheduled time.Time) {
+	ctx := s.ctx
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	ctx, span := s.tracer.Start(ctx, "cron "+t.name,
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("cron.task", t.name),
+			attribute.String("cron.schedule", t.spec),
+			attribute.String("cron.scheduled_time", scheduled.UTC().Format(time.RFC3339Nano)),
+			attribute.Float64("cron.delay", time.Since(scheduled).Seconds()),
+		),
+	)
+	defer span.End()
+	s.log.Debug(ctx, "Scheduled task started", logging.String("cron.task", t.name))
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		return t.run(ctx)
+	}()
+	elapsed := time.Since(start)
+
+	attrs := []attribute.KeyValue{attribute.String("cron.task", t.name)}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs,
+			attribute.String("cron.outcome", outcomeFailure),
+			semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)),
+		)
+		s.log.Error(ctx, "Scheduled task failed",
+			logging.String("cron.task", t.name),
+			logging.Duration("cron.duration", elapsed),
+			logging.Err(err),
+		)
+	} else {
+		attrs = append(attrs, attribute.String("cron.outcome", outcomeSuccess))
+		t.mu.Lock()
+		t.lastSuccess = time.Now()
+		t.mu.Unlock()
+		s.log.Info(ctx, "Scheduled task finished",
+			logging.String("cron.task", t.name),
+			logging.Duration("cron.duration", elapsed),
+		)
+	}
+	s.runs.Add(ctx, 1, metric.WithAttributes(attrs...))
+	s.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+}
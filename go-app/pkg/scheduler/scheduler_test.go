@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"my-go-app/pkg/telemetry"
+)
+
+func TestAddRejectsScheduleThatNeverFires(t *testing.T) {
+	s, err := New(telemetry.Scope("scheduler-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Add("never", "0 0 30 2 *", 0, func(context.Context) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "never fires") {
+		t.Fatalf("Add(%q) = %v, want a never fires error", "0 0 30 2 *", err)
+	}
+	if err := s.Add("hourly", "@hourly", 0, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("Add(@hourly) = %v", err)
+	}
+}
+
+// endingSchedule fires every minute until end.
+type endingSchedule struct{ end time.Time }
+
+func (e endingSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	if next.After(e.end) {
+		return time.Time{}
+	}
+	return next
+}
+
+func TestNextRun(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	everyMinute, err := cron.ParseStandard("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	never, err := cron.ParseStandard("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		schedule   cron.Schedule
+		now        time.Time
+		wantNext   time.Time
+		wantMissed int64
+	}{
+		{"on time", everyMinute, base.Add(30 * time.Second), base.Add(time.Minute), 0},
+		{"outlasted three ticks", everyMinute, base.Add(3*time.Minute + 30*time.Second), base.Add(4 * time.Minute), 3},
+		{"next tick is now", everyMinute, base.Add(2 * time.Minute), base.Add(2 * time.Minute), 1},
+		{"never fires", never, base.Add(time.Hour), time.Time{}, 0},
+		{"ends while catching up", endingSchedule{end: base.Add(2 * time.Minute)}, base.Add(time.Hour), time.Time{}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, missed := nextRun(context.Background(), tt.schedule, base, tt.now)
+			if !next.Equal(tt.wantNext) || missed != tt.wantMissed {
+				t.Errorf("nextRun() = %v, %d, want %v, %d", next, missed, tt.wantNext, tt.wantMissed)
+			}
+		})
+	}
+}
+
+func TestNextRunStopsWhenContextDone(t *testing.T) {
+	everySecond := cron.Every(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	next, _ := nextRun(ctx, everySecond, start, start.AddDate(100, 0, 0))
+	if !next.IsZero() {
+		t.Errorf("nextRun() with a done context = %v, want the zero time", next)
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/dependency"
+	"my-go-app/pkg/metrics"
+)
+
+// instrumented decorates a Store with a client span and a duration
+// measurement per operation, labeled with the driver as db.system. Each
+// operation is bounded by the policy's timeout and failures are classified
+// on the span.
+type instrumented struct {
+	next     Store
+	system   string
+	policy   dependency.Policy
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+}
+
+// Instrument wraps s so every operation is traced and timed under the
+// db.system of driver, and governed by policy.
+func Instrument(s Store, driver string, policy dependency.Policy, tracer trace.Tracer, meter metric.Meter) (Store, error) {
+	duration, err := metrics.StoreOperationDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	system := driver
+	switch driver {
+	case "postgres":
+		system = semconv.DBSystemPostgreSQL.Value.AsString()
+	case "mongo":
+		system = semconv.DBSystemMongoDB.Value.AsString()
+	}
+	return &instrumented{next: s, system: system, policy: policy, tracer: tracer, duration: duration}, nil
+}
+
+func (s *instrumented) Get(ctx context.Context, key string) ([]byte, error) {
+	var v []byte
+	err := s.observe(ctx, "GET", func(ctx context.Context) error {
+		var err error
+		v, err = s.next.Get(ctx, key)
+		return err
+	})
+	return v, err
+}
+
+func (s *instrumented) Put(ctx context.Context, key string, value []byte) error {
+	return s.observe(ctx, "PUT", func(ctx context.Context) error {
+		return s.next.Put(ctx, key, value)
+	})
+}
+
+func (s *instrumented) Delete(ctx context.Context, key string) error {
+	return s.observe(ctx, "DELETE", func(ctx context.Context) error {
+		return s.next.Delete(ctx, key)
+	})
+}
+
+// Ping is not traced: it is called by health checks, not by requests.
+func (s *instrumented) Ping(ctx context.Context) error { return s.next.Ping(ctx) }
+
+func (s *instrumented) Close() error { return s.next.Close() }
+
+func (s *instrumented) observe(ctx context.Context, op string, fn func(context.Context) error) error {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemKey.String(s.system),
+		semconv.DBOperation(op),
+	}
+	ctx, span := s.tracer.Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	start := time.Now()
+	opCtx, cancel := s.policy.WithTimeout(ctx)
+	err := fn(opCtx)
+	cancel()
+	elapsed := time.Since(start)
+
+	// A missing key is an expected outcome, not a store failure.
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+		s.policy.Annotate(ctx, s.policy.Classify(err, 0))
+	}
+	s.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	return err
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/telemetry"
+)
+
+// mongoDatabase is used when the connection string names no database.
+const mongoDatabase = "app"
+
+// Mongo is a Store backed by the items collection of a MongoDB database,
+// one document per key. It also implements Repository.
+//
+// Commands are traced by otelmongo below the Store decorator: each one gets
+// a client span named after the collection and command, with db.system and
+// the collection but not the command document, which carries item values.
+// The connection pool is exported as the db.client.connection.* metrics of
+// the semantic conventions.
+type Mongo struct {
+	client *mongo.Client
+	items  *mongo.Collection
+}
+
+// OpenMongo connects to the deployment at uri (mongodb://host:port/db or
+// mongodb+srv://...) and checks that it is reachable.
+func OpenMongo(ctx context.Context, uri string) (*Mongo, error) {
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mongo URI: %w", err)
+	}
+	pool, err := newMongoPoolMetrics(telemetry.Scope("store").Meter())
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(uri).
+		SetMonitor(otelmongo.NewMonitor()).
+		SetPoolMonitor(&event.PoolMonitor{Event: pool.record}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mongo: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.WithoutCancel(ctx))
+		return nil, fmt.Errorf("failed to connect to mongo: %w", err)
+	}
+	db := cs.Database
+	if db == "" {
+		db = mongoDatabase
+	}
+	return &Mongo{client: client, items: client.Database(db).Collection("items")}, nil
+}
+
+// mongoItem is the document stored per key.
+type mongoItem struct {
+	Key   string `bson:"_id"`
+	Value []byte `bson:"value"`
+}
+
+func (m *Mongo) Get(ctx context.Context, key string) ([]byte, error) {
+	var item mongoItem
+	err := m.items.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	return item.Value, err
+}
+
+func (m *Mongo) Put(ctx context.Context, key string, value []byte) error {
+	_, err := m.items.ReplaceOne(ctx, bson.D{{Key: "_id", Value: key}}, mongoItem{Key: key, Value: value},
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *Mongo) Delete(ctx context.Context, key string) error {
+	res, err := m.items.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Mongo) Ping(ctx context.Context) error { return m.client.Ping(ctx, nil) }
+
+func (m *Mongo) Close() error { return m.client.Disconnect(context.Background()) }
+
+// Summary counts the stored items and their total size.
+func (m *Mongo) Summary(ctx context.Context) (Summary, error) {
+	cur, err := m.items.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "items", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "bytes", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$binarySize", Value: "$value"}}}}},
+		}}},
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	var rows []struct {
+		Items int64 `bson:"items"`
+		Bytes int64 `bson:"bytes"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return Summary{}, err
+	}
+	if len(rows) == 0 {
+		return Summary{}, nil // empty collection
+	}
+	return Summary{Items: rows[0].Items, Bytes: rows[0].Bytes}, nil
+}
+
+// mongoPoolMetrics turns the driver's connection pool events into the
+// db.client.connection.* metrics, per pool (one per server address).
+type mongoPoolMetrics struct {
+	count      metric.Int64UpDownCounter
+	createTime metric.Float64Histogram
+	waitTime   metric.Float64Histogram
+	timeouts   metric.Int64Counter
+}
+
+func newMongoPoolMetrics(meter metric.Meter) (*mongoPoolMetrics, error) {
+	var (
+		p   mongoPoolMetrics
+		err error
+	)
+	if p.count, err = metrics.DBClientConnectionCount.Int64UpDownCounter(meter); err != nil {
+		return nil, err
+	}
+	if p.createTime, err = metrics.DBClientConnectionCreateTime.Float64Histogram(meter); err != nil {
+		return nil, err
+	}
+	if p.waitTime, err = metrics.DBClientConnectionWaitTime.Float64Histogram(meter); err != nil {
+		return nil, err
+	}
+	if p.timeouts, err = metrics.DBClientConnectionTimeouts.Int64Counter(meter); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// record handles one pool event. A connection counts as idle from its
+// creation until it is checked out, and again once it is checked back in,
+// until it is closed.
+func (p *mongoPoolMetrics) record(e *event.PoolEvent) {
+	ctx := context.Background()
+	pool := semconv.DBClientConnectionPoolName(e.Address)
+	idle := metric.WithAttributes(pool, semconv.DBClientConnectionStateIdle)
+	used := metric.WithAttributes(pool, semconv.DBClientConnectionStateUsed)
+	switch e.Type {
+	case event.ConnectionCreated:
+		p.count.Add(ctx, 1, idle)
+	case event.ConnectionReady:
+		p.createTime.Record(ctx, e.Duration.Seconds(), metric.WithAttributes(pool))
+	case event.GetSucceeded:
+		p.count.Add(ctx, -1, idle)
+		p.count.Add(ctx, 1, used)
+		p.waitTime.Record(ctx, e.Duration.Seconds(), metric.WithAttributes(pool))
+	case event.GetFailed:
+		p.waitTime.Record(ctx, e.Duration.Seconds(), metric.WithAttributes(pool))
+		if e.Reason == event.ReasonTimedOut {
+			p.timeouts.Add(ctx, 1, metric.WithAttributes(pool))
+		}
+	case event.ConnectionReturned:
+		p.count.Add(ctx, -1, used)
+		p.count.Add(ctx, 1, idle)
+	case event.ConnectionClosed:
+		p.count.Add(ctx, -1, idle)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/XSAM/otelsql"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Postgres is a Store backed by a single Postgres table. It also implements
+// Repository.
+//
+// Queries are traced by otelsql below the Store decorator: each statement
+// gets a client span with db.system and db.statement, and the connection
+// pool is exported as db.sql.connection.* metrics (open connections by
+// idle and in-use status, wait count and wait duration).
+type Postgres struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database at dsn and creates the items table
+// if needed.
+func OpenPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	attrs := otelsql.WithAttributes(semconv.DBSystemPostgreSQL)
+	db, err := otelsql.Open("pgx", dsn, attrs, otelsql.WithSpanOptions(otelsql.SpanOptions{
+		// One span per statement rather than per connection round trip.
+		OmitConnResetSession: true,
+		OmitRows:             true,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %w", err)
+	}
+	if err := otelsql.RegisterDBStatsMetrics(db, attrs); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to register connection pool metrics: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS items (key TEXT PRIMARY KEY, value BYTEA NOT NULL)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create items table: %w", err)
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Get(ctx context.Context, key string) ([]byte, error) {
+	var v []byte
+	err := p.db.QueryRowContext(ctx, `SELECT value FROM items WHERE key = $1`, key).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+func (p *Postgres) Put(ctx context.Context, key string, value []byte) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO items (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`,
+		key, value)
+	return err
+}
+
+func (p *Postgres) Delete(ctx context.Context, key string) error {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM items WHERE key = $1`, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) Ping(ctx context.Context) error { return p.db.PingContext(ctx) }
+
+func (p *Postgres) Close() error { return p.db.Close() }
+
+// Summary counts the stored items and their total size.
+func (p *Postgres) Summary(ctx context.Context) (Summary, error) {
+	var sum Summary
+	err := p.db.QueryRowContext(ctx,
+		`SELECT count(*), coalesce(sum(octet_length(value)), 0) FROM items`).Scan(&sum.Items, &sum.Bytes)
+	return sum, err
+}
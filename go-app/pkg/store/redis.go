@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store backed by Redis string keys under the "items:" prefix.
+type Redis struct {
+	client *redis.Client
+}
+
+// OpenRedis connects to the Redis server at url (redis://host:port/db).
+func OpenRedis(ctx context.Context, url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.client.Get(ctx, "items:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+func (r *Redis) Put(ctx context.Context, key string, value []byte) error {
+	return r.client.Set(ctx, "items:"+key, value, 0).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	n, err := r.client.Del(ctx, "items:"+key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Redis) Ping(ctx context.Context) error { return r.client.Ping(ctx).Err() }
+
+func (r *Redis) Close() error { return r.client.Close() }
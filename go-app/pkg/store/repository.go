@@ -0,0 +1,17 @@
+package store
+
+import "context"
+
+// Repository answers aggregate queries over the stored items, for callers
+// that need more than key-value access. Drivers backed by a database
+// implement it next to Store; check with a type assertion on the Store
+// returned by Open.
+type Repository interface {
+	Summary(ctx context.Context) (Summary, error)
+}
+
+// Summary describes the stored items.
+type Summary struct {
+	Items int64 // number of items
+	Bytes int64 // total size of their values
+}
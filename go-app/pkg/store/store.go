@@ -0,0 +1,42 @@
+// Package store defines the key-value storage used by the items endpoints,
+// with in-memory, Postgres, Redis and MongoDB drivers selectable at runtime. Every
+// driver is wrapped by the same instrumentation decorator, so their latency
+// can be compared like-for-like in traces and metrics.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by Get and Delete for keys that do not exist.
+var ErrNotFound = errors.New("store: key not found")
+
+// Store is a key-value store.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Open connects to the store of the named driver ("memory", "postgres",
+// "redis" or "mongo"); dsn is the driver's connection string and is ignored by the
+// in-memory driver.
+func Open(ctx context.Context, driver, dsn string) (Store, error) {
+	switch driver {
+	case "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return OpenPostgres(ctx, dsn)
+	case "redis":
+		return OpenRedis(ctx, dsn)
+	case "mongo":
+		return OpenMongo(ctx, dsn)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q, want memory, postgres, redis or mongo", driver)
+	}
+}
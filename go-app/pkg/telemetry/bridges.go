@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/bridges/otellogrus"
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.opentelemetry.io/otel"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewZapCore returns a zap core that emits OTLP log records to provider
+// under the "my-go-app/zap" scope, so they carry the provider's resource.
+// Tee it with an existing core to keep console output:
+//
+//	logger := zap.New(zapcore.NewTee(consoleCore, telemetry.NewZapCore(lp)))
+//
+// Pass the request context as a field (zap.Any("ctx", ctx)) to attach its
+// trace and span ids to the record.
+func NewZapCore(provider otellog.LoggerProvider) zapcore.Core {
+	s := Scope("zap")
+	return otelzap.NewCore(s.Name,
+		otelzap.WithVersion(s.Version),
+		otelzap.WithSchemaURL(s.SchemaURL),
+		otelzap.WithLoggerProvider(provider),
+	)
+}
+
+// NewLogrusHook returns a logrus hook that emits OTLP log records to provider
+// under the "my-go-app/logrus" scope, so they carry the provider's resource.
+// Register it with logger.AddHook; entries logged through
+// logger.WithContext(ctx) carry ctx's trace and span ids.
+func NewLogrusHook(provider otellog.LoggerProvider) logrus.Hook {
+	s := Scope("logrus")
+	return otellogrus.NewHook(s.Name,
+		otellogrus.WithVersion(s.Version),
+		otellogrus.WithSchemaURL(s.SchemaURL),
+		otellogrus.WithLoggerProvider(provider),
+	)
+}
+
+// NewOpenTracingBridge returns an opentracing-go tracer that records spans
+// through provider under the "my-go-app/opentracing" scope, for libraries
+// not yet migrated off OpenTracing, and the tracer provider to install in
+// its place. Spans started from the returned provider are visible to
+// opentracing.SpanFromContext and the other way round, so both APIs build
+// one trace:
+//
+//	otTracer, tp := telemetry.NewOpenTracingBridge(tracerProvider, propagator)
+//	opentracing.SetGlobalTracer(otTracer)
+//	otel.SetTracerProvider(tp)
+//
+// Inject and Extract use propagator. Bridge warnings, such as unsupported
+// span references, go to the global OTel error handler.
+func NewOpenTracingBridge(provider trace.TracerProvider, propagator propagation.TextMapPropagator) (*otelbridge.BridgeTracer, trace.TracerProvider) {
+	s := Scope("opentracing")
+	bridge := otelbridge.NewBridgeTracer()
+	wrapped := otelbridge.NewTracerProvider(bridge, provider)
+	bridge.SetOpenTelemetryTracer(wrapped.Tracer(s.Name,
+		trace.WithInstrumentationVersion(s.Version),
+		trace.WithSchemaURL(s.SchemaURL),
+	))
+	bridge.SetTextMapPropagator(propagator)
+	bridge.SetWarningHandler(func(msg string) {
+		otel.Handle(errors.New("opentracing bridge: " + msg))
+	})
+	return bridge, wrapped
+}
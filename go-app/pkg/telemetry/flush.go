@@ -0,0 +1,36 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+type flusher interface {
+	ForceFlush(context.Context) error
+}
+
+// Flush force-flushes the global tracer, meter and logger providers, so
+// everything recorded so far is exported before ctx is done. Providers that
+// cannot flush (e.g. the no-op defaults) are skipped.
+func Flush(ctx context.Context) error {
+	var errs []error
+	for _, p := range []struct {
+		signal   string
+		provider any
+	}{
+		{"traces", otel.GetTracerProvider()},
+		{"metrics", otel.GetMeterProvider()},
+		{"logs", global.GetLoggerProvider()},
+	} {
+		if f, ok := p.provider.(flusher); ok {
+			if err := f.ForceFlush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("flush %s: %w", p.signal, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
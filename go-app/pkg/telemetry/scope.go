@@ -0,0 +1,87 @@
+// Package telemetry holds the instrumentation helpers shared by the
+// application's packages.
+package telemetry
+
+import (
+	"log/slog"
+	"runtime/debug"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// ScopePrefix is prepended to every instrumentation scope name.
+const ScopePrefix = "my-go-app"
+
+// Version is reported as the instrumentation scope version. It defaults to
+// the main module version from the build info and can be set at build time
+// with -ldflags "-X my-go-app/pkg/telemetry.Version=v1.2.3".
+var Version = moduleVersion()
+
+func moduleVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// InstrumentationScope names the tracers, meters and loggers of one package
+// or component, with the module version and the semantic conventions schema
+// URL the application's attributes follow.
+type InstrumentationScope struct {
+	Name      string
+	Version   string
+	SchemaURL string
+}
+
+// Scope returns the instrumentation scope of pkg, named "my-go-app/<pkg>".
+func Scope(pkg string) InstrumentationScope {
+	return InstrumentationScope{
+		Name:      ScopePrefix + "/" + pkg,
+		Version:   Version,
+		SchemaURL: semconv.SchemaURL,
+	}
+}
+
+// Tracer returns the scope's tracer from the global TracerProvider.
+func (s InstrumentationScope) Tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(s.Name,
+		trace.WithInstrumentationVersion(s.Version),
+		trace.WithSchemaURL(s.SchemaURL),
+	)
+}
+
+// Meter returns the scope's meter from the global MeterProvider. Instrument
+// names are checked for conflicting registrations across the process (see
+// metrics.Checked).
+func (s InstrumentationScope) Meter() metric.Meter {
+	return metrics.Checked(otel.GetMeterProvider().Meter(s.Name,
+		metric.WithInstrumentationVersion(s.Version),
+		metric.WithSchemaURL(s.SchemaURL),
+	))
+}
+
+// Logger returns the scope's logger from the global LoggerProvider.
+func (s InstrumentationScope) Logger() otellog.Logger {
+	return global.GetLoggerProvider().Logger(s.Name,
+		otellog.WithInstrumentationVersion(s.Version),
+		otellog.WithSchemaURL(s.SchemaURL),
+	)
+}
+
+// SlogHandler returns an otelslog handler emitting to provider under the
+// scope.
+func (s InstrumentationScope) SlogHandler(provider otellog.LoggerProvider) slog.Handler {
+	return otelslog.NewHandler(s.Name,
+		otelslog.WithVersion(s.Version),
+		otelslog.WithSchemaURL(s.SchemaURL),
+		otelslog.WithLoggerProvider(provider),
+	)
+}
@@ -0,0 +1,242 @@
+// Package worker runs jobs in the background on a fixed number of
+// goroutines, with a bounded queue in front of them. Each job runs under
+// its own span, in a new trace linked to the span that submitted it, since
+// the job outlives the request that queued it, and carries the submitter's
+// baggage.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// Submit errors, also recorded as the reason of app.worker.jobs.rejected.
+var (
+	ErrQueueFull = errors.New("worker queue is full")
+	ErrClosed    = errors.New("worker pool is shut down")
+)
+
+// Job is the work of one job. The context carries the job span and is
+// canceled if the pool's drain times out.
+type Job func(ctx context.Context) error
+
+// Config sizes a pool.
+type Config struct {
+	// Name identifies the pool, as worker.pool, in its spans and metrics.
+	Name string
+	// Concurrency is the number of jobs run at once.
+	Concurrency int
+	// QueueSize is the number of jobs that can wait for a worker; Submit
+	// rejects jobs beyond it.
+	QueueSize int
+}
+
+type task struct {
+	name     string
+	job      Job
+	link     trace.Link
+	baggage  baggage.Baggage
+	enqueued time.Time
+}
+
+// Pool runs submitted jobs on Config.Concurrency goroutines.
+type Pool struct {
+	name   string
+	tracer trace.Tracer
+	tasks  chan task
+	active atomic.Int64
+
+	// mu guards closed against Submit sending on tasks after Shutdown
+	// closed it.
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context // canceled when a drain times out
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	duration metric.Float64Histogram
+	wait     metric.Float64Histogram
+	rejected metric.Int64Counter
+}
+
+// New starts a pool. Besides app.worker.job.duration and
+// app.worker.job.wait, it exports app.worker.queue.depth, the jobs waiting
+// for a worker, app.worker.jobs.active, the jobs running, and counts the
+// jobs Submit turned away in app.worker.jobs.rejected.
+func New(cfg Config, tracer trace.Tracer, meter metric.Meter) (*Pool, error) {
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("worker pool %s: concurrency must be at least 1, got %d", cfg.Name, cfg.Concurrency)
+	}
+	if cfg.QueueSize < 0 {
+		return nil, fmt.Errorf("worker pool %s: queue size must not be negative, got %d", cfg.Name, cfg.QueueSize)
+	}
+	p := &Pool{
+		name:   cfg.Name,
+		tracer: tracer,
+		tasks:  make(chan task, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+	poolAttrs := metric.WithAttributes(attribute.String("worker.pool", p.name))
+	var err error
+	p.duration, err = metrics.WorkerJobDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	p.wait, err = metrics.WorkerJobWait.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	p.rejected, err = metrics.WorkerJobsRejected.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = metrics.WorkerQueueDepth.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(len(p.tasks)), poolAttrs)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = metrics.WorkerJobsActive.Int64ObservableGauge(meter,
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(p.active.Load(), poolAttrs)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range p.tasks {
+				// After an interrupted drain, the rest of the queue is
+				// dropped.
+				if p.ctx.Err() == nil {
+					p.run(t)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Submit queues job without waiting for it. It returns ErrQueueFull if no
+// worker is free and the queue is full, and ErrClosed once Shutdown has
+// been called. name names the job's span and is recorded as job.name.
+func (p *Pool) Submit(ctx context.Context, name string, job Job) error {
+	t := task{
+		name:     name,
+		job:      job,
+		link:     trace.LinkFromContext(ctx, attribute.String("link.type", "worker.submitter")),
+		baggage:  baggage.FromContext(ctx),
+		enqueued: time.Now(),
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var reason string
+	switch {
+	case p.closed:
+		reason = "shut_down"
+	default:
+		select {
+		case p.tasks <- t:
+			return nil
+		default:
+			reason = "queue_full"
+		}
+	}
+	p.rejected.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("worker.pool", p.name),
+		attribute.String("job.name", name),
+		attribute.String("reason", reason),
+	))
+	if reason == "shut_down" {
+		return ErrClosed
+	}
+	return ErrQueueFull
+}
+
+// run runs one job under a span, recording a panic as the job's error so
+// the worker survives it.
+func (p *Pool) run(t task) {
+	attrs := []attribute.KeyValue{
+		attribute.String("worker.pool", p.name),
+		attribute.String("job.name", t.name),
+	}
+	waited := time.Since(t.enqueued)
+	ctx := baggage.ContextWithBaggage(p.ctx, t.baggage)
+	ctx, span := p.tracer.Start(ctx, t.name,
+		trace.WithNewRoot(),
+		trace.WithLinks(t.link),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.Float64("job.wait", waited.Seconds())),
+	)
+	defer span.End()
+	p.wait.Record(ctx, waited.Seconds(), metric.WithAttributes(attrs...))
+
+	p.active.Add(1)
+	defer p.active.Add(-1)
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return t.job(ctx)
+	}()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	}
+	p.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones
+// to finish. If ctx is done first, the running jobs' contexts are canceled,
+// the jobs still queued are dropped unrun, and Shutdown returns an error
+// saying how many.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+	dropped := len(p.tasks)
+	p.cancel()
+	return fmt.Errorf("worker pool %s: drain interrupted with %d jobs queued: %w", p.name, dropped, ctx.Err())
+}
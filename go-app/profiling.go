@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pprofLabelsMiddleware runs next under pprof labels carrying the trace id
+// and route, so CPU profiles from /debug/pprof can be filtered down to a
+// single trace or route (e.g. `go tool pprof -tagfocus route=/work`). It must
+// run inside otelhttp so the request span is already started.
+func pprofLabelsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labels := []string{"route", r.Pattern}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			labels = append(labels, "trace_id", sc.TraceID().String())
+		}
+		runtimepprof.Do(r.Context(), runtimepprof.Labels(labels...), func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
+
+// registerPprof exposes the net/http/pprof handlers on mux.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
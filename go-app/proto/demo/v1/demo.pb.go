@@ -0,0 +1,275 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v5.28.3
+// source: proto/demo/v1/demo.proto
+
+// The gRPC counterpart of the demo service's /hello and /work endpoints.
+
+package demov1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type HelloRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to "OpenTelemetry".
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HelloRequest) Reset() {
+	*x = HelloRequest{}
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HelloRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HelloRequest) ProtoMessage() {}
+
+func (x *HelloRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HelloRequest.ProtoReflect.Descriptor instead.
+func (*HelloRequest) Descriptor() ([]byte, []int) {
+	return file_proto_demo_v1_demo_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *HelloRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type HelloResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HelloResponse) Reset() {
+	*x = HelloResponse{}
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HelloResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HelloResponse) ProtoMessage() {}
+
+func (x *HelloResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HelloResponse.ProtoReflect.Descriptor instead.
+func (*HelloResponse) Descriptor() ([]byte, []int) {
+	return file_proto_demo_v1_demo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HelloResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type WorkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkRequest) Reset() {
+	*x = WorkRequest{}
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkRequest) ProtoMessage() {}
+
+func (x *WorkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkRequest.ProtoReflect.Descriptor instead.
+func (*WorkRequest) Descriptor() ([]byte, []int) {
+	return file_proto_demo_v1_demo_proto_rawDescGZIP(), []int{2}
+}
+
+type WorkResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// HTTP status code of the downstream call.
+	DownstreamStatusCode int32 `protobuf:"varint,1,opt,name=downstream_status_code,json=downstreamStatusCode,proto3" json:"downstream_status_code,omitempty"`
+	// Total time spent, in seconds.
+	DurationSeconds float64 `protobuf:"fixed64,2,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *WorkResponse) Reset() {
+	*x = WorkResponse{}
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkResponse) ProtoMessage() {}
+
+func (x *WorkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_demo_v1_demo_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkResponse.ProtoReflect.Descriptor instead.
+func (*WorkResponse) Descriptor() ([]byte, []int) {
+	return file_proto_demo_v1_demo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WorkResponse) GetDownstreamStatusCode() int32 {
+	if x != nil {
+		return x.DownstreamStatusCode
+	}
+	return 0
+}
+
+func (x *WorkResponse) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+var File_proto_demo_v1_demo_proto protoreflect.FileDescriptor
+
+const file_proto_demo_v1_demo_proto_rawDesc = "" +
+	"\n" +
+	"\x18proto/demo/v1/demo.proto\x12\ademo.v1\"\"\n" +
+	"\fHelloRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\")\n" +
+	"\rHelloResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\r\n" +
+	"\vWorkRequest\"o\n" +
+	"\fWorkResponse\x124\n" +
+	"\x16downstream_status_code\x18\x01 \x01(\x05R\x14downstreamStatusCode\x12)\n" +
+	"\x10duration_seconds\x18\x02 \x01(\x01R\x0fdurationSeconds2s\n" +
+	"\x04Demo\x126\n" +
+	"\x05Hello\x12\x15.demo.v1.HelloRequest\x1a\x16.demo.v1.HelloResponse\x123\n" +
+	"\x04Work\x12\x14.demo.v1.WorkRequest\x1a\x15.demo.v1.WorkResponseB Z\x1emy-go-app/proto/demo/v1;demov1b\x06proto3"
+
+var (
+	file_proto_demo_v1_demo_proto_rawDescOnce sync.Once
+	file_proto_demo_v1_demo_proto_rawDescData []byte
+)
+
+func file_proto_demo_v1_demo_proto_rawDescGZIP() []byte {
+	file_proto_demo_v1_demo_proto_rawDescOnce.Do(func() {
+		file_proto_demo_v1_demo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_demo_v1_demo_proto_rawDesc), len(file_proto_demo_v1_demo_proto_rawDesc)))
+	})
+	return file_proto_demo_v1_demo_proto_rawDescData
+}
+
+var file_proto_demo_v1_demo_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_demo_v1_demo_proto_goTypes = []any{
+	(*HelloRequest)(nil),  // 0: demo.v1.HelloRequest
+	(*HelloResponse)(nil), // 1: demo.v1.HelloResponse
+	(*WorkRequest)(nil),   // 2: demo.v1.WorkRequest
+	(*WorkResponse)(nil),  // 3: demo.v1.WorkResponse
+}
+var file_proto_demo_v1_demo_proto_depIdxs = []int32{
+	0, // 0: demo.v1.Demo.Hello:input_type -> demo.v1.HelloRequest
+	2, // 1: demo.v1.Demo.Work:input_type -> demo.v1.WorkRequest
+	1, // 2: demo.v1.Demo.Hello:output_type -> demo.v1.HelloResponse
+	3, // 3: demo.v1.Demo.Work:output_type -> demo.v1.WorkResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_proto_demo_v1_demo_proto_init() }
+func file_proto_demo_v1_demo_proto_init() {
+	if File_proto_demo_v1_demo_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_demo_v1_demo_proto_rawDesc), len(file_proto_demo_v1_demo_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_demo_v1_demo_proto_goTypes,
+		DependencyIndexes: file_proto_demo_v1_demo_proto_depIdxs,
+		MessageInfos:      file_proto_demo_v1_demo_proto_msgTypes,
+	}.Build()
+	File_proto_demo_v1_demo_proto = out.File
+	file_proto_demo_v1_demo_proto_goTypes = nil
+	file_proto_demo_v1_demo_proto_depIdxs = nil
+}
@@ -0,0 +1,167 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: proto/demo/v1/demo.proto
+
+// The gRPC counterpart of the demo service's /hello and /work endpoints.
+
+package demov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Demo_Hello_FullMethodName = "/demo.v1.Demo/Hello"
+	Demo_Work_FullMethodName  = "/demo.v1.Demo/Work"
+)
+
+// DemoClient is the client API for Demo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DemoClient interface {
+	// Hello answers with a greeting after a short simulated delay.
+	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// Work simulates processing that includes a call to the downstream
+	// HTTP service, like GET /work.
+	Work(ctx context.Context, in *WorkRequest, opts ...grpc.CallOption) (*WorkResponse, error)
+}
+
+type demoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDemoClient(cc grpc.ClientConnInterface) DemoClient {
+	return &demoClient{cc}
+}
+
+func (c *demoClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HelloResponse)
+	err := c.cc.Invoke(ctx, Demo_Hello_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoClient) Work(ctx context.Context, in *WorkRequest, opts ...grpc.CallOption) (*WorkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WorkResponse)
+	err := c.cc.Invoke(ctx, Demo_Work_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DemoServer is the server API for Demo service.
+// All implementations must embed UnimplementedDemoServer
+// for forward compatibility.
+type DemoServer interface {
+	// Hello answers with a greeting after a short simulated delay.
+	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
+	// Work simulates processing that includes a call to the downstream
+	// HTTP service, like GET /work.
+	Work(context.Context, *WorkRequest) (*WorkResponse, error)
+	mustEmbedUnimplementedDemoServer()
+}
+
+// UnimplementedDemoServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDemoServer struct{}
+
+func (UnimplementedDemoServer) Hello(context.Context, *HelloRequest) (*HelloResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Hello not implemented")
+}
+func (UnimplementedDemoServer) Work(context.Context, *WorkRequest) (*WorkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Work not implemented")
+}
+func (UnimplementedDemoServer) mustEmbedUnimplementedDemoServer() {}
+func (UnimplementedDemoServer) testEmbeddedByValue()              {}
+
+// UnsafeDemoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DemoServer will
+// result in compilation errors.
+type UnsafeDemoServer interface {
+	mustEmbedUnimplementedDemoServer()
+}
+
+func RegisterDemoServer(s grpc.ServiceRegistrar, srv DemoServer) {
+	// If the following call pancis, it indicates UnimplementedDemoServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Demo_ServiceDesc, srv)
+}
+
+func _Demo_Hello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServer).Hello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Demo_Hello_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServer).Hello(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Demo_Work_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServer).Work(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Demo_Work_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServer).Work(ctx, req.(*WorkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Demo_ServiceDesc is the grpc.ServiceDesc for Demo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Demo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "demo.v1.Demo",
+	HandlerType: (*DemoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hello",
+			Handler:    _Demo_Hello_Handler,
+		},
+		{
+			MethodName: "Work",
+			Handler:    _Demo_Work_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/demo/v1/demo.proto",
+}
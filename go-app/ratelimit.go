@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+	"my-go-app/pkg/middleware"
+)
+
+// rateLimiter answers requests over the global limit (RATE_LIMIT) or their
+// route's limit (a route policy's rate_limit) with 429. Rejections are
+// counted in http.server.throttled_requests and recorded on the request
+// span as rate_limit.scope ("route" or "global") and rate_limit.rate.
+type rateLimiter struct {
+	global    *tokenBucket // nil without a global limit
+	throttled metric.Int64Counter
+}
+
+// newRateLimiter returns a limiter allowing rate requests per second across
+// all routes, in bursts of up to burst; a rate of 0 sets no global limit.
+func newRateLimiter(meter metric.Meter, rate float64, burst int) (*rateLimiter, error) {
+	throttled, err := metrics.HTTPServerThrottledRequests.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	l := &rateLimiter{throttled: throttled}
+	if rate > 0 {
+		l.global = newTokenBucket(rate, burst)
+	}
+	return l, nil
+}
+
+// Middleware returns the middleware enforcing the global limit and the one
+// of policy, or nil when neither is set. Every call returns a route limit
+// of its own. The global limit is checked first, and its token is given
+// back when the route limit rejects the request, so requests rejected by
+// one limit do not count against the other.
+func (l *rateLimiter) Middleware(policy *effectiveRoutePolicy) middleware.Middleware {
+	var route *tokenBucket
+	if policy.RateLimit > 0 {
+		route = newTokenBucket(policy.RateLimit, policy.Burst)
+	}
+	if route == nil && l.global == nil {
+		return nil
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.global != nil {
+				if wait, ok := l.global.take(); !ok {
+					l.reject(w, r, "global", l.global.rate, wait)
+					return
+				}
+			}
+			if route != nil {
+				if wait, ok := route.take(); !ok {
+					if l.global != nil {
+						l.global.giveBack()
+					}
+					l.reject(w, r, "route", route.rate, wait)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (l *rateLimiter) reject(w http.ResponseWriter, r *http.Request, scope string, rate float64, wait time.Duration) {
+	ctx := r.Context()
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("rate_limit.scope", scope),
+		attribute.Float64("rate_limit.rate", rate),
+	)
+	l.throttled.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("http.route", r.Pattern),
+		attribute.String("rate_limit.scope", scope),
+	))
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+	http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+}
+
+// tokenBucket allows rate requests per second on average, in bursts of up
+// to burst.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket. A burst of 0 allows one second's
+// worth of requests, and at least one.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take takes a token, or reports how long until the next one.
+func (b *tokenBucket) take() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
+
+// giveBack returns a token taken for a request that was rejected anyway.
+func (b *tokenBucket) giveBack() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		global     float64
+		route      float64
+		wantCodes  []int
+		wantTokens float64 // left in the global bucket
+	}{
+		{
+			name:       "global limit",
+			global:     2,
+			wantCodes:  []int{200, 200, 429},
+			wantTokens: 0,
+		},
+		{
+			name:      "route limit",
+			route:     1,
+			wantCodes: []int{200, 429, 429},
+		},
+		{
+			// Route rejections give their global token back.
+			name:       "route limit under a global one",
+			global:     3,
+			route:      1,
+			wantCodes:  []int{200, 429, 429},
+			wantTokens: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := newRateLimiter(noop.NewMeterProvider().Meter("test"), tt.global, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mw := l.Middleware(&effectiveRoutePolicy{RateLimit: tt.route})
+			h := mw(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			for i, want := range tt.wantCodes {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+				if rec.Code != want {
+					t.Errorf("request %d: status %d, want %d", i, rec.Code, want)
+				}
+			}
+			// Refill over the test's runtime is far below one token.
+			if l.global != nil && int(l.global.tokens) != int(tt.wantTokens) {
+				t.Errorf("global bucket has %.2f tokens, want %v", l.global.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
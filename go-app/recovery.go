@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+)
+
+// recoveryMiddleware turns a handler panic into a 500 response instead of a
+// dropped connection. The panic and its stack trace are recorded as an
+// exception on the request span, logged at error level and counted in
+// http.server.panics. It must run inside otelhttp so the request span is
+// already started, and inside the HTTP metrics middleware so the 500 is
+// measured.
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wroteHeader := false
+		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					wroteHeader = true
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					wroteHeader = true
+					return next(b)
+				}
+			},
+		})
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// ErrAbortHandler is net/http's way to abort a response on
+			// purpose; let the server handle it as usual.
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			ctx := r.Context()
+			stack := string(debug.Stack())
+			msg := fmt.Sprint(rec)
+
+			span := trace.SpanFromContext(ctx)
+			span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+				semconv.ExceptionType(fmt.Sprintf("%T", rec)),
+				semconv.ExceptionMessage(msg),
+				semconv.ExceptionStacktrace(stack),
+			))
+			span.SetStatus(codes.Error, "panic: "+msg)
+
+			httpPanicsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", r.Pattern)))
+			appLog.Error(ctx, "Recovered from handler panic",
+				logging.String("panic", msg),
+				logging.String("exception.stacktrace", stack),
+				logging.String("http.route", r.Pattern),
+			)
+
+			if !wroteHeader {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
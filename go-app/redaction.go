@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const redactedValue = "[REDACTED]"
+
+// defaultRedactKeys are attribute key fragments whose values are always
+// redacted.
+var defaultRedactKeys = []string{"authorization", "cookie", "password", "passwd", "secret", "token", "api_key", "api-key"}
+
+// defaultRedactPatterns match sensitive substrings of any string value:
+// email addresses. Card numbers are matched by cardNumberPattern.
+var defaultRedactPatterns = []string{
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+}
+
+// cardNumberPattern matches candidate payment card numbers: 13 to 19
+// digits, optionally grouped by spaces or dashes. Only candidates that pass
+// the Luhn check are redacted, so order ids, timestamps and other long
+// numbers are kept.
+var cardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// redactor scrubs sensitive data from telemetry before it leaves the pod:
+// values of attributes whose key contains one of keys are replaced
+// entirely, and substrings matching one of patterns, or card numbers, are
+// replaced in every other string value.
+type redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// newRedactor extends the default rules with extraKeys (comma-separated key
+// fragments) and extraPatterns (semicolon-separated regular expressions).
+func newRedactor(extraKeys, extraPatterns string) (*redactor, error) {
+	r := &redactor{keys: append([]string(nil), defaultRedactKeys...)}
+	for _, k := range strings.Split(extraKeys, ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keys = append(r.keys, k)
+		}
+	}
+	patterns := defaultRedactPatterns
+	for _, p := range strings.Split(extraPatterns, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *redactor) sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString returns s with every pattern match and card number
+// replaced, and whether anything was replaced.
+func (r *redactor) redactString(s string) (string, bool) {
+	changed := false
+	for _, re := range r.patterns {
+		if re.MatchString(s) {
+			s = re.ReplaceAllString(s, redactedValue)
+			changed = true
+		}
+	}
+	if cardNumberPattern.MatchString(s) {
+		s = cardNumberPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if !luhnValid(m) {
+				return m
+			}
+			changed = true
+			return redactedValue
+		})
+	}
+	return s, changed
+}
+
+// luhnValid reports whether the digits of s pass the Luhn check that every
+// payment card number satisfies. Other characters are skipped.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redactAttributes returns attrs with sensitive values replaced, or nil if
+// nothing had to change.
+func (r *redactor) redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		redacted, changed := r.redactAttribute(kv)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = append([]attribute.KeyValue(nil), attrs...)
+		}
+		out[i] = redacted
+	}
+	return out
+}
+
+func (r *redactor) redactAttribute(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		if r.sensitiveKey(string(kv.Key)) {
+			return kv.Key.String(redactedValue), true
+		}
+		if s, changed := r.redactString(kv.Value.AsString()); changed {
+			return kv.Key.String(s), true
+		}
+	case attribute.STRINGSLICE:
+		if r.sensitiveKey(string(kv.Key)) {
+			return kv.Key.StringSlice([]string{redactedValue}), true
+		}
+		values := kv.Value.AsStringSlice()
+		changed := false
+		for i, v := range values {
+			if s, ok := r.redactString(v); ok {
+				values[i], changed = s, true
+			}
+		}
+		if changed {
+			return kv.Key.StringSlice(values), true
+		}
+	}
+	return kv, false
+}
+
+func (r *redactor) redactLogValue(key string, v otellog.Value) (otellog.Value, bool) {
+	switch v.Kind() {
+	case otellog.KindString:
+		if key != "" && r.sensitiveKey(key) {
+			return otellog.StringValue(redactedValue), true
+		}
+		if s, changed := r.redactString(v.AsString()); changed {
+			return otellog.StringValue(s), true
+		}
+	case otellog.KindMap:
+		// Cloned: the value shares its backing array with the caller's.
+		kvs := slices.Clone(v.AsMap())
+		changed := false
+		for i, kv := range kvs {
+			if nv, ok := r.redactLogValue(kv.Key, kv.Value); ok {
+				kvs[i].Value, changed = nv, true
+			}
+		}
+		if changed {
+			return otellog.MapValue(kvs...), true
+		}
+	case otellog.KindSlice:
+		vs := slices.Clone(v.AsSlice())
+		changed := false
+		for i, e := range vs {
+			if nv, ok := r.redactLogValue(key, e); ok {
+				vs[i], changed = nv, true
+			}
+		}
+		if changed {
+			return otellog.SliceValue(vs...), true
+		}
+	}
+	return v, false
+}
+
+// redactEvents returns events with sensitive data in their names and
+// attribute values replaced, or nil if nothing had to change.
+func (r *redactor) redactEvents(events []sdktrace.Event) []sdktrace.Event {
+	var out []sdktrace.Event
+	for i, e := range events {
+		name, nameChanged := r.redactString(e.Name)
+		attrs := r.redactAttributes(e.Attributes)
+		if !nameChanged && attrs == nil {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(events)
+		}
+		out[i].Name = name
+		if attrs != nil {
+			out[i].Attributes = attrs
+		}
+	}
+	return out
+}
+
+// redactLinks returns links with sensitive attribute values replaced, or nil
+// if nothing had to change.
+func (r *redactor) redactLinks(links []sdktrace.Link) []sdktrace.Link {
+	var out []sdktrace.Link
+	for i, l := range links {
+		attrs := r.redactAttributes(l.Attributes)
+		if attrs == nil {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(links)
+		}
+		out[i].Attributes = attrs
+	}
+	return out
+}
+
+// redactSpan returns s with sensitive data replaced in its name, status
+// description, attributes, events and links, or nil if nothing had to
+// change.
+func (r *redactor) redactSpan(s sdktrace.ReadOnlySpan) *rewrittenSpan {
+	rs := rewrittenSpan{
+		ReadOnlySpan: s,
+		attrs:        r.redactAttributes(s.Attributes()),
+		events:       r.redactEvents(s.Events()),
+		links:        r.redactLinks(s.Links()),
+	}
+	changed := rs.attrs != nil || rs.events != nil || rs.links != nil
+	if name, ok := r.redactString(s.Name()); ok {
+		rs.name, changed = name, true
+	}
+	if status := s.Status(); status.Description != "" {
+		if desc, ok := r.redactString(status.Description); ok {
+			status.Description = desc
+			rs.status, changed = &status, true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return &rs
+}
+
+// redactionRules holds the redactor in effect, so the rules can be replaced
+// at runtime. A nil redactor disables redaction.
+type redactionRules struct {
+	current atomic.Pointer[redactor]
+}
+
+func (r *redactionRules) Load() *redactor      { return r.current.Load() }
+func (r *redactionRules) Store(next *redactor) { r.current.Store(next) }
+
+// redactionSpanProcessor passes spans to next with sensitive data redacted
+// (see redactor.redactSpan).
+type redactionSpanProcessor struct {
+	next  sdktrace.SpanProcessor
+	rules *redactionRules
+}
+
+func (p redactionSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p redactionSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if redactor := p.rules.Load(); redactor != nil {
+		if rs := redactor.redactSpan(s); rs != nil {
+			p.next.OnEnd(*rs)
+			return
+		}
+	}
+	p.next.OnEnd(s)
+}
+
+func (p redactionSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p redactionSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// liveRedactionSpanProcessor is a redactionSpanProcessor for a processor
+// that shows spans while they are still running, like the zpages one behind
+// /debug/tracez: started spans are passed on wrapped, so that they are
+// redacted whenever they are read.
+type liveRedactionSpanProcessor struct {
+	redactionSpanProcessor
+}
+
+func (p liveRedactionSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, liveRedactedSpan{s, p.rules})
+}
+
+// liveRedactedSpan redacts a running span on every read, under the rules in
+// effect at the time.
+type liveRedactedSpan struct {
+	sdktrace.ReadWriteSpan
+	rules *redactionRules
+}
+
+func (s liveRedactedSpan) redacted() sdktrace.ReadOnlySpan {
+	if redactor := s.rules.Load(); redactor != nil {
+		if rs := redactor.redactSpan(s.ReadWriteSpan); rs != nil {
+			return *rs
+		}
+	}
+	return s.ReadWriteSpan
+}
+
+func (s liveRedactedSpan) Name() string                     { return s.redacted().Name() }
+func (s liveRedactedSpan) Status() sdktrace.Status          { return s.redacted().Status() }
+func (s liveRedactedSpan) Attributes() []attribute.KeyValue { return s.redacted().Attributes() }
+func (s liveRedactedSpan) Events() []sdktrace.Event         { return s.redacted().Events() }
+func (s liveRedactedSpan) Links() []sdktrace.Link           { return s.redacted().Links() }
+
+// rewrittenSpan overrides parts of a span rewritten by a processor before
+// export; zero fields fall through to the original span.
+type rewrittenSpan struct {
+	sdktrace.ReadOnlySpan
+	name   string
+	status *sdktrace.Status
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+	links  []sdktrace.Link
+}
+
+func (s rewrittenSpan) Name() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.ReadOnlySpan.Name()
+}
+
+func (s rewrittenSpan) Status() sdktrace.Status {
+	if s.status != nil {
+		return *s.status
+	}
+	return s.ReadOnlySpan.Status()
+}
+
+func (s rewrittenSpan) Attributes() []attribute.KeyValue {
+	if s.attrs != nil {
+		return s.attrs
+	}
+	return s.ReadOnlySpan.Attributes()
+}
+
+func (s rewrittenSpan) Events() []sdktrace.Event {
+	if s.events != nil {
+		return s.events
+	}
+	return s.ReadOnlySpan.Events()
+}
+
+func (s rewrittenSpan) Links() []sdktrace.Link {
+	if s.links != nil {
+		return s.links
+	}
+	return s.ReadOnlySpan.Links()
+}
+
+// redactionLogProcessor redacts the body and attributes of records in place.
+// It must be registered before the exporting processors.
+type redactionLogProcessor struct {
+	rules *redactionRules
+}
+
+func (p redactionLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	redactor := p.rules.Load()
+	if redactor == nil {
+		return nil
+	}
+	if body, changed := redactor.redactLogValue("", r.Body()); changed {
+		r.SetBody(body)
+	}
+
+	var attrs []otellog.KeyValue
+	changed := false
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		if v, ok := redactor.redactLogValue(kv.Key, kv.Value); ok {
+			kv.Value, changed = v, true
+		}
+		attrs = append(attrs, kv)
+		return true
+	})
+	if changed {
+		r.SetAttributes(attrs...)
+	}
+	return nil
+}
+
+func (redactionLogProcessor) Shutdown(context.Context) error   { return nil }
+func (redactionLogProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordedSpans records the spans it sees start, as the zpages processor
+// does for /debug/tracez, and end.
+type recordedSpans struct {
+	started []sdktrace.ReadWriteSpan
+	ended   []sdktrace.ReadOnlySpan
+}
+
+func (s *recordedSpans) OnStart(_ context.Context, span sdktrace.ReadWriteSpan) {
+	s.started = append(s.started, span)
+}
+func (s *recordedSpans) OnEnd(span sdktrace.ReadOnlySpan) { s.ended = append(s.ended, span) }
+func (*recordedSpans) Shutdown(context.Context) error     { return nil }
+func (*recordedSpans) ForceFlush(context.Context) error   { return nil }
+
+func testRedactionRules(t *testing.T, extraKeys, extraPatterns string) *redactionRules {
+	t.Helper()
+	redact, err := newRedactor(extraKeys, extraPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := &redactionRules{}
+	rules.Store(redact)
+	return rules
+}
+
+func TestRedactString(t *testing.T) {
+	rules := testRedactionRules(t, "", `\bSSN-\d{4}\b`)
+	tests := []struct {
+		name, in, want string
+	}{
+		{"email", "mail bob@example.com now", "mail [REDACTED] now"},
+		{"card", "card 4111 1111 1111 1111 ok", "card [REDACTED] ok"},
+		{"dashed card", "5500-0000-0000-0004", "[REDACTED]"},
+		{"fails Luhn", "order 4111111111111112", "order 4111111111111112"},
+		{"timestamp", "at 1700000000000123", "at 1700000000000123"},
+		{"too short", "4111 1111 1111", "4111 1111 1111"},
+		{"extra pattern", "id SSN-1234", "id [REDACTED]"},
+		{"nothing", "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := rules.Load().redactString(tt.in)
+			if got != tt.want || changed != (tt.in != tt.want) {
+				t.Errorf("redactString(%q) = %q, %v, want %q", tt.in, got, changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactAttribute(t *testing.T) {
+	rules := testRedactionRules(t, "ssn", "")
+	tests := []struct {
+		name string
+		in   attribute.KeyValue
+		want attribute.KeyValue
+	}{
+		{"sensitive key", attribute.String("http.request.header.authorization", "Bearer x"), attribute.String("http.request.header.authorization", redactedValue)},
+		{"extra key", attribute.String("user.ssn", "123"), attribute.String("user.ssn", redactedValue)},
+		{"pattern in value", attribute.String("note", "bob@example.com"), attribute.String("note", redactedValue)},
+		{"sensitive slice", attribute.StringSlice("cookies", []string{"a", "b"}), attribute.StringSlice("cookies", []string{redactedValue})},
+		{"slice values", attribute.StringSlice("to", []string{"bob@example.com", "ops"}), attribute.StringSlice("to", []string{redactedValue, "ops"})},
+		{"not a string", attribute.Int("token_count", 3), attribute.Int("token_count", 3)},
+		{"clean", attribute.String("http.route", "/work"), attribute.String("http.route", "/work")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := rules.Load().redactAttribute(tt.in); got != tt.want {
+				t.Errorf("redactAttribute(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactionSpanProcessor(t *testing.T) {
+	recorded := &recordedSpans{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(redactionSpanProcessor{recorded, testRedactionRules(t, "", "")}))
+	defer tp.Shutdown(context.Background())
+
+	linked := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	_, span := tp.Tracer("test").Start(context.Background(), "lookup bob@example.com",
+		trace.WithLinks(trace.Link{SpanContext: linked, Attributes: []attribute.KeyValue{attribute.String("session.token", "t")}}))
+	span.SetAttributes(attribute.String("http.route", "/work"))
+	span.AddEvent("charged 4111 1111 1111 1111", trace.WithAttributes(attribute.String("password", "hunter2")))
+	span.SetStatus(codes.Error, "no user bob@example.com")
+	span.End()
+
+	got := recorded.ended[0]
+	if got.Name() != "lookup [REDACTED]" {
+		t.Errorf("name = %q", got.Name())
+	}
+	if got.Status().Description != "no user [REDACTED]" || got.Status().Code != codes.Error {
+		t.Errorf("status = %+v", got.Status())
+	}
+	if e := got.Events()[0]; e.Name != "charged [REDACTED]" || e.Attributes[0].Value.AsString() != redactedValue {
+		t.Errorf("event = %q %v", e.Name, e.Attributes)
+	}
+	if l := got.Links()[0]; l.Attributes[0].Value.AsString() != redactedValue || !l.SpanContext.Equal(linked) {
+		t.Errorf("link = %v", l)
+	}
+	if v := got.Attributes()[0].Value.AsString(); v != "/work" {
+		t.Errorf("clean attribute = %q, want it kept", v)
+	}
+}
+
+// recordedLogs records the log records emitted to it.
+type recordedLogs struct {
+	records []sdklog.Record
+}
+
+func (l *recordedLogs) OnEmit(_ context.Context, r *sdklog.Record) error {
+	l.records = append(l.records, r.Clone())
+	return nil
+}
+func (*recordedLogs) Shutdown(context.Context) error   { return nil }
+func (*recordedLogs) ForceFlush(context.Context) error { return nil }
+
+func TestRedactionLogProcessorCopiesValues(t *testing.T) {
+	recorded := &recordedLogs{}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(redactionLogProcessor{testRedactionRules(t, "", "")}),
+		sdklog.WithProcessor(recorded),
+	)
+	defer lp.Shutdown(context.Background())
+
+	user := []otellog.KeyValue{otellog.String("email", "bob@example.com"), otellog.String("id", "42")}
+	var record otellog.Record
+	record.SetBody(otellog.StringValue("login by bob@example.com"))
+	record.AddAttributes(otellog.Map("user", user...), otellog.String("api_key", "k"))
+	lp.Logger("test").Emit(context.Background(), record)
+
+	r := recorded.records[0]
+	if got := r.Body().AsString(); got != "login by [REDACTED]" {
+		t.Errorf("body = %q", got)
+	}
+	got := map[string]otellog.Value{}
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		got[kv.Key] = kv.Value
+		return true
+	})
+	if v := got["user"].AsMap()[0].Value.AsString(); v != redactedValue {
+		t.Errorf("user.email = %q, want it redacted", v)
+	}
+	if v := got["api_key"].AsString(); v != redactedValue {
+		t.Errorf("api_key = %q, want it redacted", v)
+	}
+	// The caller's attribute values are left alone.
+	if v := user[0].Value.AsString(); v != "bob@example.com" {
+		t.Errorf("caller's map was rewritten: email = %q", v)
+	}
+}
+
+func TestLiveRedactionSpanProcessor(t *testing.T) {
+	live := &recordedSpans{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(liveRedactionSpanProcessor{redactionSpanProcessor{live, testRedactionRules(t, "", "")}}))
+	defer tp.Shutdown(context.Background())
+
+	// Still running, so only the live view can show it.
+	_, span := tp.Tracer("test").Start(context.Background(), "work for bob@example.com")
+	defer span.End()
+	span.SetAttributes(attribute.String("user.email", "bob@example.com"))
+	span.AddEvent("login", trace.WithAttributes(attribute.String("password", "hunter2")))
+
+	got := live.started[0]
+	if got.Name() != "work for [REDACTED]" {
+		t.Errorf("live name = %q", got.Name())
+	}
+	if v := got.Attributes()[0].Value.AsString(); v != redactedValue {
+		t.Errorf("live attribute = %q, want %q", v, redactedValue)
+	}
+	if v := got.Events()[0].Attributes[0].Value.AsString(); v != redactedValue {
+		t.Errorf("live event attribute = %q, want %q", v, redactedValue)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"my-go-app/pkg/logging"
+)
+
+// reloadDebounce coalesces the burst of events an editor or a ConfigMap
+// update produces into one reload.
+const reloadDebounce = 250 * time.Millisecond
+
+// reloadableConfig is the part of the configuration that takes effect
+// without a restart.
+type reloadableConfig struct {
+	sampleRatio      float64
+	logLevel         string
+	exportInterval   time.Duration
+	redactionEnabled bool
+	redactKeys       string
+	redactPatterns   string
+}
+
+// reloadableSettings are the settings behind reloadableConfig. Changing any
+// other setting of the config file needs a restart.
+var reloadableSettings = []string{
+	"TRACE_SAMPLE_RATIO", "LOG_LEVEL", "METRIC_EXPORT_INTERVAL",
+	"REDACTION_ENABLED", "REDACT_KEYS", "REDACT_PATTERNS",
+}
+
+// reloadableConfigFrom resolves the reloadable settings from the config file
+// settings, with environment variables taking precedence as at startup.
+func reloadableConfigFrom(settings map[string]string) (reloadableConfig, error) {
+	get := func(key, def string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		if v, ok := settings[key]; ok {
+			return v
+		}
+		return def
+	}
+	var (
+		cfg  reloadableConfig
+		err  error
+		errs []error
+	)
+	if cfg.sampleRatio, err = strconv.ParseFloat(get("TRACE_SAMPLE_RATIO", "1"), 64); err != nil {
+		errs = append(errs, fmt.Errorf("TRACE_SAMPLE_RATIO: %w", err))
+	}
+	cfg.logLevel = get("LOG_LEVEL", "info")
+	if _, err := parseSeverity(cfg.logLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if cfg.exportInterval, err = time.ParseDuration(get("METRIC_EXPORT_INTERVAL", envMillis("OTEL_METRIC_EXPORT_INTERVAL", time.Minute).String())); err != nil || cfg.exportInterval <= 0 {
+		errs = append(errs, errors.New("METRIC_EXPORT_INTERVAL must be a positive duration"))
+	}
+	if cfg.redactionEnabled, err = strconv.ParseBool(get("REDACTION_ENABLED", "true")); err != nil {
+		errs = append(errs, fmt.Errorf("REDACTION_ENABLED: %w", err))
+	}
+	cfg.redactKeys = get("REDACT_KEYS", "")
+	cfg.redactPatterns = get("REDACT_PATTERNS", "")
+	return cfg, errors.Join(errs...)
+}
+
+// configReloader re-reads CONFIG_FILE on SIGHUP, when the file changes, or
+// on POST /admin/reload, and swaps the sampler ratio, log level, metric
+// export interval and redaction rules in place. Other changes are reported
+// as needing a restart. An invalid file is rejected as a whole and the
+// running configuration is kept.
+//
+// Overrides set remotely (see SetRemote) take precedence over the local
+// configuration and survive reloads of the file.
+type configReloader struct {
+	path string
+
+	mu       sync.Mutex
+	settings map[string]string
+	views    []viewConfig
+	routes   []routePolicy
+	local    reloadableConfig
+	remote   remoteOverrides
+	applied  reloadableConfig
+}
+
+// remoteOverrides are the settings a remote management server may override.
+// Zero values leave the local setting in effect.
+type remoteOverrides struct {
+	sampleRatio *float64
+	logLevel    string
+}
+
+func (o remoteOverrides) apply(cfg reloadableConfig) reloadableConfig {
+	if o.sampleRatio != nil {
+		cfg.sampleRatio = *o.sampleRatio
+	}
+	if o.logLevel != "" {
+		cfg.logLevel = o.logLevel
+	}
+	return cfg
+}
+
+func newConfigReloader(path string) *configReloader {
+	startup := reloadableConfig{
+		sampleRatio:      traceSampleRatio,
+		logLevel:         logLevel,
+		exportInterval:   metricExportInterval,
+		redactionEnabled: redactionEnabled,
+		redactKeys:       envString("REDACT_KEYS", ""),
+		redactPatterns:   envString("REDACT_PATTERNS", ""),
+	}
+	return &configReloader{
+		path:     path,
+		settings: configSettings,
+		views:    configFile.Views,
+		routes:   configFile.Routes,
+		local:    startup,
+		applied:  startup,
+	}
+}
+
+// Reload applies the current contents of the config file.
+func (r *configReloader) Reload(ctx context.Context, trigger string) error {
+	err := r.reload(ctx, trigger)
+	if err != nil {
+		appLog.Error(ctx, "Configuration reload failed", logging.String("trigger", trigger), logging.Err(err))
+	}
+	return err
+}
+
+func (r *configReloader) reload(ctx context.Context, trigger string) error {
+	if r.path == "" {
+		return errors.New("CONFIG_FILE is not set")
+	}
+	cfg, err := loadConfigFile(r.path)
+	if err != nil {
+		return err
+	}
+	settings := cfg.settings()
+	next, err := reloadableConfigFrom(settings)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed, err := r.swap(r.remote.apply(next))
+	if err != nil {
+		return err
+	}
+	r.local = next
+
+	var restart []string
+	for _, key := range slices.Sorted(maps.Keys(unionKeys(r.settings, settings))) {
+		if r.settings[key] != settings[key] && !slices.Contains(reloadableSettings, key) {
+			restart = append(restart, key)
+		}
+	}
+	if !reflect.DeepEqual(r.views, cfg.Views) {
+		restart = append(restart, "views")
+	}
+	if !reflect.DeepEqual(r.routes, cfg.Routes) {
+		restart = append(restart, "routes")
+	}
+	r.settings, r.views, r.routes = settings, cfg.Views, cfg.Routes
+
+	attrs := []logging.Attr{
+		logging.String("trigger", trigger),
+		logging.String("config.changed", strings.Join(changed, ",")),
+	}
+	if len(changed) == 0 && len(restart) == 0 {
+		appLog.Debug(ctx, "Configuration unchanged", attrs[0])
+		return nil
+	}
+	if len(restart) > 0 {
+		appLog.Warn(ctx, "Configuration reloaded, some changes need a restart",
+			append(attrs, logging.String("config.restart_required", strings.Join(restart, ",")))...)
+		return nil
+	}
+	appLog.Info(ctx, "Configuration reloaded", attrs...)
+	return nil
+}
+
+// SetRemote replaces the remote overrides and applies them, returning the
+// settings that changed.
+func (r *configReloader) SetRemote(ctx context.Context, o remoteOverrides, source string) ([]string, error) {
+	if o.sampleRatio != nil && (*o.sampleRatio < 0 || *o.sampleRatio > 1) {
+		return nil, fmt.Errorf("sampling ratio must be between 0 and 1, got %g", *o.sampleRatio)
+	}
+	if o.logLevel != "" {
+		if _, err := parseSeverity(o.logLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed, err := r.swap(o.apply(r.local))
+	if err != nil {
+		return nil, err
+	}
+	r.remote = o
+	if len(changed) > 0 {
+		appLog.Info(ctx, "Remote configuration applied",
+			logging.String("trigger", source),
+			logging.String("config.changed", strings.Join(changed, ",")),
+		)
+	}
+	return changed, nil
+}
+
+// Applied returns the reloadable configuration in effect.
+func (r *configReloader) Applied() reloadableConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied
+}
+
+// swap puts next into effect and returns the names of the settings that
+// changed. r.mu must be held.
+func (r *configReloader) swap(next reloadableConfig) ([]string, error) {
+	prev := r.applied
+
+	// Everything that can fail is resolved before anything is swapped.
+	severity, err := parseSeverity(next.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	var redact *redactor
+	if next.redactionEnabled {
+		if redact, err = newRedactor(next.redactKeys, next.redactPatterns); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+	if next.sampleRatio != prev.sampleRatio {
+		if adaptiveTraceSampler != nil {
+			adaptiveTraceSampler.SetBaseRatio(next.sampleRatio)
+		} else if ratioSampler != nil {
+			ratioSampler.Swap(newRatioSampler(next.sampleRatio, consistentSamplingEnabled))
+		}
+		changed = append(changed, "sampling.ratio")
+	}
+	if next.logLevel != prev.logLevel {
+		if logSeverityFilter != nil {
+			logSeverityFilter.SetMin(severity)
+		}
+		changed = append(changed, "logs.level")
+	}
+	if next.exportInterval != prev.exportInterval {
+		if metricReader != nil {
+			metricReader.SetInterval(next.exportInterval)
+		}
+		changed = append(changed, "metrics.export_interval")
+	}
+	if next.redactionEnabled != prev.redactionEnabled || next.redactKeys != prev.redactKeys || next.redactPatterns != prev.redactPatterns {
+		activeRedaction.Store(redact)
+		changed = append(changed, "redaction")
+	}
+	r.applied = next
+	return changed, nil
+}
+
+func unionKeys(a, b map[string]string) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// Watch reloads the config file on SIGHUP (unless it is a shutdown signal)
+// and whenever the file changes, until ctx is done. The file's directory is
+// watched rather than the file, so editors that replace the file and
+// Kubernetes ConfigMap updates, which swap a symlink, are noticed too.
+func (r *configReloader) Watch(ctx context.Context) {
+	if r.path == "" {
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	if !strings.Contains(strings.ToUpper(shutdownSignals), "SIGHUP") {
+		signal.Notify(sigs, syscall.SIGHUP)
+	}
+	var (
+		events      <-chan fsnotify.Event
+		watchErrors <-chan error
+	)
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(filepath.Dir(r.path))
+	}
+	if err != nil {
+		appLog.Warn(ctx, "Config file changes are not watched", logging.Err(err))
+	} else {
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+
+	go func() {
+		defer signal.Stop(sigs)
+		if watcher != nil {
+			defer watcher.Close()
+		}
+		debounce := time.NewTimer(0)
+		<-debounce.C
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigs:
+				r.Reload(context.WithoutCancel(ctx), sig.String())
+			case ev := <-events:
+				// "..data" is the symlink a ConfigMap update swaps.
+				name := filepath.Base(ev.Name)
+				if ev.Has(fsnotify.Chmod) || (name != filepath.Base(r.path) && name != "..data") {
+					continue
+				}
+				debounce.Reset(reloadDebounce)
+			case err := <-watchErrors:
+				appLog.Warn(ctx, "Config file watch failed", logging.Err(err))
+			case <-debounce.C:
+				r.Reload(context.WithoutCancel(ctx), "file change")
+			}
+		}
+	}()
+}
+
+// ServeHTTP reloads the config file on POST, e.g.
+// `curl -X POST localhost:8081/admin/reload`.
+func (r *configReloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.Reload(req.Context(), "admin:"+req.RemoteAddr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"my-go-app/pkg/metrics"
+)
+
+// remoteWriteExporter sends metrics to a Prometheus remote-write receiver
+// (Prometheus, Mimir, Thanos, ...) instead of the collector, using the 1.0
+// protocol: a snappy-compressed protobuf WriteRequest per export.
+//
+// Names follow the Prometheus conventions of the catalog (PrometheusName),
+// with service.name as job and service.instance.id as instance. The other
+// resource attributes are exported once per collection on target_info.
+// Exponential histograms have no 1.0 representation, so startup refuses to
+// configure them (see checkRemoteWriteAggregation), and any that still
+// arrive are skipped. Failed requests are not retried, the next collection
+// resends the cumulative values.
+type remoteWriteExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// newRemoteWriteExporter returns an exporter posting to endpoint, adding
+// headers (such as X-Scope-OrgID for a Mimir tenant) to every request.
+// headers is a list such as "X-Scope-OrgID=payments,Authorization=Bearer token".
+func newRemoteWriteExporter(endpoint, headers string) (*remoteWriteExporter, error) {
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid PROMETHEUS_REMOTE_WRITE_URL %q, want an http or https URL", redactURL(endpoint))
+	}
+	h, err := parseHeaderList("PROMETHEUS_REMOTE_WRITE_HEADERS", headers)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteWriteExporter{
+		endpoint: endpoint,
+		headers:  h,
+		// Not the instrumented client: exports must not produce spans.
+		client: &http.Client{},
+	}, nil
+}
+
+// checkRemoteWriteAggregation rejects a HISTOGRAM_AGGREGATION or metric
+// view that records exponential histograms, which remote write would
+// silently drop.
+func checkRemoteWriteAggregation(defaultAggregation string, cfgs []viewConfig) error {
+	if defaultAggregation == "exponential" {
+		return errors.New("HISTOGRAM_AGGREGATION=exponential cannot be used with PROMETHEUS_REMOTE_WRITE_URL, which only sends explicit bucket histograms")
+	}
+	for _, cfg := range cfgs {
+		if cfg.Aggregation == "exponential" {
+			return fmt.Errorf("metric view for %q uses exponential aggregation, which PROMETHEUS_REMOTE_WRITE_URL cannot send", cfg.Instrument)
+		}
+	}
+	return nil
+}
+
+func (e *remoteWriteExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	// Prometheus only understands cumulative series.
+	return metricdata.CumulativeTemporality
+}
+
+func (e *remoteWriteExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *remoteWriteExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	w := newWriteRequest(rm.Resource, time.Now())
+	var skipped []string
+	for _, sm := range rm.ScopeMetrics {
+		scope := []attribute.KeyValue{attribute.String("otel_scope_name", sm.Scope.Name)}
+		if sm.Scope.Version != "" {
+			scope = append(scope, attribute.String("otel_scope_version", sm.Scope.Version))
+		}
+		for _, m := range sm.Metrics {
+			if !w.addMetric(m, scope) {
+				skipped = append(skipped, m.Name)
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		otel.Handle(fmt.Errorf("remote write: skipped metrics without a Prometheus representation: %s", strings.Join(skipped, ", ")))
+	}
+	if len(w.series) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(snappy.Encode(nil, w.marshal())))
+	if err != nil {
+		return fmt.Errorf("remote write: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote write: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+		return fmt.Errorf("remote write to %s failed: %s: %s", redactURL(e.endpoint), res.Status, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+func (e *remoteWriteExporter) ForceFlush(ctx context.Context) error { return ctx.Err() }
+
+func (e *remoteWriteExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// Metric types of the remote-write metadata.
+const (
+	promCounter   = 1
+	promGauge     = 2
+	promHistogram = 3
+	promInfo      = 6
+)
+
+type promLabel struct{ name, value string }
+
+type promSeries struct {
+	labels    []promLabel
+	value     float64
+	timestamp int64 // milliseconds
+}
+
+type promMetadata struct {
+	kind       int
+	family     string
+	help, unit string
+}
+
+// writeRequest is a remote-write WriteRequest being built from one
+// collection.
+type writeRequest struct {
+	target   []promLabel // job and instance, on every series
+	series   []promSeries
+	metadata []promMetadata
+}
+
+// newWriteRequest starts a request for a collection of res, adding the
+// target_info series at now.
+func newWriteRequest(res *resource.Resource, now time.Time) *writeRequest {
+	w := &writeRequest{}
+	var info []promLabel
+	var name, namespace string
+	for _, kv := range res.Attributes() {
+		switch kv.Key {
+		case semconv.ServiceNameKey:
+			name = kv.Value.Emit()
+		case semconv.ServiceNamespaceKey:
+			namespace = kv.Value.Emit()
+		case semconv.ServiceInstanceIDKey:
+			w.target = append(w.target, promLabel{"instance", kv.Value.Emit()})
+		default:
+			info = append(info, promLabel{promLabelName(string(kv.Key)), kv.Value.Emit()})
+		}
+	}
+	if namespace != "" {
+		name = namespace + "/" + name
+	}
+	if name != "" {
+		w.target = append(w.target, promLabel{"job", name})
+	}
+	if len(info) > 0 {
+		w.metadata = append(w.metadata, promMetadata{kind: promInfo, family: "target_info", help: "Target metadata"})
+		w.series = append(w.series, promSeries{labels: w.labels("target_info", info), value: 1, timestamp: now.UnixMilli()})
+	}
+	return w
+}
+
+// addMetric adds the series of m, reporting false for data it cannot
+// represent.
+func (w *writeRequest) addMetric(m metricdata.Metrics, scope []attribute.KeyValue) bool {
+	def := metrics.Definition{Name: m.Name, Unit: m.Unit}
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		def.Kind = metrics.KindGauge
+		addPoints(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	case metricdata.Gauge[float64]:
+		def.Kind = metrics.KindGauge
+		addPoints(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	case metricdata.Sum[int64]:
+		def.Kind = sumKind(data.IsMonotonic)
+		addPoints(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	case metricdata.Sum[float64]:
+		def.Kind = sumKind(data.IsMonotonic)
+		addPoints(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	case metricdata.Histogram[int64]:
+		def.Kind = metrics.KindHistogram
+		addHistogram(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	case metricdata.Histogram[float64]:
+		def.Kind = metrics.KindHistogram
+		addHistogram(w, metrics.PrometheusName(def), scope, data.DataPoints)
+	default:
+		return false
+	}
+	kind, family := promGauge, metrics.PrometheusName(def)
+	switch def.Kind {
+	case metrics.KindCounter:
+		// A counter family is named without its _total suffix.
+		kind, family = promCounter, strings.TrimSuffix(family, "_total")
+	case metrics.KindHistogram:
+		kind = promHistogram
+	}
+	w.metadata = append(w.metadata, promMetadata{
+		kind:   kind,
+		family: family,
+		help:   m.Description,
+		unit:   m.Unit,
+	})
+	return true
+}
+
+func sumKind(monotonic bool) metrics.Kind {
+	if monotonic {
+		return metrics.KindCounter
+	}
+	return metrics.KindUpDownCounter
+}
+
+func addPoints[N int64 | float64](w *writeRequest, name string, scope []attribute.KeyValue, points []metricdata.DataPoint[N]) {
+	for _, dp := range points {
+		w.series = append(w.series, promSeries{
+			labels:    w.labels(name, pointLabels(scope, dp.Attributes)),
+			value:     float64(dp.Value),
+			timestamp: dp.Time.UnixMilli(),
+		})
+	}
+}
+
+// addHistogram adds the cumulative _bucket series (including le="+Inf"),
+// _sum and _count of every point.
+func addHistogram[N int64 | float64](w *writeRequest, name string, scope []attribute.KeyValue, points []metricdata.HistogramDataPoint[N]) {
+	for _, dp := range points {
+		labels := pointLabels(scope, dp.Attributes)
+		ts := dp.Time.UnixMilli()
+		var cumulative uint64
+		for i, count := range dp.BucketCounts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(dp.Bounds) {
+				le = strconv.FormatFloat(dp.Bounds[i], 'g', -1, 64)
+			}
+			w.series = append(w.series, promSeries{
+				labels:    w.labels(name+"_bucket", append(slices.Clip(labels), promLabel{"le", le})),
+				value:     float64(cumulative),
+				timestamp: ts,
+			})
+		}
+		w.series = append(w.series,
+			promSeries{labels: w.labels(name+"_sum", labels), value: float64(dp.Sum), timestamp: ts},
+			promSeries{labels: w.labels(name+"_count", labels), value: float64(dp.Count), timestamp: ts},
+		)
+	}
+}
+
+// pointLabels converts the scope and point attributes to labels.
+func pointLabels(scope []attribute.KeyValue, attrs attribute.Set) []promLabel {
+	labels := make([]promLabel, 0, len(scope)+attrs.Len())
+	for _, kv := range scope {
+		labels = append(labels, promLabel{string(kv.Key), kv.Value.Emit()})
+	}
+	for it := attrs.Iter(); it.Next(); {
+		kv := it.Attribute()
+		labels = append(labels, promLabel{promLabelName(string(kv.Key)), kv.Value.Emit()})
+	}
+	return labels
+}
+
+// labels returns the label set of a series: __name__, job, instance and
+// labels, sorted by name as the protocol requires. Where a sanitized
+// attribute name collides with another, the first one wins.
+func (w *writeRequest) labels(name string, labels []promLabel) []promLabel {
+	out := make([]promLabel, 0, len(labels)+len(w.target)+1)
+	out = append(out, promLabel{"__name__", name})
+	out = append(out, w.target...)
+	out = append(out, labels...)
+	slices.SortStableFunc(out, func(a, b promLabel) int { return strings.Compare(a.name, b.name) })
+	return slices.CompactFunc(out, func(a, b promLabel) bool { return a.name == b.name })
+}
+
+// promLabelName replaces the characters Prometheus does not allow in label
+// names with underscores.
+func promLabelName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "key_" + name
+	}
+	return name
+}
+
+// marshal encodes the request as a prometheus.WriteRequest message:
+//
+//	WriteRequest   { repeated TimeSeries timeseries = 1; repeated MetricMetadata metadata = 3; }
+//	TimeSeries     { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label          { string name = 1; string value = 2; }
+//	Sample         { double value = 1; int64 timestamp = 2; }
+//	MetricMetadata { MetricType type = 1; string metric_family_name = 2; string help = 4; string unit = 5; }
+func (w *writeRequest) marshal() []byte {
+	var buf, ts, msg []byte
+	for _, s := range w.series {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			msg = protowire.AppendTag(msg[:0], 1, protowire.BytesType)
+			msg = protowire.AppendString(msg, l.name)
+			msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+			msg = protowire.AppendString(msg, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, msg)
+		}
+		msg = protowire.AppendTag(msg[:0], 1, protowire.Fixed64Type)
+		msg = protowire.AppendFixed64(msg, math.Float64bits(s.value))
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, msg)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	for _, md := range w.metadata {
+		msg = protowire.AppendTag(msg[:0], 1, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(md.kind))
+		msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+		msg = protowire.AppendString(msg, md.family)
+		msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+		msg = protowire.AppendString(msg, md.help)
+		msg = protowire.AppendTag(msg, 5, protowire.BytesType)
+		msg = protowire.AppendString(msg, md.unit)
+		buf = protowire.AppendTag(buf, 3, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, msg)
+	}
+	return buf
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRemoteWriteExport(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Scope-OrgID") != "payments" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if body, err = snappy.Decode(nil, compressed); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	e, err := newRemoteWriteExporter(srv.URL, "X-Scope-OrgID=payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.UnixMilli(1700000000000)
+	route := attribute.NewSet(attribute.String("http.route", "/work"))
+	rm := &metricdata.ResourceMetrics{
+		Resource: resource.NewSchemaless(
+			semconv.ServiceName("svc"),
+			semconv.ServiceInstanceID("i-1"),
+			semconv.HostName("node-1"),
+		),
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope: instrumentation.Scope{Name: "test", Version: "1.0"},
+			Metrics: []metricdata.Metrics{
+				{
+					Name:        "http.server.requests",
+					Description: "Requests served.",
+					Unit:        "{request}",
+					Data: metricdata.Sum[int64]{
+						Temporality: metricdata.CumulativeTemporality,
+						IsMonotonic: true,
+						DataPoints:  []metricdata.DataPoint[int64]{{Attributes: route, Time: now, Value: 3}},
+					},
+				},
+				{
+					Name:        "app.work.duration",
+					Description: "Work duration.",
+					Unit:        "s",
+					Data: metricdata.Histogram[float64]{
+						Temporality: metricdata.CumulativeTemporality,
+						DataPoints: []metricdata.HistogramDataPoint[float64]{{
+							Attributes:   route,
+							Time:         now,
+							Bounds:       []float64{0.1, 0.5},
+							BucketCounts: []uint64{1, 2, 1},
+							Count:        4,
+							Sum:          1.25,
+						}},
+					},
+				},
+			},
+		}},
+	}
+	if err := e.Export(context.Background(), rm); err != nil {
+		t.Fatal(err)
+	}
+
+	series, metadata := decodeWriteRequest(t, body)
+	const target = `instance="i-1",job="svc"`
+	const scope = `otel_scope_name="test",otel_scope_version="1.0"`
+	const point = `http_route="/work",` + target + `,` + scope
+	const bucket = `http_route="/work",` + target + `,le=`
+	wantSeries := []string{
+		`{__name__="target_info",host_name="node-1",` + target + `} 1`,
+		`{__name__="http_server_requests_total",` + point + `} 3`,
+		`{__name__="app_work_duration_seconds_bucket",` + bucket + `"0.1",` + scope + `} 1`,
+		`{__name__="app_work_duration_seconds_bucket",` + bucket + `"0.5",` + scope + `} 3`,
+		`{__name__="app_work_duration_seconds_bucket",` + bucket + `"+Inf",` + scope + `} 4`,
+		`{__name__="app_work_duration_seconds_sum",` + point + `} 1.25`,
+		`{__name__="app_work_duration_seconds_count",` + point + `} 4`,
+	}
+	// target_info is stamped with the time of the export, the points with
+	// their own.
+	for i := range wantSeries[1:] {
+		wantSeries[i+1] += " @1700000000000"
+	}
+	if len(series) > 0 {
+		series[0], _, _ = strings.Cut(series[0], " @")
+	}
+	if !slices.Equal(series, wantSeries) {
+		t.Errorf("series:\n%s\nwant:\n%s", strings.Join(series, "\n"), strings.Join(wantSeries, "\n"))
+	}
+	wantMetadata := []string{
+		"6 target_info",
+		"1 http_server_requests",
+		"3 app_work_duration_seconds",
+	}
+	if !slices.Equal(metadata, wantMetadata) {
+		t.Errorf("metadata = %q, want %q", metadata, wantMetadata)
+	}
+}
+
+// decodeWriteRequest decodes a remote-write WriteRequest, formatting each
+// series as {labels} value @timestamp, with the labels in the order they
+// were sent, and each metadata entry as "type family".
+func decodeWriteRequest(t *testing.T, b []byte) (series, metadata []string) {
+	t.Helper()
+	forEachField(t, b, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 1:
+			var labels []string
+			var sample string
+			forEachField(t, v, func(num protowire.Number, v []byte, _ uint64) {
+				switch num {
+				case 1:
+					var name, value string
+					forEachField(t, v, func(num protowire.Number, v []byte, _ uint64) {
+						if num == 1 {
+							name = string(v)
+						} else {
+							value = string(v)
+						}
+					})
+					labels = append(labels, name+"="+strconv.Quote(value))
+				case 2:
+					forEachField(t, v, func(num protowire.Number, _ []byte, n uint64) {
+						if num == 1 {
+							sample += strconv.FormatFloat(math.Float64frombits(n), 'g', -1, 64)
+						} else {
+							sample += " @" + strconv.FormatInt(int64(n), 10)
+						}
+					})
+				}
+			})
+			series = append(series, "{"+strings.Join(labels, ",")+"} "+sample)
+		case 3:
+			var kind uint64
+			var family string
+			forEachField(t, v, func(num protowire.Number, v []byte, n uint64) {
+				switch num {
+				case 1:
+					kind = n
+				case 2:
+					family = string(v)
+				}
+			})
+			metadata = append(metadata, strconv.FormatUint(kind, 10)+" "+family)
+		default:
+			t.Errorf("unexpected WriteRequest field %d", num)
+		}
+	})
+	return series, metadata
+}
+
+// forEachField calls fn with each field of msg: its number and either its
+// bytes or its varint or fixed64 value.
+func forEachField(t *testing.T, msg []byte, fn func(num protowire.Number, v []byte, n uint64)) {
+	t.Helper()
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+			}
+			fn(num, v, 0)
+			msg = msg[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(msg)
+			if n < 0 {
+				t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+			}
+			fn(num, nil, v)
+			msg = msg[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(msg)
+			if n < 0 {
+				t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+			}
+			fn(num, nil, v)
+			msg = msg[n:]
+		default:
+			t.Fatalf("field %d has unexpected wire type %d", num, typ)
+		}
+	}
+}
+
+func TestCheckRemoteWriteAggregation(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregation string
+		views       []viewConfig
+		wantErr     string
+	}{
+		{name: "explicit", aggregation: "explicit", views: defaultViewConfigs},
+		{name: "exponential default", aggregation: "exponential", wantErr: "HISTOGRAM_AGGREGATION=exponential"},
+		{
+			name:        "exponential view",
+			aggregation: "explicit",
+			views:       []viewConfig{{Instrument: "app.work.duration", Aggregation: "exponential"}},
+			wantErr:     `metric view for "app.work.duration" uses exponential aggregation`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRemoteWriteAggregation(tt.aggregation, tt.views)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkRemoteWriteAggregation() = %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkRemoteWriteAggregation() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
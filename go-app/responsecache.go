@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+
+	"my-go-app/pkg/cache"
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/telemetry"
+)
+
+// downstreamCacheKey is the response cache key of the /downstream call.
+const downstreamCacheKey = "downstream:/downstream"
+
+// openResponseCache opens the shared layer of the downstream cache: Redis at
+// CACHE_REDIS_URL, consulted on the misses of every replica's
+// stale-while-revalidate cache (see swrCache).
+func openResponseCache(ctx context.Context) (cache.Cache, error) {
+	c, err := cache.OpenRedis(ctx, cacheRedisURL, otel.GetTracerProvider(), otel.GetMeterProvider())
+	if err != nil {
+		return nil, err
+	}
+	return cache.Instrument(c, "redis", telemetry.Scope("cache").Tracer()), nil
+}
+
+// fetchDownstreamCached is fetchDownstream behind the shared response cache,
+// if one is open, and is what the downstream cache fetches on a miss. Entries
+// live for DOWNSTREAM_CACHE_TTL. The cache is best effort: a failed lookup or write is
+// recorded on its span and the downstream service is called as if it were
+// a miss. Only successful responses are cached.
+func fetchDownstreamCached(ctx context.Context) (int, error) {
+	if responseCache == nil {
+		return fetchDownstream(ctx)
+	}
+	if v, err := responseCache.Get(ctx, downstreamCacheKey); err == nil {
+		if status, err := strconv.Atoi(string(v)); err == nil {
+			return status, nil
+		}
+	}
+	status, err := fetchDownstream(ctx)
+	if err != nil || status >= 500 {
+		return status, err
+	}
+	if err := responseCache.Set(ctx, downstreamCacheKey, []byte(strconv.Itoa(status)), downstreamCacheTTL); err != nil {
+		appLog.Warn(ctx, "Failed to cache downstream response", logging.Err(err))
+	}
+	return status, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// routePolicy adjusts the middleware of a group of routes, from the routes
+// section of CONFIG_FILE. Routes are mux patterns such as "/work" or
+// "/items/{key}"; a trailing "*" matches any suffix, so "/items*" covers
+// "/items/{key}" and "*" covers every route.
+type routePolicy struct {
+	Routes []string `yaml:"routes"`
+	// Disable lists middleware, by the names in /admin/routes, to leave
+	// out of the routes' chain.
+	Disable []string `yaml:"disable"`
+	// Timeout replaces REQUEST_TIMEOUT for the routes.
+	Timeout string `yaml:"timeout"`
+	// RateLimit caps each of the routes to that many requests per second,
+	// in bursts of up to Burst (default: one second's worth).
+	RateLimit float64 `yaml:"rate_limit"`
+	Burst     int     `yaml:"burst"`
+}
+
+func (p routePolicy) matches(pattern string) bool {
+	for _, r := range p.Routes {
+		if prefix, ok := strings.CutSuffix(r, "*"); ok {
+			if strings.HasPrefix(pattern, prefix) {
+				return true
+			}
+		} else if r == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (p routePolicy) validate() error {
+	if len(p.Routes) == 0 {
+		return errors.New("route policy has no routes")
+	}
+	if p.RateLimit < 0 || p.Burst < 0 {
+		return fmt.Errorf("route policy for %s: rate_limit and burst must not be negative", strings.Join(p.Routes, ", "))
+	}
+	if p.Burst > 0 && p.RateLimit == 0 {
+		return fmt.Errorf("route policy for %s: burst needs a rate_limit", strings.Join(p.Routes, ", "))
+	}
+	if p.Timeout != "" {
+		if d, err := time.ParseDuration(p.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("route policy for %s: timeout must be a positive duration, got %q", strings.Join(p.Routes, ", "), p.Timeout)
+		}
+	}
+	return nil
+}
+
+// effectiveRoutePolicy is what the policies that match one route add up
+// to: the middleware disabled by any of them, and the timeout and rate
+// limit of the last one that sets each.
+type effectiveRoutePolicy struct {
+	Pattern    string        `json:"pattern"`
+	Name       string        `json:"name"`
+	Policies   []int         `json:"policies,omitempty"` // indexes of the matching policies
+	Disabled   []string      `json:"disabled,omitempty"`
+	Timeout    time.Duration `json:"-"`
+	RateLimit  float64       `json:"rate_limit,omitempty"`
+	Burst      int           `json:"burst,omitempty"`
+	Middleware []string      `json:"middleware"` // as compiled, outermost first
+}
+
+// resolveRoutePolicy merges the policies matching pattern in file order.
+func resolveRoutePolicy(policies []routePolicy, pattern, name string, timeout time.Duration) effectiveRoutePolicy {
+	e := effectiveRoutePolicy{Pattern: pattern, Name: name, Timeout: timeout}
+	for i, p := range policies {
+		if !p.matches(pattern) {
+			continue
+		}
+		e.Policies = append(e.Policies, i)
+		for _, name := range p.Disable {
+			if !slices.Contains(e.Disabled, name) {
+				e.Disabled = append(e.Disabled, name)
+			}
+		}
+		if p.Timeout != "" {
+			e.Timeout, _ = time.ParseDuration(p.Timeout) // validated with the config file
+		}
+		if p.RateLimit > 0 {
+			e.RateLimit, e.Burst = p.RateLimit, p.Burst
+		}
+	}
+	return e
+}
+
+// routeTable records the policy every route was compiled with, for
+// /admin/routes.
+type routeTable struct {
+	mu     sync.Mutex
+	routes []effectiveRoutePolicy
+}
+
+func (t *routeTable) add(e effectiveRoutePolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes = append(t.routes, e)
+}
+
+// unmatched reports the policies that match none of the routes, which are
+// most likely misspelt.
+func (t *routeTable) unmatched(policies []routePolicy) []error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for i, p := range policies {
+		if !slices.ContainsFunc(t.routes, func(e effectiveRoutePolicy) bool { return slices.Contains(e.Policies, i) }) {
+			errs = append(errs, fmt.Errorf("route policy for %s matches no route", strings.Join(p.Routes, ", ")))
+		}
+	}
+	return errs
+}
+
+// ServeHTTP lists the routes with their effective policy and middleware
+// chain, in registration order.
+func (t *routeTable) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	type route struct {
+		effectiveRoutePolicy
+		Timeout string `json:"timeout"`
+	}
+	t.mu.Lock()
+	routes := make([]route, len(t.routes))
+	for i, e := range t.routes {
+		routes[i] = route{e, e.Timeout.String()}
+	}
+	t.mu.Unlock()
+	writeJSON(w, routes)
+}
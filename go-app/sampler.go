@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// maxRouteSamples bounds the number of latencies kept per route and window
+// for the p99 estimate.
+const maxRouteSamples = 1024
+
+// adaptiveSamplerConfig holds the thresholds of the adaptive sampler.
+type adaptiveSamplerConfig struct {
+	// BaseRatio is the sampling probability of healthy routes.
+	BaseRatio float64
+	// BoostedRatio is the sampling probability of routes exceeding a
+	// threshold during the previous window.
+	BoostedRatio float64
+	// ErrorRate and P99 are the thresholds that trigger boosting.
+	ErrorRate float64
+	P99       time.Duration
+	// Window is the length of the tumbling window stats are computed over.
+	Window time.Duration
+	// Consistent selects consistent probability sampling for both ratios.
+	Consistent bool
+}
+
+// adaptiveSampler is a head sampler that raises the sampling probability of
+// routes whose recent error rate or p99 latency exceeded the configured
+// thresholds. Route health is fed back through Middleware.
+type adaptiveSampler struct {
+	cfg     adaptiveSamplerConfig
+	base    *swappableSampler
+	boosted sdktrace.Sampler
+
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+// routeStats accumulates the observations of one route over a window.
+type routeStats struct {
+	start     time.Time
+	requests  int
+	errors    int
+	latencies []time.Duration
+	// boosted reflects the outcome of the previous window.
+	boosted bool
+}
+
+func newAdaptiveSampler(cfg adaptiveSamplerConfig) *adaptiveSampler {
+	return &adaptiveSampler{
+		cfg:     cfg,
+		base:    newSwappableSampler(newRatioSampler(cfg.BaseRatio, cfg.Consistent)),
+		boosted: newRatioSampler(cfg.BoostedRatio, cfg.Consistent),
+		routes:  make(map[string]*routeStats),
+	}
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s *adaptiveSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	route := routeAttribute(p.Attributes)
+	if route == "" || !s.isBoosted(route) {
+		return s.base.ShouldSample(p)
+	}
+	res := s.boosted.ShouldSample(p)
+	if res.Decision == sdktrace.RecordAndSample {
+		res.Attributes = append(res.Attributes, attribute.Bool("sampling.boosted", true))
+	}
+	return res
+}
+
+// Description implements sdktrace.Sampler.
+func (s *adaptiveSampler) Description() string {
+	return fmt.Sprintf("AdaptiveSampler{base=%s,boosted=%g,errorRate=%g,p99=%s}",
+		s.base.Description(), s.cfg.BoostedRatio, s.cfg.ErrorRate, s.cfg.P99)
+}
+
+// SetBaseRatio changes the sampling probability of healthy routes.
+func (s *adaptiveSampler) SetBaseRatio(ratio float64) {
+	s.base.Swap(newRatioSampler(ratio, s.cfg.Consistent))
+}
+
+func (s *adaptiveSampler) isBoosted(route string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs, ok := s.routes[route]
+	return ok && rs.boosted
+}
+
+// Middleware reports the outcome of every request to the sampler. It must
+// wrap handlers registered on a ServeMux so the route pattern is known.
+func (s *adaptiveSampler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := httpsnoop.CaptureMetrics(next, w, r)
+		if r.Pattern != "" {
+			s.observe(r.Pattern, stats.Duration, stats.Code >= http.StatusInternalServerError)
+		}
+	})
+}
+
+func (s *adaptiveSampler) observe(route string, d time.Duration, failed bool) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rs, ok := s.routes[route]
+	if !ok {
+		rs = &routeStats{start: now}
+		s.routes[route] = rs
+	}
+	if now.Sub(rs.start) >= s.cfg.Window {
+		rs.boosted = s.unhealthy(rs)
+		*rs = routeStats{start: now, boosted: rs.boosted, latencies: rs.latencies[:0]}
+	}
+
+	rs.requests++
+	if failed {
+		rs.errors++
+	}
+	// Reservoir sampling keeps the p99 estimate unbiased under load.
+	if len(rs.latencies) < maxRouteSamples {
+		rs.latencies = append(rs.latencies, d)
+	} else if i := rand.Intn(rs.requests); i < maxRouteSamples {
+		rs.latencies[i] = d
+	}
+}
+
+func (s *adaptiveSampler) unhealthy(rs *routeStats) bool {
+	if rs.requests == 0 {
+		return false
+	}
+	if float64(rs.errors)/float64(rs.requests) > s.cfg.ErrorRate {
+		return true
+	}
+	sort.Slice(rs.latencies, func(i, j int) bool { return rs.latencies[i] < rs.latencies[j] })
+	p99 := rs.latencies[(len(rs.latencies)*99)/100]
+	return p99 > s.cfg.P99
+}
+
+func routeAttribute(attrs []attribute.KeyValue) string {
+	for _, kv := range attrs {
+		if kv.Key == semconv.HTTPRouteKey {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+// swappableSampler delegates to a sampler that can be replaced at runtime,
+// e.g. when the sampling ratio is reloaded.
+type swappableSampler struct {
+	current atomic.Pointer[sdktrace.Sampler]
+}
+
+func newSwappableSampler(s sdktrace.Sampler) *swappableSampler {
+	sw := &swappableSampler{}
+	sw.Swap(s)
+	return sw
+}
+
+// Swap makes s the sampler for subsequent decisions.
+func (sw *swappableSampler) Swap(s sdktrace.Sampler) {
+	sw.current.Store(&s)
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (sw *swappableSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return (*sw.current.Load()).ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler.
+func (sw *swappableSampler) Description() string {
+	return (*sw.current.Load()).Description()
+}
+
+var (
+	_ sdktrace.Sampler = (*adaptiveSampler)(nil)
+	_ sdktrace.Sampler = (*swappableSampler)(nil)
+)
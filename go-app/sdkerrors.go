@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"my-go-app/pkg/metrics"
+)
+
+// sdkErrorHandler receives the errors the OTel SDK cannot return to a
+// caller, such as failed background exports. Each error is counted in
+// otel.sdk.errors by class, and logged to stderr at most once per interval
+// and class, with the number of errors suppressed in between, so a down
+// collector is visible without flooding the logs. Stderr is used because
+// the OTLP log pipeline may be the thing that is failing.
+type sdkErrorHandler struct {
+	errors   metric.Int64Counter
+	interval time.Duration
+
+	mu     sync.Mutex
+	logged map[string]time.Time
+	missed map[string]int
+}
+
+func newSDKErrorHandler(meter metric.Meter, interval time.Duration) (*sdkErrorHandler, error) {
+	counter, err := metrics.SDKErrors.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &sdkErrorHandler{
+		errors:   counter,
+		interval: interval,
+		logged:   make(map[string]time.Time),
+		missed:   make(map[string]int),
+	}, nil
+}
+
+func (h *sdkErrorHandler) Handle(err error) {
+	class := classifySDKError(err)
+	h.errors.Add(context.Background(), 1, metric.WithAttributes(semconv.ErrorTypeKey.String(class)))
+
+	h.mu.Lock()
+	now := time.Now()
+	if last, ok := h.logged[class]; ok && now.Sub(last) < h.interval {
+		h.missed[class]++
+		h.mu.Unlock()
+		return
+	}
+	missed := h.missed[class]
+	h.logged[class] = now
+	h.missed[class] = 0
+	h.mu.Unlock()
+
+	attrs := []any{slog.String("error.class", class), slog.Any("error", err)}
+	if missed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", missed), slog.Duration("interval", h.interval))
+	}
+	stderrLog.Error("OTel SDK error", attrs...)
+}
+
+// classifySDKError maps err to a low-cardinality class: the gRPC status
+// code of a failed OTLP call (e.g. "unavailable", "resource_exhausted"),
+// "timeout" or "other".
+func classifySDKError(err error) string {
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.DeadlineExceeded:
+			return "timeout"
+		case codes.Unavailable:
+			return "unavailable"
+		case codes.ResourceExhausted:
+			return "resource_exhausted"
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return "unauthorized"
+		case codes.InvalidArgument:
+			return "invalid_argument"
+		default:
+			return "grpc_" + strings.ToLower(s.Code().String())
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "other"
+}
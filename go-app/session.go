@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+const (
+	sessionCookie     = "session_id"
+	journeyCookie     = "session_last_span"
+	sessionBaggageKey = "session.id"
+	sessionAttrKey    = attribute.Key("session.id")
+)
+
+// sessions simulates session affinity for user-journey demos: a browser
+// gets a session id cookie on its first request, and the id travels with
+// every request of the session as the session.id baggage member, across
+// downstream hops. Every span started under a session carries session.id
+// (see sessionSpanProcessor), so a user's journey can be reassembled from
+// traces by searching for it. The id stays off metrics: app.session.requests
+// counts session requests by route only, since a series per session would
+// grow without bound.
+//
+// With journey links enabled, each request's server span also links to the
+// server span of the previous request of the same session, so backends that
+// follow links can walk a journey step by step.
+type sessions struct {
+	ttl          time.Duration
+	journeyLinks bool
+	requests     metric.Int64Counter
+}
+
+func newSessions(meter metric.Meter, ttl time.Duration, journeyLinks bool) (*sessions, error) {
+	requests, err := metrics.SessionRequests.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &sessions{ttl: ttl, journeyLinks: journeyLinks, requests: requests}, nil
+}
+
+// Middleware attaches the request's session to its context. The session id
+// comes from incoming baggage (a downstream hop), else from the session
+// cookie, else a new id is issued in a cookie. Ids that are not UUIDs are
+// ignored wherever they come from. It must run inside otelhttp, which
+// extracts the incoming baggage.
+func (s *sessions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+		id := baggage.FromContext(ctx).Member(sessionBaggageKey).Value()
+		if !validSessionID(id) {
+			if c, err := r.Cookie(sessionCookie); err == nil && validSessionID(c.Value) {
+				id = c.Value
+			} else {
+				id = uuid.NewString()
+			}
+			// Refreshed on every request, so the session ends after ttl
+			// of inactivity.
+			http.SetCookie(w, s.cookie(sessionCookie, id))
+			// Only requests that come straight from the browser are
+			// journey steps; downstream hops are part of their caller's
+			// trace already.
+			if s.journeyLinks {
+				s.linkJourney(w, r, span)
+			}
+		}
+		ctx = withSession(ctx, id)
+
+		span.SetAttributes(sessionAttrKey.String(id))
+		s.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", r.Pattern)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (s *sessions) cookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// linkJourney links span to the span recorded in the journey cookie, if any,
+// and records span as the session's latest step. Unsampled spans are not
+// recorded, since a link to them would dangle.
+func (s *sessions) linkJourney(w http.ResponseWriter, r *http.Request, span trace.Span) {
+	if c, err := r.Cookie(journeyCookie); err == nil {
+		if prev, ok := parseJourneyStep(c.Value); ok && prev.TraceID() != span.SpanContext().TraceID() {
+			span.AddLink(trace.Link{
+				SpanContext: prev,
+				Attributes:  []attribute.KeyValue{attribute.String("link.type", "journey.previous")},
+			})
+		}
+	}
+	if sc := span.SpanContext(); sc.IsSampled() {
+		http.SetCookie(w, s.cookie(journeyCookie, sc.TraceID().String()+"-"+sc.SpanID().String()))
+	}
+}
+
+// parseJourneyStep parses a journey cookie value, "<trace id>-<span id>" in
+// hex, into a remote span context.
+func parseJourneyStep(v string) (trace.SpanContext, bool) {
+	traceHex, spanHex, ok := strings.Cut(v, "-")
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(spanHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}), true
+}
+
+// validSessionID reports whether id is a session id this service issues: a
+// UUID in its canonical 36-character form. uuid.Validate alone also accepts
+// the braced, urn: and unhyphenated forms.
+func validSessionID(id string) bool {
+	return len(id) == 36 && uuid.Validate(id) == nil
+}
+
+// withSession returns ctx with id as its session.id baggage member.
+func withSession(ctx context.Context, id string) context.Context {
+	m, err := baggage.NewMemberRaw(sessionBaggageKey, id)
+	if err != nil {
+		return ctx
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// sessionSpanProcessor copies the session.id baggage member of the parent
+// context onto every span started within a session. A malformed member,
+// such as one a client sent to a server span before Middleware replaced it,
+// is not copied.
+type sessionSpanProcessor struct{}
+
+func (sessionSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if id := baggage.FromContext(parent).Member(sessionBaggageKey).Value(); validSessionID(id) {
+		s.SetAttributes(sessionAttrKey.String(id))
+	}
+}
+
+func (sessionSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (sessionSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (sessionSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestSessionsMiddlewareValidatesIDs(t *testing.T) {
+	const known = "0b5c2f4e-8a4d-4c3e-9f43-6f0f2c1a7b10"
+	tests := []struct {
+		name       string
+		baggage    string
+		cookie     string
+		wantID     string // empty: a new id is issued
+		wantCookie bool
+	}{
+		{name: "baggage", baggage: known, wantID: known},
+		{name: "cookie", cookie: known, wantID: known, wantCookie: true},
+		{name: "no session", wantCookie: true},
+		{name: "malformed baggage", baggage: "x" + strings.Repeat("a", 4096), cookie: known, wantID: known, wantCookie: true},
+		{name: "non-canonical baggage", baggage: "{" + known + "}", wantCookie: true},
+		{name: "malformed cookie", cookie: "not-a-session", wantCookie: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSessions(noop.NewMeterProvider().Meter("test"), 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = baggage.FromContext(r.Context()).Member(sessionBaggageKey).Value()
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.baggage != "" {
+				m, err := baggage.NewMemberRaw(sessionBaggageKey, tt.baggage)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := baggage.New(m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req = req.WithContext(baggage.ContextWithBaggage(req.Context(), b))
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			switch {
+			case tt.wantID != "" && got != tt.wantID:
+				t.Errorf("session id = %q, want %q", got, tt.wantID)
+			case tt.wantID == "" && (!validSessionID(got) || got == tt.baggage || got == tt.cookie):
+				t.Errorf("session id = %q, want a new id", got)
+			}
+			if gotCookie := len(rec.Result().Cookies()) > 0; gotCookie != tt.wantCookie {
+				t.Errorf("cookie issued = %v, want %v", gotCookie, tt.wantCookie)
+			}
+		})
+	}
+}
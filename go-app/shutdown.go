@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"my-go-app/pkg/health"
+)
+
+// shutdownCoordinator tears the process down in a fixed order. Components
+// register a step as they start; Shutdown runs the steps in reverse
+// registration order, like deferred calls, so the servers registered last
+// stop accepting traffic and drain first, and the telemetry pipeline set up
+// first is flushed and shut down last, after every other step had the chance
+// to emit its final spans and logs.
+type shutdownCoordinator struct {
+	// telemetryTimeout bounds the telemetry steps, which get a deadline of
+	// their own so a slow drain cannot leave the final flush none; zero
+	// runs them under the shutdown context like the other steps.
+	telemetryTimeout time.Duration
+
+	mu    sync.Mutex
+	steps []shutdownStep
+}
+
+type shutdownStep struct {
+	name      string
+	fn        func(context.Context) error
+	telemetry bool
+}
+
+// Add registers a shutdown step.
+func (c *shutdownCoordinator) Add(name string, fn func(context.Context) error) {
+	c.add(shutdownStep{name: name, fn: fn})
+}
+
+// AddTelemetry registers a step that flushes or shuts down the telemetry
+// pipeline. Telemetry steps are registered first and so run last.
+func (c *shutdownCoordinator) AddTelemetry(name string, fn func(context.Context) error) {
+	c.add(shutdownStep{name: name, fn: fn, telemetry: true})
+}
+
+func (c *shutdownCoordinator) add(step shutdownStep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, step)
+}
+
+// Shutdown runs every step, even after one fails, and returns all failures
+// joined. ctx bounds the steps but the telemetry ones, which share a fresh
+// telemetryTimeout that starts with the first of them; it must not be the
+// signal context, which is already canceled by the time Shutdown runs.
+func (c *shutdownCoordinator) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	steps := c.steps
+	c.steps = nil
+	c.mu.Unlock()
+
+	var errs []error
+	stepCtx := ctx
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].telemetry && stepCtx == ctx && c.telemetryTimeout > 0 {
+			var cancel context.CancelFunc
+			stepCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), c.telemetryTimeout)
+			defer cancel()
+		}
+		if err := steps[i].fn(stepCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", steps[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownSignalsByName are the signals SHUTDOWN_SIGNALS can list.
+var shutdownSignalsByName = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+}
+
+// parseSignals parses a list such as "SIGINT,SIGTERM".
+func parseSignals(list string) ([]os.Signal, error) {
+	var signals []os.Signal
+	for _, name := range splitList(list) {
+		sig, ok := shutdownSignalsByName[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown shutdown signal %q, want SIGINT, SIGTERM, SIGQUIT or SIGHUP", name)
+		}
+		signals = append(signals, sig)
+	}
+	if len(signals) == 0 {
+		return nil, errors.New("no shutdown signals configured")
+	}
+	return signals, nil
+}
+
+// parseServerFlags lets the shutdown settings be overridden on the command
+// line, e.g. `my-go-app -shutdown-timeout=30s -pre-stop-delay=5s`; the
+// environment provides the defaults. It returns the arguments after the
+// flags, such as a subcommand.
+func parseServerFlags(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("my-go-app", flag.ContinueOnError)
+	fs.DurationVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeout, "time allowed to drain requests and flush telemetry (SHUTDOWN_TIMEOUT)")
+	fs.DurationVar(&preStopDelay, "pre-stop-delay", preStopDelay, "time to keep serving while reporting unready before draining (PRE_STOP_DELAY)")
+	fs.StringVar(&shutdownSignals, "shutdown-signals", shutdownSignals, "signals that start a graceful shutdown (SHUTDOWN_SIGNALS)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
+}
+
+// draining is set once shutdown starts; readiness fails from then on.
+var draining atomic.Bool
+
+// preStop marks the pod as draining, so readiness fails, and keeps serving
+// for delay while load balancers stop sending it traffic.
+func preStop(delay time.Duration) {
+	draining.Store(true)
+	if delay <= 0 {
+		return
+	}
+	logger.Info("Draining before shutdown", "pre_stop_delay", delay.String())
+	time.Sleep(delay)
+}
+
+// drainingReadiness fails readiness once shutdown has started and defers to
+// next before that.
+func drainingReadiness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			health.WriteStatus(w, false, []health.Status{{Name: "shutdown", Error: "draining"}})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownGivesTelemetryStepsTheirOwnDeadline(t *testing.T) {
+	c := &shutdownCoordinator{telemetryTimeout: time.Second}
+	var flushErr error
+	var flushDeadline time.Time
+	c.AddTelemetry("telemetry flush", func(ctx context.Context) error {
+		flushErr = ctx.Err()
+		flushDeadline, _ = ctx.Deadline()
+		return nil
+	})
+	drained := false
+	c.Add("HTTP server", func(ctx context.Context) error {
+		<-ctx.Done() // a drain that uses up the whole shutdown budget
+		drained = true
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); err == nil {
+		t.Error("Shutdown() = nil, want the drain's deadline error")
+	}
+	if !drained {
+		t.Fatal("drain step did not run before the telemetry step")
+	}
+	if flushErr != nil {
+		t.Errorf("telemetry step ran with a done context: %v", flushErr)
+	}
+	if left := time.Until(flushDeadline); left < 500*time.Millisecond {
+		t.Errorf("telemetry step had %s left, want about its own 1s", left)
+	}
+}
+
+func TestShutdownWithoutTelemetryTimeout(t *testing.T) {
+	c := &shutdownCoordinator{}
+	var flushCtx context.Context
+	c.AddTelemetry("telemetry flush", func(ctx context.Context) error {
+		flushCtx = ctx
+		return nil
+	})
+	ctx := context.Background()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if flushCtx != ctx {
+		t.Error("telemetry step did not run under the shutdown context")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"my-go-app/pkg/telemetry"
+)
+
+// requestsServed and requestsFailed count the HTTP requests served, and
+// those answered with a 5xx or a panic, for the shutdown summary. See
+// countRequests.
+var requestsServed, requestsFailed atomic.Int64
+
+// countRequests counts the requests next serves in requestsServed and
+// requestsFailed. It wraps each route outside its middleware chain, so no
+// route policy can disable it and the summary holds whatever the chain
+// leaves out.
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, wrote, done := http.StatusOK, false, false
+		defer func() {
+			requestsServed.Add(1)
+			// A handler that panicked, done still false, has failed.
+			if !done || code >= http.StatusInternalServerError {
+				requestsFailed.Add(1)
+			}
+		}()
+		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(c int) {
+					if !wrote {
+						code, wrote = c, true
+					}
+					next(c)
+				}
+			},
+		})
+		next.ServeHTTP(w, r)
+		done = true
+	})
+}
+
+// shutdownSummary is what the process did over its lifetime, reported once
+// everything has shut down.
+type shutdownSummary struct {
+	Service          string                  `json:"service"`
+	Instance         string                  `json:"instance,omitempty"`
+	Version          string                  `json:"version"`
+	Uptime           float64                 `json:"uptime_s"`
+	ShutdownDuration float64                 `json:"shutdown_duration_s"`
+	RequestsServed   int64                   `json:"requests_served"`
+	RequestsFailed   int64                   `json:"requests_failed"`
+	Telemetry        map[string]signalTotals `json:"telemetry,omitempty"` // by signal
+	Error            string                  `json:"error,omitempty"`
+}
+
+// LogValue renders the summary as a group, so it is logged field by field
+// whatever the handler.
+func (s shutdownSummary) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("service", s.Service)}
+	if s.Instance != "" {
+		attrs = append(attrs, slog.String("instance", s.Instance))
+	}
+	attrs = append(attrs,
+		slog.String("version", s.Version),
+		slog.Float64("uptime_s", s.Uptime),
+		slog.Float64("shutdown_duration_s", s.ShutdownDuration),
+		slog.Int64("requests_served", s.RequestsServed),
+		slog.Int64("requests_failed", s.RequestsFailed),
+	)
+	if len(s.Telemetry) > 0 {
+		var signals []slog.Attr
+		for _, signal := range slices.Sorted(maps.Keys(s.Telemetry)) {
+			t := s.Telemetry[signal]
+			signals = append(signals, slog.Group(signal,
+				slog.Int64("exported", t.Exported),
+				slog.Int64("failed", t.Failed),
+				slog.Int64("failed_exports", t.FailedExports),
+				slog.Int64("dropped", t.Dropped),
+			))
+		}
+		attrs = append(attrs, slog.Attr{Key: "telemetry", Value: slog.GroupValue(signals...)})
+	}
+	if s.Error != "" {
+		attrs = append(attrs, slog.String("error", s.Error))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// signalTotals is what one signal's pipeline exported, failed to export and
+// dropped, in items.
+type signalTotals struct {
+	Exported      int64 `json:"exported"`
+	Failed        int64 `json:"failed"`
+	FailedExports int64 `json:"failed_exports"`
+	Dropped       int64 `json:"dropped"`
+}
+
+// reportShutdownSummary writes the summary of a process whose shutdown
+// started at shutdownStart and that exits with err. The telemetry pipeline
+// is gone by then, so it is logged to stderr through stderrLog, where
+// `kubectl logs --previous` finds it even when the backends lost the last
+// batches, and written as JSON to SHUTDOWN_SUMMARY_FILE if set (e.g.
+// /dev/termination-log, shown by `kubectl describe pod`).
+func reportShutdownSummary(shutdownStart time.Time, err error) {
+	s := shutdownSummary{
+		Service:          defaultServiceName(),
+		Version:          telemetry.Version,
+		Uptime:           time.Since(startTime).Seconds(),
+		ShutdownDuration: time.Since(shutdownStart).Seconds(),
+		RequestsServed:   requestsServed.Load(),
+		RequestsFailed:   requestsFailed.Load(),
+	}
+	if serviceResource != nil {
+		set := serviceResource.Set()
+		if v, ok := set.Value(semconv.ServiceNameKey); ok && v.AsString() != "" {
+			s.Service = v.AsString()
+		}
+		if v, ok := set.Value(semconv.ServiceInstanceIDKey); ok {
+			s.Instance = v.AsString()
+		}
+	}
+	if telemetryPipeline != nil {
+		s.Telemetry = telemetryPipeline.Totals()
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	stderrLog.Info("Shutdown summary", "summary", s)
+	if shutdownSummaryFile == "" {
+		return
+	}
+	data, mErr := json.Marshal(s)
+	if mErr == nil {
+		mErr = os.WriteFile(shutdownSummaryFile, append(data, '\n'), 0o644)
+	}
+	if mErr != nil {
+		stderrLog.Error("Failed to write shutdown summary", "path", shutdownSummaryFile, "error", mErr)
+	}
+}
+
+// defaultServiceName is OTEL_SERVICE_NAME or, without it, the SDK's
+// default, unknown_service:<executable>.
+func defaultServiceName() string {
+	if serviceName != "" {
+		return serviceName
+	}
+	if v, ok := resource.Default().Set().Value(semconv.ServiceNameKey); ok {
+		return v.AsString()
+	}
+	return ""
+}
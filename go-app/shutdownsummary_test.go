@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineMetricsTotals(t *testing.T) {
+	m := newTestPipelineMetrics(t)
+	ctx := context.Background()
+	m.recordExport(ctx, "traces", 10, time.Now(), nil)
+	m.recordExport(ctx, "traces", 5, time.Now(), errors.New("unavailable"))
+	m.recordExport(ctx, "traces", 3, time.Now(), errors.New("unavailable"))
+	m.recordExport(ctx, "metrics", 7, time.Now(), nil)
+	m.recordBufferDrop(ctx, "logs", 4)
+	// Reported by the SDK, see sdkLogSink.
+	m.spansDropped.Store(2)
+	m.logsDropped.Add(1)
+
+	want := map[string]signalTotals{
+		"traces":  {Exported: 10, Failed: 8, FailedExports: 2, Dropped: 2},
+		"metrics": {Exported: 7},
+		"logs":    {Dropped: 5},
+	}
+	got := m.Totals()
+	if len(got) != len(want) {
+		t.Errorf("Totals() = %+v, want %+v", got, want)
+	}
+	for signal, w := range want {
+		if got[signal] != w {
+			t.Errorf("Totals()[%q] = %+v, want %+v", signal, got[signal], w)
+		}
+	}
+
+	// The SDK's drop counts are added to a copy, not accumulated.
+	got["traces"] = signalTotals{}
+	if again := m.Totals(); again["traces"] != want["traces"] {
+		t.Errorf("second Totals()[traces] = %+v, want %+v", again["traces"], want["traces"])
+	}
+}
+
+func TestCountRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantFailed int64
+	}{
+		{"ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, 0},
+		{"client error", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, 0},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.WriteHeader(http.StatusOK) // superfluous, ignored
+		}, 1},
+		{"panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") }, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served, failed := requestsServed.Load(), requestsFailed.Load()
+			func() {
+				defer func() { recover() }()
+				countRequests(tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+			if n := requestsServed.Load() - served; n != 1 {
+				t.Errorf("requests served went up by %d, want 1", n)
+			}
+			if n := requestsFailed.Load() - failed; n != tt.wantFailed {
+				t.Errorf("requests failed went up by %d, want %d", n, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestDefaultServiceName(t *testing.T) {
+	defer func(name string) { serviceName = name }(serviceName)
+
+	serviceName = "checkout"
+	if got := defaultServiceName(); got != "checkout" {
+		t.Errorf("defaultServiceName() = %q, want OTEL_SERVICE_NAME", got)
+	}
+	serviceName = ""
+	if got := defaultServiceName(); !strings.HasPrefix(got, "unknown_service:") {
+		t.Errorf("defaultServiceName() = %q, want the SDK's unknown_service:<executable>", got)
+	}
+}
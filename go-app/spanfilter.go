@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanKindFilterProcessor forwards only spans of the kinds in keep, and the
+// telemetry canary's span, to next.
+// Filtered spans are still recorded (span metrics and the tail sampling
+// buffer see them) but never exported. Children of a filtered span keep
+// pointing at it, so backends show them under a missing parent.
+type spanKindFilterProcessor struct {
+	next sdktrace.SpanProcessor
+	keep map[trace.SpanKind]bool
+}
+
+func (p spanKindFilterProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p spanKindFilterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.keep[s.SpanKind()] || isCanarySpan(s) {
+		p.next.OnEnd(s)
+	}
+}
+
+func (p spanKindFilterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p spanKindFilterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+var spanKindsByName = map[string]trace.SpanKind{
+	"internal": trace.SpanKindInternal,
+	"server":   trace.SpanKindServer,
+	"client":   trace.SpanKindClient,
+	"producer": trace.SpanKindProducer,
+	"consumer": trace.SpanKindConsumer,
+}
+
+// exportedSpanKinds builds the set of span kinds to export from
+// comma-separated lists of kinds to keep and to drop (e.g. keep
+// "server,client", or drop "internal"). An empty keep list means all kinds.
+// It returns nil when every kind is exported.
+func exportedSpanKinds(keep, drop string) (map[trace.SpanKind]bool, error) {
+	kinds := make(map[trace.SpanKind]bool)
+	if keep == "" {
+		for _, k := range spanKindsByName {
+			kinds[k] = true
+		}
+	} else {
+		names, err := parseSpanKinds(keep)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range names {
+			kinds[k] = true
+		}
+	}
+	dropped, err := parseSpanKinds(drop)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range dropped {
+		delete(kinds, k)
+	}
+	if len(kinds) == len(spanKindsByName) {
+		return nil, nil
+	}
+	// Spans started without an explicit kind are internal.
+	kinds[trace.SpanKindUnspecified] = kinds[trace.SpanKindInternal]
+	return kinds, nil
+}
+
+func parseSpanKinds(list string) ([]trace.SpanKind, error) {
+	var kinds []trace.SpanKind
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		k, ok := spanKindsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown span kind %q", name)
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// spanDropRule matches spans whose attribute key equals value, or starts
+// with it when value ends in "*".
+type spanDropRule struct {
+	key   attribute.Key
+	value string
+}
+
+func (r spanDropRule) match(v string) bool {
+	if prefix, ok := strings.CutSuffix(r.value, "*"); ok {
+		return strings.HasPrefix(v, prefix)
+	}
+	return v == r.value
+}
+
+// parseSpanDropRules parses a comma-separated list of key=value rules, e.g.
+// "url.path=/healthz,user_agent.original=kube-probe/*".
+func parseSpanDropRules(list string) ([]spanDropRule, error) {
+	var rules []spanDropRule
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid span drop rule %q, want key=value", entry)
+		}
+		rules = append(rules, spanDropRule{attribute.Key(strings.TrimSpace(key)), strings.TrimSpace(value)})
+	}
+	return rules, nil
+}
+
+// noiseFilterProcessor drops spans matching any of its rules, such as load
+// balancer health checks, before they reach next.
+type noiseFilterProcessor struct {
+	next  sdktrace.SpanProcessor
+	rules []spanDropRule
+}
+
+func (p noiseFilterProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p noiseFilterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, kv := range s.Attributes() {
+		for _, r := range p.rules {
+			if kv.Key == r.key && r.match(kv.Value.Emit()) {
+				return
+			}
+		}
+	}
+	p.next.OnEnd(s)
+}
+
+func (p noiseFilterProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p noiseFilterProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// requestFilter applies the rules on url.path, user_agent.original and
+// http.route to an inbound request, for otelhttp.WithFilter: matching
+// requests are not traced at all, which is cheaper than dropping their spans
+// after the fact. It returns true for requests to trace.
+func requestFilter(rules []spanDropRule) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, rule := range rules {
+			var v string
+			switch rule.key {
+			case "url.path":
+				v = r.URL.Path
+			case "user_agent.original":
+				v = r.UserAgent()
+			case "http.route":
+				v = r.Pattern
+			default:
+				continue
+			}
+			if rule.match(v) {
+				return false
+			}
+		}
+		return true
+	}
+}
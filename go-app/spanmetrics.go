@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/metrics"
+)
+
+// spanMetricsProcessor derives RED metrics from spans: call counts, error
+// counts and durations per span name, kind and status, named after the
+// collector's spanmetrics connector. Combined with recordOnlySampler it also
+// sees spans whose traces are sampled out, so the metrics stay complete at
+// any sampling ratio.
+type spanMetricsProcessor struct {
+	calls    metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newSpanMetricsProcessor(meter metric.Meter) (*spanMetricsProcessor, error) {
+	var (
+		p   spanMetricsProcessor
+		err error
+	)
+	p.calls, err = metrics.SpanMetricsCalls.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	p.errors, err = metrics.SpanMetricsErrors.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+
+	p.duration, err = metrics.SpanMetricsDuration.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *spanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	// Recording with the span context lets sampled spans become exemplars.
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	opt := metric.WithAttributes(
+		attribute.String("span.name", s.Name()),
+		attribute.String("span.kind", s.SpanKind().String()),
+		attribute.String("status.code", s.Status().Code.String()),
+	)
+
+	p.calls.Add(ctx, 1, opt)
+	if s.Status().Code == codes.Error {
+		p.errors.Add(ctx, 1, opt)
+	}
+	p.duration.Record(ctx, s.EndTime().Sub(s.StartTime()).Seconds(), opt)
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error   { return nil }
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error { return nil }
+
+// recordOnlySampler turns the Drop decisions of the wrapped sampler into
+// RecordOnly: such spans are still not exported, but span processors see
+// them when they end.
+type recordOnlySampler struct {
+	sdktrace.Sampler
+}
+
+func (s recordOnlySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.Sampler.ShouldSample(p)
+	if res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s recordOnlySampler) Description() string {
+	return "RecordOnly{" + s.Sampler.Description() + "}"
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+)
+
+// Results of a cache lookup, recorded as cache.result.
+const (
+	cacheHit   = "hit"
+	cacheStale = "stale"
+	cacheMiss  = "miss"
+)
+
+// swrCache caches one downstream response with stale-while-revalidate
+// semantics: within ttl it is served as is; for up to stale after that it is
+// still served, while a single background refresh fetches a new one. Older
+// responses, and the first request, wait for the fetch.
+//
+// Only responses that validate accepts are cached; the others are still
+// returned to the request that fetched them. Concurrent fetches, by misses
+// or the refresh, are collapsed into one.
+//
+// The background refresh runs in its own trace, linked to the request that
+// triggered it, so the request's trace does not outlive the request.
+type swrCache[T any] struct {
+	ttl, stale time.Duration
+	fetch      func(context.Context) (T, error)
+	validate   func(T) error
+	group      singleflight.Group
+
+	mu         sync.Mutex
+	value      T
+	fetched    time.Time // zero until the first successful fetch
+	refreshing bool
+
+	requests        metric.Int64Counter
+	staleness       metric.Float64Histogram
+	refreshFailures metric.Int64Counter
+}
+
+func newSWRCache[T any](meter metric.Meter, ttl, stale time.Duration, fetch func(context.Context) (T, error), validate func(T) error) (*swrCache[T], error) {
+	c := &swrCache[T]{ttl: ttl, stale: stale, fetch: fetch, validate: validate}
+	var err error
+	c.requests, err = metrics.DownstreamCacheRequests.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	c.staleness, err = metrics.DownstreamCacheStaleness.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	c.refreshFailures, err = metrics.DownstreamCacheRefreshFailures.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the cached response, refreshing it as described on swrCache,
+// and the cache.result of the lookup.
+func (c *swrCache[T]) Get(ctx context.Context) (T, string, error) {
+	c.mu.Lock()
+	age := time.Since(c.fetched)
+	switch {
+	case !c.fetched.IsZero() && age < c.ttl:
+		v := c.value
+		c.mu.Unlock()
+		c.record(ctx, cacheHit)
+		return v, cacheHit, nil
+	case !c.fetched.IsZero() && age < c.ttl+c.stale:
+		v := c.value
+		if !c.refreshing {
+			c.refreshing = true
+			go c.refresh(trace.LinkFromContext(ctx))
+		}
+		c.mu.Unlock()
+		c.record(ctx, cacheStale)
+		c.staleness.Record(ctx, (age - c.ttl).Seconds())
+		return v, cacheStale, nil
+	}
+	c.mu.Unlock()
+
+	c.record(ctx, cacheMiss)
+	v, shared, err := c.load(ctx)
+	if shared {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.fetch_shared", true))
+	}
+	return v, cacheMiss, err
+}
+
+// load fetches a response, or waits for the fetch already in progress, and
+// caches it if it is valid. shared reports whether the fetch served other
+// callers too.
+func (c *swrCache[T]) load(ctx context.Context) (v T, shared bool, err error) {
+	ch := c.group.DoChan("", func() (any, error) {
+		v, err := c.fetch(ctx)
+		if err == nil && c.validate(v) == nil {
+			c.store(v)
+		}
+		return v, err
+	})
+	select {
+	case res := <-ch:
+		v, _ := res.Val.(T)
+		return v, res.Shared, res.Err
+	case <-ctx.Done():
+		return v, false, ctx.Err()
+	}
+}
+
+func (c *swrCache[T]) record(ctx context.Context, result string) {
+	c.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("cache.result", result)))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.result", result))
+}
+
+func (c *swrCache[T]) store(v T) {
+	c.mu.Lock()
+	c.value, c.fetched = v, time.Now()
+	c.mu.Unlock()
+}
+
+// refresh fetches a new response under a span linked to the request that
+// found the cached one stale.
+func (c *swrCache[T]) refresh(link trace.Link) {
+	defer func() {
+		c.mu.Lock()
+		c.refreshing = false
+		c.mu.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), c.ttl+c.stale)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "downstream.cache.refresh",
+		trace.WithNewRoot(),
+		trace.WithLinks(link),
+	)
+	defer span.End()
+
+	v, _, err := c.load(ctx)
+	if err == nil {
+		err = c.validate(v)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.refreshFailures.Add(ctx, 1)
+		appLog.Warn(ctx, "Downstream cache refresh failed, serving stale response", logging.Err(err))
+	}
+}
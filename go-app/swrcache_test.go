@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func rejectServerErrors(status int) error {
+	if status >= http.StatusInternalServerError {
+		return fmt.Errorf("downstream service answered %d", status)
+	}
+	return nil
+}
+
+func TestSWRCacheMissPassesOnInvalidResponse(t *testing.T) {
+	var fetches atomic.Int32
+	status := http.StatusServiceUnavailable
+	c, err := newSWRCache(noop.NewMeterProvider().Meter("test"), time.Minute, time.Minute,
+		func(context.Context) (int, error) {
+			fetches.Add(1)
+			return status, nil
+		}, rejectServerErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, result, err := c.Get(context.Background())
+	if err != nil || v != http.StatusServiceUnavailable || result != cacheMiss {
+		t.Fatalf("Get() = %d, %s, %v, want %d, miss, no error", v, result, err, http.StatusServiceUnavailable)
+	}
+	// Not cached, so the next request fetches again.
+	status = http.StatusOK
+	if v, result, _ := c.Get(context.Background()); v != http.StatusOK || result != cacheMiss {
+		t.Fatalf("Get() after a 503 = %d, %s, want %d, miss", v, result, http.StatusOK)
+	}
+	if v, result, _ := c.Get(context.Background()); v != http.StatusOK || result != cacheHit {
+		t.Fatalf("Get() after a 200 = %d, %s, want %d, hit", v, result, http.StatusOK)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+}
+
+func TestSWRCacheCollapsesConcurrentMisses(t *testing.T) {
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	c, err := newSWRCache(noop.NewMeterProvider().Meter("test"), time.Minute, time.Minute,
+		func(context.Context) (int, error) {
+			fetches.Add(1)
+			<-release
+			return http.StatusOK, nil
+		}, rejectServerErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _, err := c.Get(context.Background()); err != nil || v != http.StatusOK {
+				t.Errorf("Get() = %d, %v, want %d", v, err, http.StatusOK)
+			}
+		}()
+	}
+	// Let every caller reach the fetch before it returns.
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d concurrent misses fetched %d times, want 1", callers, n)
+	}
+}
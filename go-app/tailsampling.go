@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tailSamplingProcessor gives tail-sampling-like behavior without a collector
+// tail processor. Spans of traces dropped by the head sampler (recorded
+// through recordOnlySampler) are held in memory for up to decisionWait; if a
+// request of the trace ends in error, the buffered spans and every later span
+// of the trace are promoted to export. Head-sampled spans pass through
+// untouched.
+type tailSamplingProcessor struct {
+	next         sdktrace.SpanProcessor
+	decisionWait time.Duration
+	maxSpans     int
+
+	mu       sync.Mutex
+	traces   map[trace.TraceID]*bufferedTrace
+	order    []bufferedTraceRef // FIFO by first span, for eviction
+	buffered int
+	promoted map[trace.TraceID]time.Time
+}
+
+type bufferedTrace struct {
+	firstSeen time.Time
+	spans     []sdktrace.ReadOnlySpan
+}
+
+type bufferedTraceRef struct {
+	id        trace.TraceID
+	firstSeen time.Time
+}
+
+func newTailSamplingProcessor(next sdktrace.SpanProcessor, decisionWait time.Duration, maxSpans int) *tailSamplingProcessor {
+	return &tailSamplingProcessor{
+		next:         next,
+		decisionWait: decisionWait,
+		maxSpans:     maxSpans,
+		traces:       make(map[trace.TraceID]*bufferedTrace),
+		promoted:     make(map[trace.TraceID]time.Time),
+	}
+}
+
+func (p *tailSamplingProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *tailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+
+	now := time.Now()
+	id := s.SpanContext().TraceID()
+
+	p.mu.Lock()
+	p.evict(now)
+
+	if _, ok := p.promoted[id]; ok {
+		p.mu.Unlock()
+		p.next.OnEnd(promotedSpan{s})
+		return
+	}
+
+	// A local root span is the request handled by this process.
+	localRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+	if localRoot && s.Status().Code == codes.Error {
+		var spans []sdktrace.ReadOnlySpan
+		if bt, ok := p.traces[id]; ok {
+			spans = bt.spans
+			p.buffered -= len(bt.spans)
+			delete(p.traces, id)
+		}
+		p.promoted[id] = now
+		p.mu.Unlock()
+
+		for _, buffered := range spans {
+			p.next.OnEnd(promotedSpan{buffered})
+		}
+		p.next.OnEnd(promotedSpan{s})
+		return
+	}
+
+	bt, ok := p.traces[id]
+	if !ok {
+		bt = &bufferedTrace{firstSeen: now}
+		p.traces[id] = bt
+		p.order = append(p.order, bufferedTraceRef{id: id, firstSeen: now})
+	}
+	bt.spans = append(bt.spans, s)
+	p.buffered++
+	p.mu.Unlock()
+}
+
+// evict discards traces older than decisionWait, and the oldest traces while
+// the buffer holds more than maxSpans spans. p.mu must be held.
+func (p *tailSamplingProcessor) evict(now time.Time) {
+	for len(p.order) > 0 {
+		ref := p.order[0]
+		if now.Sub(ref.firstSeen) < p.decisionWait && p.buffered <= p.maxSpans {
+			break
+		}
+		p.order = p.order[1:]
+		if bt, ok := p.traces[ref.id]; ok && bt.firstSeen.Equal(ref.firstSeen) {
+			p.buffered -= len(bt.spans)
+			delete(p.traces, ref.id)
+		}
+	}
+	for id, at := range p.promoted {
+		if now.Sub(at) >= p.decisionWait {
+			delete(p.promoted, id)
+		}
+	}
+}
+
+func (p *tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// promotedSpan marks a span recorded without the sampled flag as sampled, so
+// the batch span processor exports it.
+type promotedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s promotedSpan) SpanContext() trace.SpanContext {
+	return s.ReadOnlySpan.SpanContext().WithTraceFlags(trace.FlagsSampled)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Shares of TELEMETRY_BUFFER_MEMORY given to each signal's buffer.
+const (
+	spanBufferShare = 0.6
+	logBufferShare  = 0.4
+)
+
+// telemetryBufferBudget returns the memory, in bytes, telemetry may buffer
+// while waiting to be exported: TELEMETRY_BUFFER_MEMORY if set, otherwise
+// 5% of the container's memory limit between 4MiB and 64MiB, or 64MiB
+// without a limit.
+func telemetryBufferBudget() int64 {
+	if telemetryBufferMemory > 0 {
+		return int64(telemetryBufferMemory)
+	}
+	if memoryLimit > 0 {
+		return min(max(memoryLimit/20, 4<<20), 64<<20)
+	}
+	return 64 << 20
+}
+
+// sizedItem is a buffered item with its estimated size.
+type sizedItem[T any] struct {
+	item T
+	size int64
+}
+
+// exportBuffer sits between a batch processor and its exporter and holds
+// the batches waiting to be exported, so the processor's own queue stays
+// short and buffered telemetry is bounded by memory rather than by count.
+// When the buffered items exceed limit bytes, the oldest are dropped: when
+// the collector is down, what it receives once it is back is the most
+// recent telemetry. Batches are exported one at a time by a background
+// goroutine; their errors go to the global error handler. shutdown stops
+// that goroutine before returning, so the exporter can be shut down after
+// it without an export still running.
+type exportBuffer[T any] struct {
+	signal  string
+	limit   int64
+	size    func(T) int64
+	export  func(context.Context, []T) error
+	timeout time.Duration
+	metrics *pipelineMetrics
+
+	mu       sync.Mutex
+	batches  [][]sizedItem[T]
+	bytes    int64 // queued and in-flight items
+	inFlight bool
+	started  uint64        // batches taken for export so far
+	lastErr  error         // of the last batch exported, the started-th
+	changed  chan struct{} // closed and replaced when a batch is done
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	// cancel aborts the export in progress when shutdown runs out of time.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newExportBuffer[T any](signal string, limit int64, m *pipelineMetrics, size func(T) int64, export func(context.Context, []T) error) *exportBuffer[T] {
+	b := &exportBuffer[T]{
+		signal:  signal,
+		limit:   limit,
+		size:    size,
+		export:  export,
+		timeout: batchExportTimeout,
+		metrics: m,
+		changed: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	if b.timeout <= 0 {
+		b.timeout = 30 * time.Second // the SDK's default export timeout
+	}
+	m.addBuffer(b)
+	go b.run()
+	return b
+}
+
+// enqueue adds items as one batch, dropping the oldest queued items while
+// the buffer is over its limit.
+func (b *exportBuffer[T]) enqueue(items []T) {
+	if len(items) == 0 {
+		return
+	}
+	batch := make([]sizedItem[T], len(items))
+	var bytes int64
+	for i, it := range items {
+		batch[i] = sizedItem[T]{it, b.size(it)}
+		bytes += batch[i].size
+	}
+
+	b.mu.Lock()
+	b.batches = append(b.batches, batch)
+	b.bytes += bytes
+	dropped := 0
+	for b.bytes > b.limit && len(b.batches) > 0 {
+		head := b.batches[0]
+		b.bytes -= head[0].size
+		dropped++
+		if len(head) == 1 {
+			b.batches = b.batches[1:]
+		} else {
+			b.batches[0] = head[1:]
+		}
+	}
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		b.metrics.recordBufferDrop(context.Background(), b.signal, dropped)
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// usage returns the signal buffered, the bytes buffered including the batch
+// being exported, and the limit.
+func (b *exportBuffer[T]) usage() (signal string, bytes, limit int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.signal, b.bytes, b.limit
+}
+
+func (b *exportBuffer[T]) run() {
+	defer close(b.done)
+	for {
+		b.mu.Lock()
+		select {
+		case <-b.stop:
+			// shutdown ran out of time: what is left is dropped.
+			dropped := 0
+			for _, batch := range b.batches {
+				dropped += len(batch)
+			}
+			b.batches = nil
+			b.bytes = 0
+			b.mu.Unlock()
+			if dropped > 0 {
+				b.metrics.recordBufferDrop(context.Background(), b.signal, dropped)
+			}
+			return
+		default:
+		}
+		if len(b.batches) == 0 {
+			b.mu.Unlock()
+			select {
+			case <-b.wake:
+				continue
+			case <-b.stop:
+				return
+			}
+		}
+		batch := b.batches[0]
+		b.batches = b.batches[1:]
+		b.inFlight = true
+		b.started++
+		b.mu.Unlock()
+
+		items := make([]T, len(batch))
+		var bytes int64
+		for i, it := range batch {
+			items[i] = it.item
+			bytes += it.size
+		}
+		ctx, cancel := context.WithTimeout(b.ctx, b.timeout)
+		err := b.export(ctx, items)
+		cancel()
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		b.mu.Lock()
+		b.bytes -= bytes
+		b.inFlight = false
+		b.lastErr = err
+		close(b.changed)
+		b.changed = make(chan struct{})
+		b.mu.Unlock()
+	}
+}
+
+// flush waits until every buffered batch has been exported. Like the batch
+// processors' ForceFlush, it returns the error of the last export it caused:
+// a batch already being exported when flush is called is waited for, but its
+// error has been handled already.
+func (b *exportBuffer[T]) flush(ctx context.Context) error {
+	b.mu.Lock()
+	startedBefore := b.started
+	b.mu.Unlock()
+	for {
+		b.mu.Lock()
+		if len(b.batches) == 0 && !b.inFlight {
+			var err error
+			if b.started > startedBefore {
+				err = b.lastErr
+			}
+			b.mu.Unlock()
+			return err
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// shutdown exports what is buffered, within ctx, and stops the buffer. If
+// ctx ends first, the export in progress is canceled and the rest dropped;
+// either way the background goroutine has returned when shutdown does.
+func (b *exportBuffer[T]) shutdown(ctx context.Context) error {
+	err := b.flush(ctx)
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+	}
+	b.cancel()
+	return err
+}
+
+// bufferedSpanExporter hands spans to an exportBuffer in front of the
+// wrapped exporter.
+type bufferedSpanExporter struct {
+	next   sdktrace.SpanExporter
+	buffer *exportBuffer[sdktrace.ReadOnlySpan]
+}
+
+func newBufferedSpanExporter(next sdktrace.SpanExporter, limit int64, m *pipelineMetrics) *bufferedSpanExporter {
+	return &bufferedSpanExporter{
+		next:   next,
+		buffer: newExportBuffer("traces", limit, m, spanSize, next.ExportSpans),
+	}
+}
+
+func (e *bufferedSpanExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.buffer.enqueue(spans)
+	return nil
+}
+
+func (e *bufferedSpanExporter) Shutdown(ctx context.Context) error {
+	err := e.buffer.shutdown(ctx)
+	return errors.Join(err, e.next.Shutdown(ctx))
+}
+
+// bufferFlushingSpanProcessor makes ForceFlush of the wrapped batch
+// processor wait for the spans it handed to buffer, since span exporters
+// have no ForceFlush of their own.
+type bufferFlushingSpanProcessor struct {
+	sdktrace.SpanProcessor
+	buffer *exportBuffer[sdktrace.ReadOnlySpan]
+}
+
+func (p bufferFlushingSpanProcessor) ForceFlush(ctx context.Context) error {
+	if err := p.SpanProcessor.ForceFlush(ctx); err != nil {
+		return err
+	}
+	return p.buffer.flush(ctx)
+}
+
+// bufferedLogExporter hands log records to an exportBuffer in front of the
+// wrapped exporter.
+type bufferedLogExporter struct {
+	next   sdklog.Exporter
+	buffer *exportBuffer[sdklog.Record]
+}
+
+func newBufferedLogExporter(next sdklog.Exporter, limit int64, m *pipelineMetrics) *bufferedLogExporter {
+	return &bufferedLogExporter{
+		next:   next,
+		buffer: newExportBuffer("logs", limit, m, logRecordSize, next.Export),
+	}
+}
+
+func (e *bufferedLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	// The batch processor reuses records once Export returns.
+	clones := make([]sdklog.Record, len(records))
+	for i, r := range records {
+		clones[i] = r.Clone()
+	}
+	e.buffer.enqueue(clones)
+	return nil
+}
+
+func (e *bufferedLogExporter) ForceFlush(ctx context.Context) error {
+	err := e.buffer.flush(ctx)
+	return errors.Join(err, e.next.ForceFlush(ctx))
+}
+
+func (e *bufferedLogExporter) Shutdown(ctx context.Context) error {
+	err := e.buffer.shutdown(ctx)
+	return errors.Join(err, e.next.Shutdown(ctx))
+}
+
+// itemOverhead approximates the fixed size of a span or log record: ids,
+// timestamps, scope and resource pointers, slice headers.
+const itemOverhead = 256
+
+// spanSize estimates the memory held by s.
+func spanSize(s sdktrace.ReadOnlySpan) int64 {
+	n := int64(itemOverhead + len(s.Name()))
+	n += attributesSize(s.Attributes())
+	for _, e := range s.Events() {
+		n += 64 + int64(len(e.Name)) + attributesSize(e.Attributes)
+	}
+	for _, l := range s.Links() {
+		n += 64 + attributesSize(l.Attributes)
+	}
+	return n + int64(len(s.Status().Description))
+}
+
+func attributesSize(attrs []attribute.KeyValue) int64 {
+	var n int64
+	for _, kv := range attrs {
+		n += 16 + int64(len(kv.Key)) + attributeValueSize(kv.Value)
+	}
+	return n
+}
+
+// attributeValueSize estimates the memory held by v from its type, without
+// formatting it: this runs for every exported span.
+func attributeValueSize(v attribute.Value) int64 {
+	switch v.Type() {
+	case attribute.STRING:
+		return int64(len(v.AsString()))
+	case attribute.STRINGSLICE:
+		var n int64
+		for _, s := range v.AsStringSlice() {
+			n += 16 + int64(len(s))
+		}
+		return n
+	case attribute.BOOLSLICE:
+		return int64(len(v.AsBoolSlice()))
+	case attribute.INT64SLICE:
+		return 8 * int64(len(v.AsInt64Slice()))
+	case attribute.FLOAT64SLICE:
+		return 8 * int64(len(v.AsFloat64Slice()))
+	default:
+		return 8
+	}
+}
+
+// logRecordSize estimates the memory held by r.
+func logRecordSize(r sdklog.Record) int64 {
+	n := int64(itemOverhead+len(r.EventName())) + logValueSize(r.Body())
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		n += 16 + int64(len(kv.Key)) + logValueSize(kv.Value)
+		return true
+	})
+	return n
+}
+
+func logValueSize(v otellog.Value) int64 {
+	switch v.Kind() {
+	case otellog.KindString:
+		return int64(len(v.AsString()))
+	case otellog.KindBytes:
+		return int64(len(v.AsBytes()))
+	case otellog.KindSlice:
+		var n int64
+		for _, e := range v.AsSlice() {
+			n += 16 + logValueSize(e)
+		}
+		return n
+	case otellog.KindMap:
+		var n int64
+		for _, kv := range v.AsMap() {
+			n += 16 + int64(len(kv.Key)) + logValueSize(kv.Value)
+		}
+		return n
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func newTestPipelineMetrics(t *testing.T) *pipelineMetrics {
+	t.Helper()
+	m, err := newPipelineMetrics(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// queued returns the items of b's queued batches, batch by batch.
+func queued(b *exportBuffer[int]) [][]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out [][]int
+	for _, batch := range b.batches {
+		var items []int
+		for _, it := range batch {
+			items = append(items, it.item)
+		}
+		out = append(out, items)
+	}
+	return out
+}
+
+func TestExportBufferDropsOldest(t *testing.T) {
+	m := newTestPipelineMetrics(t)
+	// Not started, so every enqueued batch stays queued. Items weigh their
+	// value in bytes.
+	b := &exportBuffer[int]{
+		signal:  "test",
+		limit:   10,
+		size:    func(n int) int64 { return int64(n) },
+		metrics: m,
+		wake:    make(chan struct{}, 1),
+	}
+
+	steps := []struct {
+		enqueue     []int
+		wantBatches [][]int
+		wantBytes   int64
+		wantDropped int64
+	}{
+		{[]int{3, 3}, [][]int{{3, 3}}, 6, 0},
+		{[]int{4}, [][]int{{3, 3}, {4}}, 10, 0},
+		// Over the limit: the oldest item goes, from within its batch.
+		{[]int{2, 1}, [][]int{{3}, {4}, {2, 1}}, 10, 1},
+		// Dropping empties the first batch and moves on to the next.
+		{[]int{5}, [][]int{{2, 1}, {5}}, 8, 3},
+		// An item larger than the limit leaves nothing behind, itself
+		// included.
+		{[]int{20}, nil, 0, 7},
+	}
+	for i, step := range steps {
+		b.enqueue(step.enqueue)
+		got := queued(b)
+		if !slices.EqualFunc(got, step.wantBatches, slices.Equal) {
+			t.Errorf("step %d: batches = %v, want %v", i, got, step.wantBatches)
+		}
+		if _, bytes, _ := b.usage(); bytes != step.wantBytes {
+			t.Errorf("step %d: bytes = %d, want %d", i, bytes, step.wantBytes)
+		}
+		if dropped := m.Totals()["test"].Dropped; dropped != step.wantDropped {
+			t.Errorf("step %d: dropped = %d, want %d", i, dropped, step.wantDropped)
+		}
+	}
+}
+
+func TestExportBufferShutdownStopsExport(t *testing.T) {
+	m := newTestPipelineMetrics(t)
+	started := make(chan struct{}, 1)
+	b := newExportBuffer("test", 1<<20, m, func(int) int64 { return 1 }, func(ctx context.Context, _ []int) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	b.enqueue([]int{1})
+	<-started
+	b.enqueue([]int{2, 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-b.done:
+	default:
+		t.Fatal("shutdown returned before the export goroutine")
+	}
+	if dropped := m.Totals()["test"].Dropped; dropped != 2 {
+		t.Errorf("dropped = %d, want the 2 items never exported", dropped)
+	}
+	if _, bytes, _ := b.usage(); bytes != 0 {
+		t.Errorf("bytes = %d after shutdown, want 0", bytes)
+	}
+}
+
+func TestTelemetryBufferBudget(t *testing.T) {
+	defer func(memory int, limit int64) {
+		telemetryBufferMemory, memoryLimit = memory, limit
+	}(telemetryBufferMemory, memoryLimit)
+
+	tests := []struct {
+		name   string
+		memory int
+		limit  int64
+		want   int64
+	}{
+		{"no limit", 0, 0, 64 << 20},
+		{"5% of the limit", 0, 1 << 30, (1 << 30) / 20},
+		{"at least 4MiB", 0, 16 << 20, 4 << 20},
+		{"at most 64MiB", 0, 4 << 30, 64 << 20},
+		{"TELEMETRY_BUFFER_MEMORY", 1 << 20, 4 << 30, 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetryBufferMemory, memoryLimit = tt.memory, tt.limit
+			if got := telemetryBufferBudget(); got != tt.want {
+				t.Errorf("telemetryBufferBudget() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributesSize(t *testing.T) {
+	tests := []struct {
+		kv   attribute.KeyValue
+		want int64
+	}{
+		{attribute.String("k", "abcd"), 16 + 1 + 4},
+		{attribute.StringSlice("k", []string{"ab", "c"}), 16 + 1 + 16 + 2 + 16 + 1},
+		{attribute.Int64Slice("k", []int64{1, 2, 3}), 16 + 1 + 3*8},
+		{attribute.BoolSlice("k", []bool{true, false}), 16 + 1 + 2},
+		{attribute.Int64("k", 1<<40), 16 + 1 + 8},
+		{attribute.Bool("k", true), 16 + 1 + 8},
+	}
+	for _, tt := range tests {
+		if got := attributesSize([]attribute.KeyValue{tt.kv}); got != tt.want {
+			t.Errorf("attributesSize(%s=%s) = %d, want %d", tt.kv.Key, tt.kv.Value.Emit(), got, tt.want)
+		}
+	}
+}
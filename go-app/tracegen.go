@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxGenRate is the highest -rate of tracegen and loggen: one item per
+// nanosecond, the shortest ticker interval.
+const maxGenRate = float64(time.Second)
+
+// tracegenConfig describes the shape of the synthetic traces produced by the
+// tracegen subcommand.
+type tracegenConfig struct {
+	traces      int
+	rate        float64
+	depth       int
+	width       int
+	errorRate   float64
+	minDuration time.Duration
+	maxDuration time.Duration
+}
+
+func parseTracegenFlags(args []string) (tracegenConfig, error) {
+	var cfg tracegenConfig
+	fs := flag.NewFlagSet("tracegen", flag.ContinueOnError)
+	fs.IntVar(&cfg.traces, "traces", 100, "number of traces to generate (0 runs until interrupted)")
+	fs.Float64Var(&cfg.rate, "rate", 10, "traces generated per second")
+	fs.IntVar(&cfg.depth, "depth", 3, "number of span levels below the root span")
+	fs.IntVar(&cfg.width, "width", 2, "number of child spans per parent span")
+	fs.Float64Var(&cfg.errorRate, "error-rate", 0.05, "probability (0-1) that a span is marked as failed")
+	fs.DurationVar(&cfg.minDuration, "min-duration", 10*time.Millisecond, "minimum root span duration")
+	fs.DurationVar(&cfg.maxDuration, "max-duration", 500*time.Millisecond, "maximum root span duration")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case cfg.traces < 0:
+		return cfg, errors.New("-traces must not be negative")
+	case !(cfg.rate > 0 && cfg.rate <= maxGenRate):
+		return cfg, fmt.Errorf("-rate must be positive and at most %g", maxGenRate)
+	case cfg.depth < 0 || cfg.width < 0:
+		return cfg, errors.New("-depth and -width must not be negative")
+	case cfg.errorRate < 0 || cfg.errorRate > 1:
+		return cfg, errors.New("-error-rate must be between 0 and 1")
+	case cfg.minDuration <= 0 || cfg.maxDuration < cfg.minDuration:
+		return cfg, errors.New("-min-duration must be positive and not exceed -max-duration")
+	}
+	return cfg, nil
+}
+
+// runTracegen fabricates traces with the configured topology and exports them
+// through the regular tracer provider. Span timestamps are synthesized, so no
+// real time is spent inside the generated operations.
+func runTracegen(ctx context.Context, args []string) error {
+	cfg, err := parseTracegenFlags(args)
+	if err != nil {
+		return err
+	}
+
+	logger.InfoContext(ctx, "tracegen: generating traces", "traces", cfg.traces, "depth", cfg.depth, "width", cfg.width, "error_rate", cfg.errorRate)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+	defer ticker.Stop()
+
+	generated := 0
+	for cfg.traces == 0 || generated < cfg.traces {
+		select {
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "tracegen: interrupted", "generated", generated)
+			return nil
+		case <-ticker.C:
+		}
+
+		duration := cfg.minDuration + time.Duration(rand.Int63n(int64(cfg.maxDuration-cfg.minDuration)+1))
+		end := time.Now()
+		generateSpan(ctx, cfg, "tracegen.root", 0, end.Add(-duration), end)
+		generated++
+	}
+
+	logger.InfoContext(ctx, "tracegen: done", "generated", generated)
+	return nil
+}
+
+// generateSpan emits a span covering [start, end] and recursively fills it
+// with cfg.width sequential children until cfg.depth is reached.
+func generateSpan(ctx context.Context, cfg tracegenConfig, name string, level int, start, end time.Time) {
+	kind := trace.SpanKindInternal
+	if level == 0 {
+		kind = trace.SpanKindServer
+	}
+	ctx, span := tracer.Start(ctx, name,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.Bool("tracegen.synthetic", true),
+			attribute.Int("tracegen.level", level),
+		),
+	)
+
+	if level < cfg.depth && cfg.width > 0 {
+		slot := end.Sub(start) / time.Duration(cfg.width)
+		for i := 0; i < cfg.width; i++ {
+			childStart := start.Add(time.Duration(i) * slot)
+			// Leave a random gap at the end of each slot to mimic work done
+			// by the parent between child operations.
+			childEnd := childStart.Add(slot/2 + time.Duration(rand.Int63n(int64(slot/2)+1)))
+			generateSpan(ctx, cfg, fmt.Sprintf("tracegen.op.%d.%d", level+1, i), level+1, childStart, childEnd)
+		}
+	}
+
+	if rand.Float64() < cfg.errorRate {
+		err := errors.New("synthetic failure")
+		span.RecordError(err, trace.WithTimestamp(end))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// viewConfig customizes the aggregation and attributes of instruments without
+// touching the code that records them.
+type viewConfig struct {
+	// Instrument is the instrument name; "*" wildcards are supported.
+	Instrument string `json:"instrument" yaml:"instrument"`
+	// Aggregation is "explicit" or "exponential". When empty, the
+	// aggregation selected by HISTOGRAM_AGGREGATION is used for views that
+	// define bucket boundaries.
+	Aggregation string `json:"aggregation,omitempty" yaml:"aggregation,omitempty"`
+	// Boundaries are the explicit bucket boundaries.
+	Boundaries []float64 `json:"boundaries,omitempty" yaml:"boundaries,omitempty"`
+	// MaxSize and MaxScale tune exponential histograms; zero values use
+	// the SDK defaults.
+	MaxSize  int32 `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	MaxScale int32 `json:"max_scale,omitempty" yaml:"max_scale,omitempty"`
+	// Attributes, when set, is the allowlist of attribute keys kept on
+	// measurements. Any other attribute is dropped before aggregation.
+	Attributes []string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+// httpLatencyBuckets are the bucket boundaries of HTTP request latencies.
+var httpLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.15, 0.2, 0.25, 0.3, 0.4, 0.5, 0.75, 1, 2.5, 5}
+
+// defaultViewConfigs tunes the buckets of the latency histograms to the 50ms
+// to 300ms range the demo endpoints operate in, and restricts HTTP server
+// and client request metrics to low-cardinality attributes.
+var defaultViewConfigs = []viewConfig{
+	{
+		Instrument: "app.work.duration",
+		Boundaries: []float64{0.025, 0.05, 0.075, 0.1, 0.125, 0.15, 0.175, 0.2, 0.225, 0.25, 0.3, 0.4, 0.5, 0.75, 1},
+	},
+	{
+		Instrument: "app.experiment.duration",
+		Boundaries: []float64{0.01, 0.02, 0.03, 0.05, 0.075, 0.1, 0.125, 0.15, 0.175, 0.2, 0.25, 0.3, 0.5},
+	},
+	{
+		Instrument: "http.server.request.duration",
+		Boundaries: httpLatencyBuckets,
+	},
+	{
+		Instrument: "http.server.unsampled.duration",
+		Boundaries: httpLatencyBuckets,
+	},
+	{
+		Instrument: "http.server.request.cpu_time",
+		Boundaries: []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1},
+	},
+	{
+		Instrument: "http.server.*",
+		Attributes: []string{"http.route", "http.request.method", "http.response.status_code", "error.type"},
+	},
+	{
+		Instrument: "http.client.request.duration",
+		Boundaries: httpLatencyBuckets,
+	},
+	{
+		Instrument: "http.client.request.*",
+		Attributes: []string{"server.address", "server.port", "http.request.method", "http.response.status_code", "error.type"},
+	},
+	{
+		Instrument: "http.client.response.*",
+		Attributes: []string{"server.address", "server.port", "http.request.method", "http.response.status_code", "error.type"},
+	},
+}
+
+// loadViewConfigs reads view definitions from the JSON file at path. When
+// path is empty it returns the views of CONFIG_FILE, or defaultViewConfigs
+// if the config file has none.
+func loadViewConfigs(path string) ([]viewConfig, error) {
+	if path == "" {
+		if len(configFile.Views) > 0 {
+			return configFile.Views, nil
+		}
+		return defaultViewConfigs, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric views file: %w", err)
+	}
+	var cfgs []viewConfig
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("failed to parse metric views file %s: %w", path, err)
+	}
+	return cfgs, nil
+}
+
+// compiledView is a viewConfig with its matcher, aggregation and attribute
+// filter resolved. A nil aggregation or filter leaves the SDK default.
+type compiledView struct {
+	match       sdkmetric.View
+	aggregation sdkmetric.Aggregation
+	filter      attribute.Filter
+}
+
+// buildView combines view configurations into a single SDK view. Several
+// configurations may match one instrument (e.g. buckets from one, the
+// attribute allowlist from a wildcard); the first configuration that sets a
+// property wins. Combining them keeps the SDK from exporting one stream per
+// matching view.
+//
+// defaultAggregation is "explicit" or "exponential"; when it is exponential,
+// every latency histogram (unit "s") without an explicit choice is recorded
+// with exponential buckets. Counters always get an errorPreferringReservoir,
+// every other instrument a sampledReservoir.
+func buildView(cfgs []viewConfig, defaultAggregation string) (sdkmetric.View, error) {
+	if defaultAggregation != "explicit" && defaultAggregation != "exponential" {
+		return nil, fmt.Errorf("unknown histogram aggregation %q", defaultAggregation)
+	}
+
+	compiled := make([]compiledView, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Instrument == "" {
+			return nil, errors.New("metric view is missing an instrument name")
+		}
+
+		cv := compiledView{
+			// An empty stream turns NewView into a pure matcher that
+			// follows the SDK's wildcard rules.
+			match: sdkmetric.NewView(sdkmetric.Instrument{Name: cfg.Instrument}, sdkmetric.Stream{}),
+		}
+
+		aggregation := cfg.Aggregation
+		if aggregation == "" && len(cfg.Boundaries) > 0 {
+			aggregation = defaultAggregation
+		}
+		switch aggregation {
+		case "":
+		case "explicit":
+			if len(cfg.Boundaries) == 0 {
+				return nil, fmt.Errorf("metric view for %q needs bucket boundaries", cfg.Instrument)
+			}
+			cv.aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: cfg.Boundaries}
+		case "exponential":
+			cv.aggregation = exponentialAggregation(cfg.MaxSize, cfg.MaxScale)
+		default:
+			return nil, fmt.Errorf("metric view for %q has unknown aggregation %q", cfg.Instrument, cfg.Aggregation)
+		}
+
+		if len(cfg.Attributes) > 0 {
+			keys := make([]attribute.Key, len(cfg.Attributes))
+			for i, k := range cfg.Attributes {
+				keys[i] = attribute.Key(k)
+			}
+			cv.filter = attribute.NewAllowKeysFilter(keys...)
+		}
+
+		compiled = append(compiled, cv)
+	}
+
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		var stream sdkmetric.Stream
+		for _, cv := range compiled {
+			if _, ok := cv.match(inst); !ok {
+				continue
+			}
+			if stream.Aggregation == nil {
+				stream.Aggregation = cv.aggregation
+			}
+			if stream.AttributeFilter == nil {
+				stream.AttributeFilter = cv.filter
+			}
+		}
+		if stream.Aggregation == nil && defaultAggregation == "exponential" &&
+			inst.Kind == sdkmetric.InstrumentKindHistogram && inst.Unit == "s" {
+			stream.Aggregation = exponentialAggregation(0, 0)
+		}
+		if inst.Kind == sdkmetric.InstrumentKindCounter {
+			stream.ExemplarReservoirProviderSelector = errorPreferringSelector
+		} else {
+			stream.ExemplarReservoirProviderSelector = sampledExemplarSelector
+		}
+
+		stream.Name = inst.Name
+		stream.Description = inst.Description
+		stream.Unit = inst.Unit
+		return stream, true
+	}, nil
+}
+
+// exponentialAggregation returns a base-2 exponential histogram aggregation,
+// falling back to the SDK default size and scale for zero values.
+func exponentialAggregation(maxSize, maxScale int32) sdkmetric.Aggregation {
+	if maxSize == 0 {
+		maxSize = 160
+	}
+	if maxScale == 0 {
+		maxScale = 20
+	}
+	return sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: maxSize, MaxScale: maxScale}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/metrics"
+)
+
+// webhookDedupMaxEvents bounds the dedup window's memory under a flood of
+// distinct events; the oldest are forgotten first.
+const webhookDedupMaxEvents = 100_000
+
+// webhookEvent is the part of an inbound webhook the receiver looks at.
+type webhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// webhookDelivery is the first delivery of an event within the window.
+type webhookDelivery struct {
+	id   string
+	at   time.Time
+	span trace.SpanContext
+}
+
+// webhookReceiver ingests webhook events at least once but processes each
+// at most once: senders retry on timeouts and errors, so the same event ID
+// may arrive several times. An event ID seen within the dedup window is
+// acknowledged without being processed again, and its request span gets
+// duplicate=true and a link (link.type=webhook.original) to the span of the
+// first delivery. Every event is counted in app.webhook.events and
+// duplicates also in app.webhook.duplicates, both by webhook.event.type.
+// Senders choose the type, so the metrics only use the known types and
+// count any other as "other"; spans and logs keep the type as sent.
+//
+// The ID is claimed before the event is processed, so concurrent retries
+// of an event still being processed are duplicates too; a failed delivery
+// releases its claim so the sender's retry is processed.
+type webhookReceiver struct {
+	window time.Duration
+	types  map[string]bool // known event types
+
+	mu    sync.Mutex
+	seen  map[string]webhookDelivery
+	order []webhookDelivery // by arrival, for expiry
+
+	events     metric.Int64Counter
+	duplicates metric.Int64Counter
+	age        metric.Float64Histogram
+}
+
+func newWebhookReceiver(meter metric.Meter, window time.Duration, types []string) (*webhookReceiver, error) {
+	wr := &webhookReceiver{window: window, types: make(map[string]bool), seen: make(map[string]webhookDelivery)}
+	for _, t := range types {
+		wr.types[t] = true
+	}
+	var err error
+	wr.events, err = metrics.WebhookEvents.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	wr.duplicates, err = metrics.WebhookDuplicates.Int64Counter(meter)
+	if err != nil {
+		return nil, err
+	}
+	wr.age, err = metrics.WebhookDuplicateAge.Float64Histogram(meter)
+	if err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// metricType returns the webhook.event.type of eventType on metrics: the
+// type itself if it is known, else "other".
+func (wr *webhookReceiver) metricType(eventType string) string {
+	if wr.types[eventType] {
+		return eventType
+	}
+	return "other"
+}
+
+// claim records the first delivery of id, or returns the delivery that
+// claimed it already within the window.
+func (wr *webhookReceiver) claim(id string, span trace.SpanContext) (webhookDelivery, bool) {
+	now := time.Now()
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.expire(now)
+	if first, ok := wr.seen[id]; ok {
+		return first, false
+	}
+	d := webhookDelivery{id: id, at: now, span: span}
+	wr.seen[id] = d
+	wr.order = append(wr.order, d)
+	return d, true
+}
+
+// release forgets a claim whose processing failed.
+func (wr *webhookReceiver) release(d webhookDelivery) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if cur, ok := wr.seen[d.id]; ok && cur.at.Equal(d.at) {
+		delete(wr.seen, d.id)
+	}
+}
+
+// expire drops deliveries older than the window, and the oldest beyond
+// webhookDedupMaxEvents, and returns how many it dropped. wr.mu must be
+// held.
+func (wr *webhookReceiver) expire(now time.Time) int {
+	n := 0
+	for n < len(wr.order) && (now.Sub(wr.order[n].at) >= wr.window || len(wr.order)-n >= webhookDedupMaxEvents) {
+		d := wr.order[n]
+		// A released and reclaimed ID has a newer entry further on.
+		if cur, ok := wr.seen[d.id]; ok && cur.at.Equal(d.at) {
+			delete(wr.seen, d.id)
+		}
+		n++
+	}
+	wr.order = wr.order[n:]
+	return n
+}
+
+// cleanup expires deliveries that fell out of the dedup window. Claims
+// expire lazily as events arrive; this scheduled task frees their memory
+// when no events arrive.
+func (wr *webhookReceiver) cleanup(ctx context.Context) error {
+	wr.mu.Lock()
+	expired := wr.expire(time.Now())
+	remaining := len(wr.order)
+	wr.mu.Unlock()
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("webhook.dedup.expired", expired),
+		attribute.Int("webhook.dedup.remaining", remaining),
+	)
+	appLog.Debug(ctx, "Expired webhook deliveries",
+		logging.Int("webhook.dedup.expired", expired),
+		logging.Int("webhook.dedup.remaining", remaining),
+	)
+	return nil
+}
+
+// ServeHTTP accepts a POSTed JSON event with an id and a type. New events are
+// processed and answered with 202; duplicates are answered with 200 so the
+// sender stops retrying.
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	httpRequestsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("http.route", "/webhooks")))
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var ev webhookEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&ev); err != nil || ev.ID == "" {
+		http.Error(w, `Expected a JSON event with an "id"`, http.StatusBadRequest)
+		return
+	}
+	if ev.Type == "" {
+		ev.Type = "unknown"
+	}
+
+	span := trace.SpanFromContext(ctx)
+	typeAttr := attribute.String("webhook.event.type", wr.metricType(ev.Type))
+	d, first := wr.claim(ev.ID, span.SpanContext())
+	span.SetAttributes(
+		attribute.String("webhook.event.id", ev.ID),
+		attribute.String("webhook.event.type", ev.Type),
+		attribute.Bool("duplicate", !first),
+	)
+	wr.events.Add(ctx, 1, metric.WithAttributes(typeAttr, attribute.Bool("duplicate", !first)))
+
+	if !first {
+		age := time.Since(d.at)
+		if d.span.IsValid() {
+			span.AddLink(trace.Link{
+				SpanContext: d.span,
+				Attributes:  []attribute.KeyValue{attribute.String("link.type", "webhook.original")},
+			})
+		}
+		wr.duplicates.Add(ctx, 1, metric.WithAttributes(typeAttr))
+		wr.age.Record(ctx, age.Seconds(), metric.WithAttributes(typeAttr))
+		appLog.Info(ctx, "Duplicate webhook event ignored",
+			logging.String("webhook.event.id", ev.ID),
+			logging.String("webhook.event.type", ev.Type),
+			logging.Duration("webhook.duplicate.age", age),
+		)
+		fmt.Fprintf(w, "Event %s already received.\n", ev.ID)
+		return
+	}
+
+	if err := processWebhookEvent(ctx, ev); err != nil {
+		wr.release(d)
+		http.Error(w, "Failed to process event", http.StatusInternalServerError)
+		appLog.Error(ctx, "Webhook event processing failed",
+			logging.String("webhook.event.id", ev.ID),
+			logging.Err(err),
+		)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "Event %s accepted.\n", ev.ID)
+}
+
+// processWebhookEvent stands in for the event's side effects. It fails 2%
+// of the time, so the sender's retry of a failed delivery is processed.
+func processWebhookEvent(ctx context.Context, ev webhookEvent) error {
+	ctx, span := tracer.Start(ctx, "webhook.process", trace.WithAttributes(
+		attribute.String("webhook.event.type", ev.Type),
+	))
+	defer span.End()
+
+	appLog.Info(ctx, "Processing webhook event", logging.String("webhook.event.id", ev.ID))
+	time.Sleep(time.Duration(20+rand.Intn(30)) * time.Millisecond)
+	if rand.Float64() < 0.02 {
+		err := errors.New("simulated processing failure")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestWebhookMetricType(t *testing.T) {
+	wr, err := newWebhookReceiver(noop.NewMeterProvider().Meter("test"), time.Minute, splitList("order.created, payment.failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"order.created":           "order.created",
+		"payment.failed":          "payment.failed",
+		"order.deleted":           "other",
+		"unknown":                 "other",
+		strings.Repeat("x", 4096): "other",
+	} {
+		if got := wr.metricType(in); got != want {
+			t.Errorf("metricType(%.20q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"my-go-app/pkg/logging"
+	"my-go-app/pkg/queue"
+	"my-go-app/pkg/telemetry"
+)
+
+// workJob is the follow-up work /work hands to the queue.
+type workJob struct {
+	DownstreamStatusCode int       `json:"downstream_status_code"`
+	RequestedAt          time.Time `json:"requested_at"`
+}
+
+// queueServers returns the servers of the QUEUE_TRANSPORT in use, if any
+// are configured.
+func queueServers() (string, error) {
+	switch queueTransport {
+	case "kafka":
+		return kafkaBrokers, nil
+	case "nats":
+		return natsURL, nil
+	default:
+		return "", fmt.Errorf("invalid QUEUE_TRANSPORT %q, want kafka or nats", queueTransport)
+	}
+}
+
+// startWorkJobs connects the asynchronous part of /work over
+// QUEUE_TRANSPORT to servers: a producer that publishes a workJob per request to
+// QUEUE_TOPIC, and a consumer in QUEUE_CONSUMER_GROUP that processes them
+// in the background. Trace context and baggage travel in the message
+// headers, so each job's process span links back to the send span of the
+// request that published it.
+func startWorkJobs(ctx context.Context, servers string, shutdown *shutdownCoordinator) error {
+	scope := telemetry.Scope("queue")
+	producer, consumer, err := queue.Open(ctx, queue.Config{
+		Transport:          queueTransport,
+		Servers:            splitList(servers),
+		Topic:              queueTopic,
+		Group:              queueConsumerGroup,
+		QueueTimeObjective: queueTimeObjective,
+	}, scope.Tracer(), scope.Meter(), otel.GetTextMapPropagator())
+	if err != nil {
+		return err
+	}
+	shutdown.Add("queue producer", func(context.Context) error { return producer.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, processWorkJob, func(err error, retryIn time.Duration) {
+			appLog.Warn(ctx, "Queue consumer failed", logging.Err(err), logging.Duration("retry_in", retryIn))
+		})
+	}()
+	// Both stop after the HTTP server has drained, so every request's job
+	// is published; jobs not consumed yet are left for the group.
+	shutdown.Add("queue consumer", func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return consumer.Close()
+	})
+
+	workJobs = producer
+	return nil
+}
+
+// publishWorkJob hands the rest of a /work request to the queue.
+// The request does not wait for the job, and does not fail if it cannot
+// be published.
+func publishWorkJob(ctx context.Context, downstreamStatus int) {
+	job, _ := json.Marshal(workJob{DownstreamStatusCode: downstreamStatus, RequestedAt: time.Now()})
+	ctx, cancel := dependencyPolicies.Policy("queue").WithTimeout(ctx)
+	defer cancel()
+	if err := workJobs.Publish(ctx, nil, job); err != nil {
+		appLog.Warn(ctx, "Failed to publish work job", logging.Err(err))
+	}
+}
+
+// processWorkJob stands in for work done after the request has returned.
+func processWorkJob(ctx context.Context, _, value []byte) error {
+	var job workJob
+	if err := json.Unmarshal(value, &job); err != nil {
+		return err
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("downstream.status_code", job.DownstreamStatusCode))
+
+	appLog.Info(ctx, "Processing work job")
+	time.Sleep(time.Duration(30+rand.Intn(50)) * time.Millisecond)
+	span.AddEvent("Job processing complete")
+	return nil
+}